@@ -0,0 +1,153 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gernest/wow"
+	"github.com/gernest/wow/spin"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/environments"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Update the dev environment to match vessel.yml",
+	Long: `Compare vessel.yml (image, machine size, volumes, etc) with the dev environment's machine,
+show the differences, and update the machine to match. The IP address, SSH keys and app name stay the same.`,
+	Run: runApplyCommand,
+}
+
+var applyDryRun bool
+var applyYes bool
+
+func init() {
+	applyCmd.Flags().StringVarP(&ConfigPath, "config-file", "c", "vessel.yml", "Configuration file to read from")
+	applyCmd.Flags().BoolVar(&applyDryRun, "dry-run", false, "Only show the differences, don't update the machine")
+	applyCmd.Flags().BoolVarP(&applyYes, "yes", "y", false, "Update without prompting for approval")
+	applyCmd.Flags().BoolVar(&forceLease, "force", false, "Take over the environment even if a teammate is using it")
+}
+
+func runApplyCommand(cmd *cobra.Command, args []string) {
+	cfg, err := config.RetrieveProjectConfig(ConfigPath)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "apply", "msg", "could not read configuration", "error", err)
+		PrintIfVerbose(Verbose, err, "error reading project configuration file")
+
+		os.Exit(1)
+	}
+
+	requireFlyProvider("apply", cfg)
+
+	auth, err := config.RetrieveVesselConfig()
+
+	if err != nil {
+		logger.GetLogger().Error("command", "apply", "msg", "could not get Fly API token from vessel config", "error", err)
+		PrintIfVerbose(Verbose, err, "error retrieving Fly API token")
+
+		os.Exit(1)
+	}
+
+	stopFlyctl := ensureFlyApi("apply")
+	defer stopFlyctl()
+
+	machineId, err := environments.MachineId(auth.Token, cfg.Name)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "apply", "msg", "could not find dev environment machine", "error", err)
+		PrintIfVerbose(Verbose, err, "could not find the dev environment's machine")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	machine, err := fly.GetMachine(auth.Token, cfg.Name, machineId)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "apply", "msg", "could not get machine", "error", err)
+		PrintIfVerbose(Verbose, err, "could not get the dev environment's machine")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	plan, err := environments.PlanMachineUpdate(auth.Token, cfg, machine)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "apply", "msg", "could not plan machine update", "error", err)
+		PrintIfVerbose(Verbose, err, "could not compare vessel.yml with the dev environment")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	if !plan.HasChanges() {
+		fmt.Println("The dev environment already matches vessel.yml")
+		return
+	}
+
+	for _, c := range plan.Changes {
+		fmt.Println(colorizeChange(c))
+	}
+
+	if applyDryRun {
+		return
+	}
+
+	if !applyYes {
+		canUpdate := promptui.Prompt{
+			Label:     "This will restart the dev environment with the changes above, continue",
+			IsConfirm: true,
+		}
+
+		if _, err = canUpdate.Run(); err != nil {
+			stopFlyctl()
+			os.Exit(0)
+		}
+	}
+
+	w := wow.New(os.Stdout, spin.Get(spin.Dots), " Updating environment")
+	w.Start()
+
+	if _, err = environments.ApplyMachinePlan(auth.Token, cfg.Name, machine, plan, leaseOptions()); err != nil {
+		logger.GetLogger().Error("command", "apply", "msg", "could not update machine", "error", err)
+		PrintIfVerbose(Verbose, err, "could not update the dev environment")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	// The updated machine may come back with new host keys, which are trusted on the next connection
+	if err = config.ForgetEnvironmentHostKey(cfg.Name); err != nil {
+		logger.GetLogger().Error("command", "apply", "msg", "could not forget host key", "error", err)
+		PrintIfVerbose(Verbose, err, "could not reset the dev environment's host key")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	w.PersistWith(spin.Spinner{Frames: []string{"\033[1;32m\xE2\x9C\x94\033[0m"}}, " Environment updated")
+}
+
+// colorizeChange colors added lines green, removed lines red and changed lines yellow
+func colorizeChange(change string) string {
+	if len(change) == 0 {
+		return change
+	}
+
+	switch change[0] {
+	case '+':
+		return "\033[0;32m" + change + "\033[0m"
+	case '-':
+		return "\033[0;31m" + change + "\033[0m"
+	case '~':
+		return "\033[0;33m" + change + "\033[0m"
+	}
+
+	return change
+}
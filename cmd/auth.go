@@ -8,6 +8,7 @@ import (
 	"github.com/vessel-app/vessel-cli/internal/fly"
 	"github.com/vessel-app/vessel-cli/internal/logger"
 	"github.com/vessel-app/vessel-cli/internal/util"
+	"github.com/vessel-app/vessel-cli/internal/vessel"
 	"os"
 	"path/filepath"
 )
@@ -15,14 +16,18 @@ import (
 var authCmd = &cobra.Command{
 	Use:   "auth",
 	Short: "Add a authorization token",
-	Long:  `Add a authorization token to generate a dev server against your account at https://vessel.app`,
-	Run:   runAuthCommand,
+	Long: `Add a authorization token to generate a dev server against your account at https://vessel.app
+By default a Fly.io token is used, from --token or ~/.fly/config.yml. Use --vessel to use
+the hosted Vessel API instead, choosing one of your teams.`,
+	Run: runAuthCommand,
 }
 
 var AuthToken string
+var AuthVessel bool
 
 func init() {
 	authCmd.Flags().StringVarP(&AuthToken, "token", "t", "", "Auth token generated at https://vessel.app/user/api-tokens")
+	authCmd.Flags().BoolVar(&AuthVessel, "vessel", false, "Authenticate with the hosted Vessel API, instead of Fly.io")
 }
 
 func runAuthCommand(cmd *cobra.Command, args []string) {
@@ -35,6 +40,18 @@ func runAuthCommand(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	// Settings from previous runs are kept when re-authenticating
+	existing, err := config.RetrieveVesselConfig()
+
+	if err != nil {
+		existing = &config.AuthConfig{}
+	}
+
+	if AuthVessel {
+		runVesselAuth(vesselDir, existing)
+		return
+	}
+
 	if len(AuthToken) == 0 {
 		// Get access_token from ~/.fly/config.yml
 		flycfg, err := config.RetrieveFlyConfig()
@@ -87,8 +104,16 @@ func runAuthCommand(cmd *cobra.Command, args []string) {
 org: %s
 `, AuthToken, SelectedOrg.Name, SelectedOrg.Slug)
 
-	configPath := filepath.ToSlash(vesselDir + "/config.yml")
-	if err = os.WriteFile(configPath, []byte(yaml), 0755); err != nil {
+	// Keep the team image catalog and Vessel API access when re-authenticating
+	if len(existing.Catalog) > 0 {
+		yaml += fmt.Sprintf("catalog: %s\n", existing.Catalog)
+	}
+
+	if len(existing.VesselToken) > 0 {
+		yaml += fmt.Sprintf("vessel_token: %s\nvessel_team: %s\n", existing.VesselToken, existing.VesselTeam)
+	}
+
+	if err = writeAuthConfig(vesselDir, yaml); err != nil {
 		logger.GetLogger().Error("command", "auth", "msg", "could not write vessel config file", "error", err)
 		PrintIfVerbose(Verbose, err, "could not set auth token")
 
@@ -97,3 +122,99 @@ org: %s
 
 	fmt.Println("You're authenticated! Head into an application, and run `vessel init`")
 }
+
+// runVesselAuth stores a token for the hosted Vessel API, along with the team to create
+// environments in. Any Fly.io token and team image catalog are kept.
+func runVesselAuth(vesselDir string, existing *config.AuthConfig) {
+	if len(AuthToken) == 0 {
+		askToken := promptui.Prompt{
+			Label: "Vessel API token (from https://vessel.app/user/api-tokens)",
+			Mask:  '*',
+		}
+
+		token, err := askToken.Run()
+
+		if err != nil {
+			// User likely bailed out
+			os.Exit(1)
+		}
+
+		AuthToken = token
+	}
+
+	user, err := vessel.GetUser(AuthToken)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "auth", "message", "could not get Vessel user from token", "error", err)
+		PrintIfVerbose(Verbose, err, "could not find a Vessel user from that token")
+
+		os.Exit(1)
+	}
+
+	if len(user.Teams) == 0 {
+		logger.GetLogger().Error("command", "auth", "message", "Vessel user has no teams", "user", user.Email)
+		fmt.Println("Your Vessel account isn't part of a team yet, create one at https://vessel.app")
+
+		os.Exit(1)
+	}
+
+	var selectedTeam vessel.Team
+	if len(user.Teams) > 1 {
+		selectTeam := promptui.Select{
+			Label: "Which team should we use?",
+			Items: user.Teams,
+			Templates: &promptui.SelectTemplates{
+				Active:   fmt.Sprintf("%s {{ .Name | underline }}", promptui.IconSelect),
+				Inactive: "  {{ .Name }}",
+				Selected: fmt.Sprintf(`{{ "%s" | green }} {{ .Name | faint }}`, promptui.IconGood),
+			},
+		}
+
+		idx, _, err := selectTeam.Run()
+
+		if err != nil {
+			// User likely bailed out
+			os.Exit(1)
+		}
+
+		selectedTeam = user.Teams[idx]
+	} else {
+		selectedTeam = user.Teams[0]
+	}
+
+	yaml := ""
+
+	if len(existing.Token) > 0 {
+		yaml += fmt.Sprintf("access_token: %s\norg: %s\n", existing.Token, existing.Org)
+	}
+
+	if len(existing.Catalog) > 0 {
+		yaml += fmt.Sprintf("catalog: %s\n", existing.Catalog)
+	}
+
+	yaml += fmt.Sprintf(`vessel_token: %s
+# Team Name: %s
+vessel_team: %s
+`, AuthToken, selectedTeam.Name, selectedTeam.Guid)
+
+	if err = writeAuthConfig(vesselDir, yaml); err != nil {
+		logger.GetLogger().Error("command", "auth", "msg", "could not write vessel config file", "error", err)
+		PrintIfVerbose(Verbose, err, "could not set auth token")
+
+		os.Exit(1)
+	}
+
+	fmt.Println("You're authenticated! Head into an application, and run `vessel init --vessel`")
+}
+
+// writeAuthConfig writes ~/.vessel/config.yml, readable only by the current user as it holds API tokens
+func writeAuthConfig(vesselDir, yaml string) error {
+	configPath := filepath.ToSlash(vesselDir + "/config.yml")
+
+	if err := os.WriteFile(configPath, []byte(yaml), 0600); err != nil {
+		return err
+	}
+
+	// WriteFile does not change the permissions of an existing file
+	return os.Chmod(configPath, 0600)
+}
@@ -4,7 +4,6 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/vessel-app/vessel-cli/internal/config"
 	"github.com/vessel-app/vessel-cli/internal/logger"
-	"github.com/vessel-app/vessel-cli/internal/remote"
 	"os"
 	"strings"
 )
@@ -36,11 +35,21 @@ func runCmdCommand(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	connection := remote.NewConnection(&cfg.Remote)
+	connection, closeTunnel, err := remoteConnection(cfg)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "cmd", "error", err)
+		PrintIfVerbose(Verbose, err, "could not connect to dev environment")
+
+		os.Exit(1)
+	}
+
+	defer closeTunnel()
 
 	if err := connection.Cmd(strings.Join(args, " ")); err != nil {
 		logger.GetLogger().Error("command", "cmd", "error", err)
 		PrintIfVerbose(Verbose, err, "could not run given command")
+		closeTunnel()
 
 		os.Exit(1)
 	}
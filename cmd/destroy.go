@@ -8,7 +8,7 @@ import (
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
 	"github.com/vessel-app/vessel-cli/internal/config"
-	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/environments"
 	"github.com/vessel-app/vessel-cli/internal/logger"
 	"github.com/vessel-app/vessel-cli/internal/mutagen"
 	"github.com/vessel-app/vessel-cli/internal/util"
@@ -28,6 +28,7 @@ func init() {
 	destroyCmd.Flags().BoolVarP(&localFiles, "files-only", "f", false, "Only delete local files, not the virtual machine")
 	destroyCmd.Flags().BoolVarP(&shutUp, "quit", "q", false, "Delete without prompting for approval")
 	destroyCmd.Flags().StringVarP(&ConfigPath, "config-file", "c", "vessel.yml", "Configuration file to read from")
+	destroyCmd.Flags().BoolVar(&forceLease, "force", false, "Take over the environment even if a teammate is using it")
 }
 
 func runDestroyCommand(cmd *cobra.Command, args []string) {
@@ -40,13 +41,10 @@ func runDestroyCommand(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	auth, err := config.RetrieveVesselConfig()
-
-	if err != nil {
-		logger.GetLogger().Error("command", "destroy", "msg", "could not get Fly API token from vessel config", "error", err)
-		PrintIfVerbose(Verbose, err, "error retrieving Fly API token")
-
-		os.Exit(1)
+	// Files-only deletes don't use the provider, e.g. for environments deleted outside of Vessel
+	var provider environments.Provider
+	if !localFiles {
+		provider = environmentProvider("destroy", cfg)
 	}
 
 	// Get mutagen session name
@@ -72,7 +70,7 @@ func runDestroyCommand(cmd *cobra.Command, args []string) {
 
 	/**
 	 * The Process:
-	 * 1. Delete Fly App (which deletes machines, etc)
+	 * 1. Destroy the environment through its provider (for Fly, the machines, volumes and app)
 	 * 2. vessel.yml
 	 * 3. ~/.vessel/envs/<app-name>
 	 * 4. Warn about ~/.ssh/config entries (TODO: Can we safely delete from that file?)
@@ -80,39 +78,19 @@ func runDestroyCommand(cmd *cobra.Command, args []string) {
 
 	stopFlyctl := func() error {
 		return nil
-		// Does nothing, but we want it to exist, so we can call it later
-		// even if we fly.ShouldStartFlyMachineApiProxy() == false
 	}
 
 	// Delete the VM if the -f / --files-only flag is not used
 	// This lets you delete the VM from within Fly's and then cleanup Vessel-generated files
 	if !localFiles {
-		// Ensure we can connect to Fly's API
-		if fly.ShouldStartFlyMachineApiProxy() {
-			flyctl, err := fly.FindFlyctlCommandPath()
-
-			if err != nil {
-				logger.GetLogger().Error("command", "init", "msg", "could not find flyctl command", "error", err)
-				PrintIfVerbose(Verbose, err, "You need flyctl installed to make API calls to Fly.io")
-
-				os.Exit(1)
-			}
-
-			if stopFlyctl, err = fly.StartMachineProxy(flyctl); err != nil {
-				logger.GetLogger().Error("command", "init", "msg", "could not run `flyctl machine api-proxy` command", "error", err)
-				PrintIfVerbose(Verbose, err, "Could not make API calls to Fly.io via api-proxy")
-
-				os.Exit(1)
-			}
-
-			defer stopFlyctl()
-		}
+		stopFlyctl = ensureProviderApi("destroy", cfg)
+		defer stopFlyctl()
 
-		err = fly.DeleteApp(auth.Token, cfg.Name)
+		err = provider.Destroy(cfg.Name)
 
 		if err != nil {
-			logger.GetLogger().Error("command", "destroy", "msg", "could not destroy Fly app", "error", err)
-			PrintIfVerbose(Verbose, err, "could not destroy Fly app")
+			logger.GetLogger().Error("command", "destroy", "msg", "could not destroy dev environment", "error", err)
+			PrintIfVerbose(Verbose, err, "could not destroy the dev environment")
 			stopFlyctl()
 
 			os.Exit(1)
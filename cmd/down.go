@@ -0,0 +1,66 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gosimple/slug"
+	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/environments"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+	"github.com/vessel-app/vessel-cli/internal/mutagen"
+)
+
+var downCmd = &cobra.Command{
+	Use:   "down",
+	Short: "Stop the dev environment's machine",
+	Long:  `Stop any development session and stop the dev environment's machine and sidecars right away, instead of waiting for the machine to shut down when idle.`,
+	Run:   runDownCommand,
+}
+
+func init() {
+	downCmd.Flags().StringVarP(&ConfigPath, "config-file", "c", "vessel.yml", "Configuration file to read from")
+	downCmd.Flags().BoolVar(&forceLease, "force", false, "Take over the environment even if a teammate is using it")
+}
+
+// runDownCommand stops Mutagen sessions and then
+// the machine and sidecars of a dev environment
+func runDownCommand(cmd *cobra.Command, args []string) {
+	cfg, err := config.RetrieveProjectConfig(ConfigPath)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "down", "msg", "could not read configuration", "error", err)
+		PrintIfVerbose(Verbose, err, "error reading project configuration file")
+
+		os.Exit(1)
+	}
+
+	provider := environmentProvider("down", cfg)
+
+	// Stop syncing and forwarding before the machine goes away
+	// Note that we ignore errors, there may not be a session running
+	name := slug.Make("vessel-" + cfg.Name)
+	if err = mutagen.StopSession(name); err != nil {
+		logger.GetLogger().Debug("command", "down", "msg", "could not stop development session", "error", err)
+	}
+
+	stopFlyctl := ensureProviderApi("down", cfg)
+	defer stopFlyctl()
+
+	if err = provider.Stop(cfg.Name); err != nil {
+		logger.GetLogger().Error("command", "down", "msg", "could not stop dev environment", "error", err)
+		PrintIfVerbose(Verbose, err, "could not stop the dev environment")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	// Hosts of the ssh provider aren't managed by Vessel, so they keep running
+	if cfg.Provider == environments.SshProvider {
+		fmt.Println("\033[1;32m\xE2\x9C\x94\033[0m Development session stopped, nothing was stopped on the SSH host")
+		return
+	}
+
+	fmt.Println("\033[1;32m\xE2\x9C\x94\033[0m Environment stopped")
+}
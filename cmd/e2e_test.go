@@ -0,0 +1,358 @@
+//go:build flytest
+
+package cmd
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vessel-app/vessel-cli/internal/fly/flytest"
+	"golang.org/x/crypto/ssh"
+)
+
+// TestMain runs Vessel itself when the test binary is started by runVessel, so commands
+// (which exit the process when they fail) can be driven end to end. The tests need the
+// flytest build tag, as the fake Fly API's SSH dialer is only built with it.
+func TestMain(m *testing.M) {
+	if os.Getenv("VESSEL_E2E") != "1" {
+		os.Exit(m.Run())
+	}
+
+	Execute()
+	os.Exit(0)
+}
+
+// e2e is a project using a fake Fly API, with ~/.vessel in a temporary directory
+type e2e struct {
+	fly     *flytest.Server
+	home    string
+	project string
+	ssh     string
+	// env overrides environment variables of vessel commands
+	env []string
+}
+
+func newE2E(t *testing.T) *e2e {
+	t.Helper()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake mutagen binary is a shell script")
+	}
+
+	e := &e2e{
+		fly:     flytest.NewServer(),
+		home:    t.TempDir(),
+		project: t.TempDir(),
+		ssh:     newSshServer(t),
+	}
+
+	e.fly.StateDelay = 10 * time.Millisecond
+	e.fly.IpAddress = "127.0.0.1"
+	t.Cleanup(e.fly.Close)
+
+	writeFile(t, filepath.Join(e.home, ".vessel", "config.yml"), "access_token: token\norg: personal\n", 0600)
+
+	// Mutagen records what it's asked to do, and has no sessions running
+	writeFile(t, filepath.Join(e.home, ".vessel", "bin", "mutagen"), `#!/bin/sh
+echo "$@" >> "$HOME/mutagen.log"
+case "$1 $2" in
+  "sync list"|"forward list") echo '[]' ;;
+esac
+`, 0755)
+
+	return e
+}
+
+// runVessel runs a vessel command within the project, returning its output
+func (e *e2e) runVessel(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	urls := e.fly.Urls()
+
+	cmd := exec.CommandContext(ctx, os.Args[0], args...)
+	cmd.Dir = e.project
+	cmd.Env = append(os.Environ(),
+		"VESSEL_E2E=1",
+		// Environments are reached at the test's SSH server, see ssh_dial_flytest.go
+		"VESSEL_FAKE_SSH="+e.ssh,
+		"HOME="+e.home,
+		"FLY_MACHINES_URL="+urls.Machines,
+		"FLY_GRAPHQL_URL="+urls.GraphQL,
+		"FLY_LOGS_URL="+urls.Logs,
+		"FLY_PROBE_URL="+urls.Probe,
+		"FLY_HOST=",
+		"VESSEL_CATALOG=",
+	)
+	cmd.Env = append(cmd.Env, e.env...)
+
+	output, err := cmd.CombinedOutput()
+
+	return string(output), err
+}
+
+// expectNoState checks no state file is left behind for an environment
+func (e *e2e) expectNoState(t *testing.T, name string) {
+	t.Helper()
+
+	if _, err := os.Stat(filepath.Join(e.home, ".vessel", "envs", name, "state.yml")); !os.IsNotExist(err) {
+		t.Errorf("expected the state of %s to be deleted, got %v", name, err)
+	}
+}
+
+func (e *e2e) exists(path string) bool {
+	_, err := os.Stat(filepath.Join(e.project, path))
+	return err == nil
+}
+
+func TestInitStartDestroy(t *testing.T) {
+	e := newE2E(t)
+
+	output, err := e.runVessel(t, "init", "--name", "e2e", "--image", "acme/php:dev")
+
+	if err != nil {
+		t.Fatalf("init failed: %v\n%s", err, output)
+	}
+
+	if !e.fly.HasApp("e2e") {
+		t.Fatal("expected init to create the Fly app")
+	}
+
+	cfg, err := os.ReadFile(filepath.Join(e.project, "vessel.yml"))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, expected := range []string{"name: e2e", "image: acme/php:dev", "hostname: 127.0.0.1", "- vendor"} {
+		if !strings.Contains(string(cfg), expected) {
+			t.Errorf("expected vessel.yml to contain %q, got:\n%s", expected, cfg)
+		}
+	}
+
+	// The machine's host key is pinned when init first connects to it
+	if knownHosts, err := os.ReadFile(filepath.Join(e.home, ".vessel", "envs", "e2e", "known_hosts")); err != nil || !strings.HasPrefix(string(knownHosts), "127.0.0.1 ssh-ed25519 ") {
+		t.Errorf("expected the host key to be pinned, got %q (%v)", knownHosts, err)
+	}
+
+	// Without a terminal, the ssh config is printed rather than added to ~/.ssh/config
+	if !strings.Contains(output, "StrictHostKeyChecking accept-new") || strings.Contains(output, "/dev/null") {
+		t.Errorf("expected ssh to check the pinned host key, got:\n%s", output)
+	}
+
+	if output, err := e.runVessel(t, "start", "--detach"); err != nil {
+		t.Fatalf("start failed: %v\n%s", err, output)
+	}
+
+	mutagen, _ := os.ReadFile(filepath.Join(e.home, "mutagen.log"))
+
+	if !strings.Contains(string(mutagen), "sync create --ignore-vcs --name vessel-e2e") {
+		t.Errorf("expected start to sync the project, mutagen ran:\n%s", mutagen)
+	}
+
+	if output, err := e.runVessel(t, "destroy", "--quit"); err != nil {
+		t.Fatalf("destroy failed: %v\n%s", err, output)
+	}
+
+	if e.fly.HasApp("e2e") {
+		t.Error("expected destroy to delete the Fly app")
+	}
+
+	if e.exists("vessel.yml") {
+		t.Error("expected destroy to delete vessel.yml")
+	}
+}
+
+func TestInitCleansUpWhenMachineCreationFails(t *testing.T) {
+	e := newE2E(t)
+	e.fly.Inject(flytest.Fault{Method: "POST", Path: "/v1/apps/*/machines", Status: 503, Times: 1})
+
+	if output, err := e.runVessel(t, "init", "--name", "e2e-fault", "--image", "acme/php:dev"); err == nil {
+		t.Fatalf("expected init to fail, got:\n%s", output)
+	}
+
+	if e.fly.HasApp("e2e-fault") || !slices.Contains(e.fly.Requests(), "DELETE /v1/apps/e2e-fault") {
+		t.Errorf("expected the Fly app to be deleted after the failure, got %v", e.fly.Requests())
+	}
+
+	if e.exists("vessel.yml") {
+		t.Error("expected no vessel.yml to be written")
+	}
+}
+
+func TestInitCleansUpWhenWireGuardPeerFails(t *testing.T) {
+	e := newE2E(t)
+	e.fly.Inject(flytest.Fault{Query: "addWireGuardPeer", GraphQLError: "could not add peer", Times: 1})
+
+	if output, err := e.runVessel(t, "init", "--name", "e2e-peer", "--image", "acme/php:dev", "--wireguard"); err == nil {
+		t.Fatalf("expected init to fail, got:\n%s", output)
+	}
+
+	if e.fly.HasApp("e2e-peer") {
+		t.Errorf("expected the Fly app to be deleted after the failure, got %v", e.fly.Requests())
+	}
+
+	e.expectNoState(t, "e2e-peer")
+}
+
+func TestInitCleansUpWhenWaitingForMachineFails(t *testing.T) {
+	e := newE2E(t)
+	e.fly.Inject(flytest.Fault{Method: "GET", Path: "/v1/apps/e2e-wait/machines/*", Status: 500})
+
+	if output, err := e.runVessel(t, "init", "--name", "e2e-wait", "--image", "acme/php:dev", "--wireguard"); err == nil {
+		t.Fatalf("expected init to fail, got:\n%s", output)
+	}
+
+	if e.fly.HasApp("e2e-wait") {
+		t.Errorf("expected the Fly app to be deleted after the failure, got %v", e.fly.Requests())
+	}
+
+	if e.fly.HasWireGuardPeer("vessel-e2e-wait") {
+		t.Error("expected the WireGuard peer to be removed after the failure")
+	}
+
+	e.expectNoState(t, "e2e-wait")
+
+	if e.exists("vessel.yml") {
+		t.Error("expected no vessel.yml to be written")
+	}
+}
+
+func TestDestroyKeepsFilesWhenFlyFails(t *testing.T) {
+	e := newE2E(t)
+
+	if output, err := e.runVessel(t, "init", "--name", "e2e-destroy", "--image", "acme/php:dev"); err != nil {
+		t.Fatalf("init failed: %v\n%s", err, output)
+	}
+
+	e.fly.Inject(flytest.Fault{Method: "DELETE", Path: "/v1/apps/e2e-destroy", Status: 500, Times: 1})
+
+	if output, err := e.runVessel(t, "destroy", "--quit"); err == nil {
+		t.Fatalf("expected destroy to fail, got:\n%s", output)
+	}
+
+	// Files are kept so destroy can be run again
+	if !e.exists("vessel.yml") {
+		t.Fatal("expected vessel.yml to be kept")
+	}
+
+	if output, err := e.runVessel(t, "destroy", "--quit"); err != nil {
+		t.Fatalf("destroy failed when run again: %v\n%s", err, output)
+	}
+
+	if e.fly.HasApp("e2e-destroy") {
+		t.Error("expected destroy to delete the Fly app")
+	}
+}
+
+func TestSshProviderDoesNotNeedFly(t *testing.T) {
+	e := newE2E(t)
+
+	// Fly's API would be reached through flyctl's proxy, but flyctl isn't installed
+	e.env = []string{"FLY_MACHINES_URL=http://127.0.0.1:4280", "PATH=" + t.TempDir()}
+
+	host, port, _ := net.SplitHostPort(e.ssh)
+	writeFile(t, filepath.Join(e.project, "vessel.yml"), `name: e2e-ssh
+provider: ssh
+remote:
+  hostname: `+host+`
+  user: vessel
+  identityfile: `+filepath.Join(e.home, "id_vessel")+`
+  port: `+port+`
+  path: /srv/app
+forwarding:
+  - 8000:80
+`, 0644)
+
+	if output, err := e.runVessel(t, "down"); err != nil {
+		t.Fatalf("expected down to work without Fly, got: %v\n%s", err, output)
+	}
+
+	if len(e.fly.Requests()) > 0 {
+		t.Errorf("expected no Fly API requests, got %v", e.fly.Requests())
+	}
+}
+
+// newSshServer accepts SSH connections with any key, standing in for environments' SSH servers
+func newSshServer(t *testing.T) string {
+	t.Helper()
+
+	_, key, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := ssh.NewSignerFromKey(key)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			return nil, nil
+		},
+	}
+	config.AddHostKey(signer)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+
+				_, channels, requests, err := ssh.NewServerConn(conn, config)
+
+				if err != nil {
+					return
+				}
+
+				go ssh.DiscardRequests(requests)
+
+				for ch := range channels {
+					ch.Reject(ssh.Prohibited, "commands aren't supported")
+				}
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func writeFile(t *testing.T, path, contents string, perm os.FileMode) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(contents), perm); err != nil {
+		t.Fatal(err)
+	}
+}
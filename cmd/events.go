@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/environments"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+)
+
+var eventsCmd = &cobra.Command{
+	Use:   "events",
+	Short: "Show the dev environment's machine events",
+	Long: `Show a timeline of the dev environment's machine lifecycle events (starts, stops, exits, restarts),
+including exit codes, OOM kills and who triggered each event. Use --sidecar to show a sidecar's events instead.`,
+	Run: runEventsCommand,
+}
+
+var eventsJson bool
+var eventsSince string
+var eventsSidecar string
+
+func init() {
+	eventsCmd.Flags().StringVarP(&ConfigPath, "config-file", "c", "vessel.yml", "Configuration file to read from")
+	eventsCmd.Flags().BoolVar(&eventsJson, "json", false, "Output the events as JSON")
+	eventsCmd.Flags().StringVar(&eventsSince, "since", "", "Only show events after a duration ago (e.g. 2h) or a time (RFC 3339)")
+	eventsCmd.Flags().StringVar(&eventsSidecar, "sidecar", "", "Show the events of a sidecar")
+}
+
+type eventEntry struct {
+	Time          time.Time `json:"time"`
+	Type          string    `json:"type"`
+	Status        string    `json:"status"`
+	Source        string    `json:"source"`
+	ExitCode      *int      `json:"exit_code,omitempty"`
+	OomKilled     bool      `json:"oom_killed,omitempty"`
+	RequestedStop bool      `json:"requested_stop,omitempty"`
+	Restarting    bool      `json:"restarting,omitempty"`
+	RestartCount  int       `json:"restart_count,omitempty"`
+}
+
+func runEventsCommand(cmd *cobra.Command, args []string) {
+	cfg, err := config.RetrieveProjectConfig(ConfigPath)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "events", "msg", "could not read configuration", "error", err)
+		PrintIfVerbose(Verbose, err, "error reading project configuration file")
+
+		os.Exit(1)
+	}
+
+	requireFlyProvider("events", cfg)
+
+	since, err := parseSince(eventsSince, time.Now())
+
+	if err != nil {
+		logger.GetLogger().Error("command", "events", "msg", "invalid --since value", "error", err)
+		fmt.Println(err)
+
+		os.Exit(1)
+	}
+
+	auth, err := config.RetrieveVesselConfig()
+
+	if err != nil {
+		logger.GetLogger().Error("command", "events", "msg", "could not get Fly API token from vessel config", "error", err)
+		PrintIfVerbose(Verbose, err, "error retrieving Fly API token")
+
+		os.Exit(1)
+	}
+
+	stopFlyctl := ensureFlyApi("events")
+	defer stopFlyctl()
+
+	var machineId string
+	if len(eventsSidecar) > 0 {
+		sidecars, err := environments.SidecarIds(cfg.Name)
+
+		if err == nil && len(sidecars[eventsSidecar]) == 0 {
+			err = fmt.Errorf("sidecar '%s' has not been created", eventsSidecar)
+		}
+
+		if err != nil {
+			logger.GetLogger().Error("command", "events", "msg", "could not find sidecar machine", "error", err)
+			PrintIfVerbose(Verbose, err, "could not find the sidecar's machine")
+			stopFlyctl()
+
+			os.Exit(1)
+		}
+
+		machineId = sidecars[eventsSidecar]
+	} else if machineId, err = environments.MachineId(auth.Token, cfg.Name); err != nil {
+		logger.GetLogger().Error("command", "events", "msg", "could not find dev environment machine", "error", err)
+		PrintIfVerbose(Verbose, err, "could not find the dev environment's machine")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	machine, err := fly.GetMachine(auth.Token, cfg.Name, machineId)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "events", "msg", "could not get machine", "error", err)
+		PrintIfVerbose(Verbose, err, "could not get the dev environment's machine")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	events := eventTimeline(machine.Events, since)
+
+	if eventsJson {
+		output, err := json.MarshalIndent(events, "", "  ")
+
+		if err != nil {
+			logger.GetLogger().Error("command", "events", "msg", "could not marshal events", "error", err)
+			PrintIfVerbose(Verbose, err, "could not output events")
+			stopFlyctl()
+
+			os.Exit(1)
+		}
+
+		fmt.Println(string(output))
+		return
+	}
+
+	if len(events) == 0 {
+		fmt.Println("No events found")
+		return
+	}
+
+	for _, e := range events {
+		fmt.Println(describeEvent(e))
+	}
+}
+
+// parseSince parses a --since value, either a duration before now or an RFC 3339 time.
+// An empty value returns the zero time, so all events are shown.
+func parseSince(since string, now time.Time) (time.Time, error) {
+	if len(since) == 0 {
+		return time.Time{}, nil
+	}
+
+	if d, err := time.ParseDuration(since); err == nil {
+		return now.Add(-d), nil
+	}
+
+	t, err := time.Parse(time.RFC3339, since)
+
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid --since '%s', expected a duration (e.g. 2h) or a time (e.g. 2022-09-01T15:04:05Z)", since)
+	}
+
+	return t, nil
+}
+
+// eventTimeline orders events oldest first, leaving out events before since
+func eventTimeline(events []fly.MachineEvent, since time.Time) []eventEntry {
+	entries := make([]eventEntry, 0, len(events))
+
+	for i := range events {
+		e := &events[i]
+
+		if e.Time().Before(since) {
+			continue
+		}
+
+		entry := eventEntry{
+			Time:         e.Time(),
+			Type:         e.Type,
+			Status:       e.Status,
+			Source:       e.Source,
+			RestartCount: e.RestartCount(),
+		}
+
+		if exit := e.Exit(); exit != nil {
+			exitCode := exit.ExitCode
+			entry.ExitCode = &exitCode
+			entry.OomKilled = exit.OomKilled
+			entry.RequestedStop = exit.RequestedStop
+			entry.Restarting = exit.Restarting
+		}
+
+		entries = append(entries, entry)
+	}
+
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+
+	return entries
+}
+
+func describeEvent(e eventEntry) string {
+	line := fmt.Sprintf("%s  %-8s %-10s by %s", e.Time.Local().Format("2006-01-02 15:04:05"), e.Type, e.Status, e.Source)
+
+	details := make([]string, 0)
+
+	if e.ExitCode != nil {
+		details = append(details, fmt.Sprintf("exit code %d", *e.ExitCode))
+	}
+
+	if e.OomKilled {
+		details = append(details, "\033[0;31mkilled: out of memory\033[0m")
+	}
+
+	if e.RequestedStop {
+		details = append(details, "stop requested")
+	}
+
+	if e.Restarting {
+		details = append(details, "restarting")
+	}
+
+	if e.RestartCount > 0 {
+		details = append(details, fmt.Sprintf("restart #%d", e.RestartCount))
+	}
+
+	if len(details) > 0 {
+		line += " (" + strings.Join(details, ", ") + ")"
+	}
+
+	return line
+}
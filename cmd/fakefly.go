@@ -0,0 +1,65 @@
+//go:build flytest
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/fly/flytest"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+)
+
+var fakeFlyCmd = &cobra.Command{
+	Use:    "fake-fly",
+	Short:  "Run a fake Fly API for offline testing",
+	Hidden: true,
+	Long: `Run a fake Fly API until ctrl+c is pressed, so Vessel can be used offline (e.g. in CI).
+Export the printed environment variables to point Vessel at it. Faults can be injected by
+POSTing JSON to /_flytest/faults, e.g. {"method":"POST","path":"/v1/apps/*/machines","status":503,"times":1}.`,
+	Run: runFakeFlyCommand,
+}
+
+var fakeFlyListen string
+var fakeFlyStateDelay time.Duration
+var fakeFlyIp string
+
+// fake-fly is only built with the flytest build tag, keeping the fake out of release builds
+func init() {
+	rootCmd.AddCommand(fakeFlyCmd)
+
+	fakeFlyCmd.Flags().StringVar(&fakeFlyListen, "listen", "127.0.0.1:4281", "Address to listen on")
+	fakeFlyCmd.Flags().DurationVar(&fakeFlyStateDelay, "state-delay", flytest.DefaultStateDelay, "How long machines take to move between states")
+	fakeFlyCmd.Flags().StringVar(&fakeFlyIp, "ip", "", "Address given to every allocated IP, e.g. to use a local SSH server")
+}
+
+func runFakeFlyCommand(cmd *cobra.Command, args []string) {
+	server, err := flytest.Listen(fakeFlyListen)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "fake-fly", "msg", "could not start fake fly api", "error", err)
+		PrintIfVerbose(Verbose, err, "could not start the fake Fly API")
+
+		os.Exit(1)
+	}
+
+	defer server.Close()
+
+	server.StateDelay = fakeFlyStateDelay
+	server.IpAddress = fakeFlyIp
+
+	urls := server.Urls()
+	fmt.Printf("export FLY_MACHINES_URL=%s\n", urls.Machines)
+	fmt.Printf("export FLY_GRAPHQL_URL=%s\n", urls.GraphQL)
+	fmt.Printf("export FLY_LOGS_URL=%s\n", urls.Logs)
+	fmt.Printf("export FLY_PROBE_URL=%s\n", urls.Probe)
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	<-ctx.Done()
+}
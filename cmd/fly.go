@@ -0,0 +1,83 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gernest/wow"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+	"github.com/vessel-app/vessel-cli/internal/wait"
+)
+
+// forceLease is set by the --force flag of commands changing machines. It takes over
+// machine leases held by teammates, instead of failing.
+var forceLease bool
+
+// leaseOptions are passed to calls changing machines, taking over leases if --force was given
+func leaseOptions() fly.LeaseOptions {
+	return fly.LeaseOptions{Force: forceLease}
+}
+
+// ensureFlyApi starts `fly machine api-proxy` if the Machines API is set to go through the
+// local proxy, and it isn't running yet. By default the public Machines API is used.
+// It returns a function that stops the proxy, which is safe to call even if the proxy
+// was not started. The process exits if the proxy cannot be started.
+func ensureFlyApi(command string) func() error {
+	stopFlyctl := func() error {
+		return nil
+	}
+
+	if !fly.ShouldStartFlyMachineApiProxy() {
+		return stopFlyctl
+	}
+
+	flyctl, err := fly.FindFlyctlCommandPath()
+
+	if err != nil {
+		logger.GetLogger().Error("command", command, "msg", "could not find flyctl command", "error", err)
+		PrintIfVerbose(Verbose, err, "You need flyctl installed to make API calls to Fly.io")
+
+		os.Exit(1)
+	}
+
+	if stopFlyctl, err = fly.StartMachineProxy(flyctl); err != nil {
+		logger.GetLogger().Error("command", command, "msg", "could not run `flyctl machine api-proxy` command", "error", err)
+		PrintIfVerbose(Verbose, err, "Could not make API calls to Fly.io via api-proxy")
+
+		os.Exit(1)
+	}
+
+	return stopFlyctl
+}
+
+// waitErrorMessage adds the machine's last known state to the message
+// shown when waiting for a machine times out or fails
+func waitErrorMessage(err error, fallback string) string {
+	var timeout *wait.TimeoutError
+	var failed *wait.FailedError
+
+	switch {
+	case errors.As(err, &timeout):
+		return fmt.Sprintf("%s: timed out while the machine was %s", fallback, timeout.LastState)
+	case errors.As(err, &failed):
+		return fmt.Sprintf("%s: the machine is %s", fallback, failed.State)
+	}
+
+	return fallback
+}
+
+// printStateChanges stops the spinner and prints each state a machine passes through. The spinner's
+// text can't be changed while it spins, as the spinner reads it from its own goroutine.
+func printStateChanges(w *wow.Wow, format string) wait.StateFunc {
+	return func(state string) {
+		w.Stop()
+
+		if w.IsTerminal {
+			fmt.Print("\033[2K\r")
+		}
+
+		fmt.Printf(format+"\n", state)
+	}
+}
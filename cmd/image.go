@@ -0,0 +1,213 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/gernest/wow"
+	"github.com/gernest/wow/spin"
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/environments"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+	"github.com/vessel-app/vessel-cli/internal/registry"
+)
+
+var imageCmd = &cobra.Command{
+	Use:   "image",
+	Short: "Manage dev environment images",
+	Long:  `Push local images to Fly.io's registry, and manage credentials for private image registries.`,
+}
+
+var imagePushCmd = &cobra.Command{
+	Use:   "push PATH",
+	Short: "Push a local image to registry.fly.io",
+	Long: `Push an image in OCI layout (a directory, or a tarball such as one created by "docker save")
+to registry.fly.io/<app-name>. Set the pushed image in vessel.yml and run "vessel apply" to use it.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runImagePushCommand,
+}
+
+var imageLoginCmd = &cobra.Command{
+	Use:   "login REGISTRY",
+	Short: "Save credentials for a private registry",
+	Long: `Save credentials for a private registry (e.g. ghcr.io or docker.io) in ~/.vessel/registries.yml.
+The credentials are stored in plain text (readable only by you), so prefer an access token with read-only
+access over your account's password. Use --docker to read the credentials saved by "docker login" instead,
+including those kept in the OS keychain by Docker's credential helpers. Nothing secret is stored by Vessel then.
+Images from the registry are copied into registry.fly.io when creating or updating a dev environment.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runImageLoginCommand,
+}
+
+var imageLogoutCmd = &cobra.Command{
+	Use:   "logout REGISTRY",
+	Short: "Remove saved credentials for a private registry",
+	Args:  cobra.ExactArgs(1),
+	Run:   runImageLogoutCommand,
+}
+
+var imageTag string
+var registryUsername string
+var registryDocker bool
+
+func init() {
+	imagePushCmd.Flags().StringVarP(&ConfigPath, "config-file", "c", "vessel.yml", "Configuration file to read from")
+	imagePushCmd.Flags().StringVarP(&imageTag, "tag", "t", "latest", "Tag to push the image as")
+	imageLoginCmd.Flags().StringVarP(&registryUsername, "username", "u", "", "Registry username")
+	imageLoginCmd.Flags().BoolVar(&registryDocker, "docker", false, "Use the credentials saved by docker login, instead of storing them")
+	imageCmd.AddCommand(imagePushCmd, imageLoginCmd, imageLogoutCmd)
+}
+
+func runImagePushCommand(cmd *cobra.Command, args []string) {
+	cfg, err := config.RetrieveProjectConfig(ConfigPath)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "image push", "msg", "could not read configuration", "error", err)
+		PrintIfVerbose(Verbose, err, "error reading project configuration file")
+
+		os.Exit(1)
+	}
+
+	auth, err := config.RetrieveVesselConfig()
+
+	if err != nil {
+		logger.GetLogger().Error("command", "image push", "msg", "could not get Fly API token from vessel config", "error", err)
+		PrintIfVerbose(Verbose, err, "error retrieving Fly API token")
+
+		os.Exit(1)
+	}
+
+	target := registry.Reference{
+		Registry:   environments.FlyRegistry,
+		Repository: cfg.Name,
+		Tag:        imageTag,
+	}
+
+	w := wow.New(os.Stdout, spin.Get(spin.Dots), " Pushing image to "+target.String())
+	w.Start()
+
+	if err = registry.PushOCILayout(environments.FlyRegistryClient(auth.Token), args[0], target.Repository, target.Tag); err != nil {
+		logger.GetLogger().Error("command", "image push", "msg", "could not push image", "error", err)
+		PrintIfVerbose(Verbose, err, "could not push image")
+
+		os.Exit(1)
+	}
+
+	w.PersistWith(spin.Spinner{Frames: []string{"\033[1;32m\xE2\x9C\x94\033[0m"}}, " Pushed "+target.String())
+	fmt.Printf("Set `image: %s` in vessel.yml and run `vessel apply` to use it\n", target.String())
+}
+
+func runImageLoginCommand(cmd *cobra.Command, args []string) {
+	host := args[0]
+
+	cred, err := registryLogin(host)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "image login", "msg", "could not read registry credentials", "registry", host, "error", err)
+		PrintIfVerbose(Verbose, err, "could not read credentials for "+host)
+
+		os.Exit(1)
+	}
+
+	login, err := cred.Resolve(host)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "image login", "msg", "could not read docker credentials", "registry", host, "error", err)
+		PrintIfVerbose(Verbose, err, "could not read Docker's credentials for "+host)
+
+		os.Exit(1)
+	}
+
+	// Check the credentials before saving them
+	if err = registry.NewClient(registry.BaseUrl(host), login.Username, login.Password).Ping(); err != nil {
+		logger.GetLogger().Error("command", "image login", "msg", "could not log into registry", "registry", host, "error", err)
+		PrintIfVerbose(Verbose, err, "could not log into "+host)
+
+		os.Exit(1)
+	}
+
+	creds, err := config.RetrieveRegistryCredentials()
+
+	if err != nil {
+		logger.GetLogger().Error("command", "image login", "msg", "could not read registry credentials", "error", err)
+		PrintIfVerbose(Verbose, err, "could not read saved registry credentials")
+
+		os.Exit(1)
+	}
+
+	creds.Registries[host] = *cred
+
+	if err = config.SaveRegistryCredentials(creds); err != nil {
+		logger.GetLogger().Error("command", "image login", "msg", "could not save registry credentials", "error", err)
+		PrintIfVerbose(Verbose, err, "could not save registry credentials")
+
+		os.Exit(1)
+	}
+
+	if cred.Docker {
+		fmt.Printf("\033[1;32m\xE2\x9C\x94\033[0m Using Docker's credentials for %s\n", host)
+		return
+	}
+
+	fmt.Printf("\033[1;32m\xE2\x9C\x94\033[0m Saved credentials for %s\n", host)
+}
+
+// registryLogin asks for a registry's username and password, unless Docker's credentials are used
+func registryLogin(host string) (*config.RegistryCredential, error) {
+	if registryDocker {
+		return &config.RegistryCredential{Docker: true}, nil
+	}
+
+	if len(registryUsername) == 0 {
+		askUsername := promptui.Prompt{
+			Label: "Username",
+		}
+
+		var err error
+		if registryUsername, err = askUsername.Run(); err != nil {
+			// User likely bailed out
+			os.Exit(1)
+		}
+	}
+
+	askPassword := promptui.Prompt{
+		Label: "Password (or access token)",
+		Mask:  '*',
+	}
+
+	password, err := askPassword.Run()
+
+	if err != nil {
+		// User likely bailed out
+		os.Exit(1)
+	}
+
+	return &config.RegistryCredential{
+		Username: registryUsername,
+		Password: password,
+	}, nil
+}
+
+func runImageLogoutCommand(cmd *cobra.Command, args []string) {
+	creds, err := config.RetrieveRegistryCredentials()
+
+	if err != nil {
+		logger.GetLogger().Error("command", "image logout", "msg", "could not read registry credentials", "error", err)
+		PrintIfVerbose(Verbose, err, "could not read saved registry credentials")
+
+		os.Exit(1)
+	}
+
+	delete(creds.Registries, args[0])
+
+	if err = config.SaveRegistryCredentials(creds); err != nil {
+		logger.GetLogger().Error("command", "image logout", "msg", "could not save registry credentials", "error", err)
+		PrintIfVerbose(Verbose, err, "could not save registry credentials")
+
+		os.Exit(1)
+	}
+
+	fmt.Printf("\033[1;32m\xE2\x9C\x94\033[0m Removed credentials for %s\n", args[0])
+}
@@ -1,9 +1,11 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -13,6 +15,7 @@ import (
 	"github.com/kevinburke/ssh_config"
 	"github.com/manifoldco/promptui"
 	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/catalog"
 	"github.com/vessel-app/vessel-cli/internal/config"
 	"github.com/vessel-app/vessel-cli/internal/environments"
 	"github.com/vessel-app/vessel-cli/internal/fly"
@@ -20,22 +23,58 @@ import (
 	"github.com/vessel-app/vessel-cli/internal/mutagen"
 	"github.com/vessel-app/vessel-cli/internal/remote"
 	"github.com/vessel-app/vessel-cli/internal/util"
+	"golang.org/x/exp/slices"
 )
 
 var initCmd = &cobra.Command{
 	Use:   "init",
 	Short: "Create a dev environment.",
 	Long: `Configure and create a remote dev environment.
-Defaults to assigning an IPv6 address. Use the -4 flag to use IPv4 instead.`,
+Defaults to assigning an IPv6 address. Use the -4 flag to use IPv4 instead.
+Use --cpus, --memory and --cpu-kind to size the machine.
+Use --volume name:/path[:size_gb] to persist a directory across restarts.
+Use --env KEY=VALUE to set environment variables in the environment.
+Use --name and --image to skip the app name and image prompts.
+Only SSH is published on the environment's IP address, use --public-http to publish HTTP (ports 80/443) as well.
+Images are offered from Vessel's built-in catalog, plus a team catalog if one is set with
+--catalog, the VESSEL_CATALOG env variable, or "catalog" in ~/.vessel/config.yml.
+Use --host user@host[:port] and --path to use an existing server over SSH instead of Fly.io.
+Use --vessel to create the environment through the hosted Vessel API, set up with vessel auth --vessel.
+Use --wireguard to skip allocating a public IP, reaching the environment through a WireGuard
+tunnel into Fly's private network instead. The tunnel runs within Vessel, without needing root.`,
 	Run: runInitCommand,
 }
 
 var AppName string
 var UseIpv4 bool
+var MachineCpus int
+var MachineMemory int
+var MachineCpuKind string
+var Volumes []string
+var EnvVars []string
+var PublicHttp bool
+var CatalogSource string
+var InitImage string
+var InitHost string
+var InitPath string
+var InitVessel bool
+var InitWireGuard bool
 
 func init() {
 	initCmd.Flags().StringVarP(&AppName, "name", "n", "", "Set the environment name")
 	initCmd.Flags().BoolVarP(&UseIpv4, "ipv4", "4", false, "Allocate an IPv4 instead of IPv6 to the environment")
+	initCmd.Flags().IntVar(&MachineCpus, "cpus", 1, "Number of CPUs for the environment's machine")
+	initCmd.Flags().IntVar(&MachineMemory, "memory", 256, "Memory (in MB) for the environment's machine, in multiples of 256")
+	initCmd.Flags().StringVar(&MachineCpuKind, "cpu-kind", "shared", "Kind of CPU for the environment's machine (shared, performance)")
+	initCmd.Flags().StringArrayVar(&Volumes, "volume", []string{}, "Persistent volume to mount, as name:/path[:size_gb]")
+	initCmd.Flags().StringArrayVarP(&EnvVars, "env", "e", []string{}, "Environment variable to set, as KEY=VALUE")
+	initCmd.Flags().BoolVar(&PublicHttp, "public-http", false, "Publish port 80 within the environment on public ports 80 and 443")
+	initCmd.Flags().StringVar(&CatalogSource, "catalog", "", "Path or URL of a team image catalog")
+	initCmd.Flags().StringVar(&InitImage, "image", "", "Docker image to use, instead of choosing one from the image catalog")
+	initCmd.Flags().StringVar(&InitHost, "host", "", "Use an existing SSH host, as user@host[:port], instead of Fly.io")
+	initCmd.Flags().StringVar(&InitPath, "path", "", "Project path on the SSH host, used with --host")
+	initCmd.Flags().BoolVar(&InitVessel, "vessel", false, "Create the environment through the hosted Vessel API, instead of Fly.io")
+	initCmd.Flags().BoolVar(&InitWireGuard, "wireguard", false, "Reach the environment over a WireGuard tunnel, instead of a public IP")
 }
 
 // runInitCommand will guide users through setting up a new development environment.
@@ -43,14 +82,21 @@ func init() {
 //  1. Retrieves vessel configuration
 //  2. Starts `fly machine api-proxy` if needed
 //  3. Helps create an environment name
-//  4. Prompts for dev env type (PHP, etc)
+//  4. Prompts for dev env type (PHP, etc) from the image catalog
 //  5. Generates env files (SSH keys, etc)
 //  6. Gets user's nearest region
 //  7. Creates the dev environment
 //  8. Generates project and SSH configuration
 //  9. Downloads Mutagen (if needed)
 //  10. Waits for dev env to be available
+//  11. Runs the image's post-create commands
 func runInitCommand(cmd *cobra.Command, args []string) {
+	// Existing SSH hosts don't use Fly at all
+	if len(InitHost) > 0 {
+		runInitHostCommand()
+		return
+	}
+
 	auth, err := config.RetrieveVesselConfig()
 
 	if err != nil {
@@ -60,79 +106,121 @@ func runInitCommand(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	stopFlyctl := func() error {
-		return nil
-		// Does nothing, but we want it to exist, so we can call it later
-		// even if we fly.ShouldStartFlyMachineApiProxy() == false
-	}
+	if InitWireGuard && InitVessel {
+		logger.GetLogger().Error("command", "init", "msg", "wireguard is only supported on Fly")
+		fmt.Println("--wireguard can't be used with --vessel, it needs your own Fly.io organization")
 
-	// Ensure we can connect to Fly's API
-	if fly.ShouldStartFlyMachineApiProxy() {
-		flyctl, err := fly.FindFlyctlCommandPath()
+		os.Exit(1)
+	}
 
-		if err != nil {
-			logger.GetLogger().Error("command", "init", "msg", "could not find flyctl command", "error", err)
-			PrintIfVerbose(Verbose, err, "You need flyctl installed to make API calls to Fly.io")
+	// The Vessel API chooses the environment's image and machine itself
+	if InitVessel {
+		if unsupported := vesselUnsupportedFlags(cmd); len(unsupported) > 0 {
+			logger.GetLogger().Error("command", "init", "msg", "flags not supported by provider", "provider", environments.VesselProvider, "flags", unsupported)
+			fmt.Printf("%s can't be used with --vessel, the Vessel API doesn't support them\n", strings.Join(unsupported, ", "))
 
 			os.Exit(1)
 		}
+	}
 
-		if stopFlyctl, err = fly.StartMachineProxy(flyctl); err != nil {
-			logger.GetLogger().Error("command", "init", "msg", "could not run `flyctl machine api-proxy` command", "error", err)
-			PrintIfVerbose(Verbose, err, "Could not make API calls to Fly.io via api-proxy")
+	if InitWireGuard && PublicHttp {
+		logger.GetLogger().Error("command", "init", "msg", "public http needs a public ip")
+		fmt.Println("--public-http needs a public IP, so it can't be used with --wireguard")
 
-			os.Exit(1)
-		}
+		os.Exit(1)
+	}
 
-		defer stopFlyctl()
+	machineSize := &config.MachineConfig{
+		CpuKind:  MachineCpuKind,
+		Cpus:     MachineCpus,
+		MemoryMb: MachineMemory,
 	}
 
-	// Get/generate application name
-	dir, err := os.Getwd()
+	if valid, err := machineSize.Valid(); !valid {
+		logger.GetLogger().Error("command", "init", "msg", "invalid machine size", "error", err)
+		fmt.Println(err)
 
-	if err != nil {
-		dir = "my-app"
+		os.Exit(1)
 	}
 
-	askAppName := promptui.Prompt{
-		Label:   "App Name",
-		Default: slug.Make(filepath.Base(dir)),
+	volumes, err := parseVolumeFlags(Volumes)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "invalid volume", "error", err)
+		fmt.Println(err)
+
+		os.Exit(1)
 	}
 
-	appName, err := askAppName.Run()
+	envVars, err := parseEnvFlags(EnvVars)
 
 	if err != nil {
-		// No logging, user likely just bailed out
-		logger.GetLogger().Debug("cmd", "init", "msg", "prompt ui failure asking app name", "error", err)
-		stopFlyctl()
+		logger.GetLogger().Error("command", "init", "msg", "invalid env variable", "error", err)
+		fmt.Println(err)
+
 		os.Exit(1)
 	}
 
+	// Ensure we can connect to Fly's API, environments created through Vessel don't use it
+	stopFlyctl := func() error { return nil }
+	if !InitVessel {
+		stopFlyctl = ensureFlyApi("init")
+	}
+	defer stopFlyctl()
+
+	// Get/generate application name, prompting for it unless --name is used
+	appName := AppName
+
+	if len(appName) == 0 {
+		dir, err := os.Getwd()
+
+		if err != nil {
+			dir = "my-app"
+		}
+
+		askAppName := promptui.Prompt{
+			Label:   "App Name",
+			Default: slug.Make(filepath.Base(dir)),
+		}
+
+		if appName, err = askAppName.Run(); err != nil {
+			// No logging, user likely just bailed out
+			logger.GetLogger().Debug("cmd", "init", "msg", "prompt ui failure asking app name", "error", err)
+			stopFlyctl()
+			os.Exit(1)
+		}
+	}
+
 	appName = slug.Make(appName)
 
 	// Get image to use (development environment type)
-	bundledTypes := []string{"vesselapp/php:8.1", "vesselapp/php:8.0", "vesselapp/php:7.4"}
-	typeIndex := -1
 	var envDockerImage string
+	image := catalog.Default(envDockerImage)
 
-	for typeIndex < 0 {
-		typePrompt := promptui.SelectWithAdd{
-			Label:    "What Docker base image should we use?",
-			Items:    bundledTypes,
-			AddLabel: "Other",
-		}
+	if !InitVessel {
+		images, err := imageCatalog(auth)
 
-		typeIndex, envDockerImage, err = typePrompt.Run()
+		if err != nil {
+			logger.GetLogger().Error("command", "init", "msg", "could not load image catalog", "error", err)
+			fmt.Println(err)
+			stopFlyctl()
+			os.Exit(1)
+		}
 
-		if typeIndex == -1 {
-			bundledTypes = append(bundledTypes, envDockerImage)
+		// Prompt for the image unless --image is used
+		envDockerImage = InitImage
+		if len(envDockerImage) == 0 {
+			if envDockerImage, err = selectImage(images); err != nil {
+				logger.GetLogger().Debug("cmd", "init", "msg", "prompt ui failure selecting Docker image", "error", err)
+				stopFlyctl()
+				os.Exit(1)
+			}
 		}
-	}
 
-	if err != nil {
-		logger.GetLogger().Debug("cmd", "init", "msg", "prompt ui failure selecting Docker image", "error", err)
-		stopFlyctl()
-		os.Exit(1)
+		var found bool
+		if image, found = images.Find(envDockerImage); !found {
+			image = catalog.Default(envDockerImage)
+		}
 	}
 
 	// Create ~/.vessel/envs/<app-name>
@@ -146,7 +234,7 @@ func runInitCommand(cmd *cobra.Command, args []string) {
 	}
 
 	// Generate and store SSH keys
-	keys, err := util.GenerateSSHKey()
+	keys, privateKeyPath, err := generateEnvironmentKeys(vesselAppDir)
 
 	if err != nil {
 		logger.GetLogger().Error("command", "init", "msg", "could not generate SSH keys", "error", err)
@@ -155,39 +243,39 @@ func runInitCommand(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
-	privateKeyPath := filepath.FromSlash(vesselAppDir + "/id_ed25519")
-	if err = os.WriteFile(privateKeyPath, keys.Private, 0600); err != nil {
-		logger.GetLogger().Error("command", "init", "msg", "could not store generated SSH private key", "error", err, "file", privateKeyPath)
-		PrintIfVerbose(Verbose, err, "error initializing app")
-		stopFlyctl()
-		os.Exit(1)
-	}
+	// Get user's nearest Fly region. Without Fly credentials (creating the environment through Vessel),
+	// the cached or built-in region list is used and the user picks one.
+	var nearestRegionCode string
+	var region *fly.NearestRegion
+	var availableRegions []fly.Region
 
-	publicKeyPath := filepath.FromSlash(vesselAppDir + "/id_ed25519.pub")
-	if err = os.WriteFile(publicKeyPath, keys.Public, 0644); err != nil {
-		logger.GetLogger().Error("command", "init", "msg", "could not store generated SSH public key", "error", err, "file", publicKeyPath)
-		PrintIfVerbose(Verbose, err, "error initializing app")
-		stopFlyctl()
-		os.Exit(1)
+	if InitVessel {
+		availableRegions = fly.AvailableRegions("")
+		err = fmt.Errorf("nearest region is only found through Fly's API")
+	} else {
+		availableRegions = fly.AvailableRegions(auth.Token)
+		region, err = fly.GetNearestRegion(auth.Token)
 	}
 
-	// Get user's nearest Fly region
-	var nearestRegionCode string
-	region, err := fly.GetNearestRegion(auth.Token)
+	if err == nil && slices.IndexFunc(availableRegions, func(r fly.Region) bool { return r.Code == region.NearestRegion.Code }) < 0 {
+		err = fmt.Errorf("nearest region %s is not available for machines", region.NearestRegion.Code)
+	}
 
 	if err != nil {
 		logger.GetLogger().Debug("cmd", "init", "msg", "could not automatically find nearest region", "error", err)
 
-		// If we can't get the nearest region, have them select a region
+		// If we can't get the nearest region, have them select a region, fastest first
+		regions := probeRegions(availableRegions)
+
 		selectRegion := promptui.Select{
-			Label: "Which region is closest to you?",
-			Items: fly.Regions,
+			Label: "Which region should we use? (fastest first)",
+			Items: regions,
 			Templates: &promptui.SelectTemplates{
-				Active:   fmt.Sprintf("%s {{ .Code | underline }}{{ `-` | underline }}{{ .Name | underline }}", promptui.IconSelect),
-				Inactive: "  {{ .Code }} - {{ .Name }}",
+				Active:   fmt.Sprintf("%s {{ .Code | underline }}{{ `-` | underline }}{{ .Name | underline }} {{ .Latency | faint }}", promptui.IconSelect),
+				Inactive: "  {{ .Code }} - {{ .Name }} {{ .Latency | faint }}",
 				Selected: fmt.Sprintf(`{{ "%s" | green }} {{ .Code| faint }}{{ "-" | faint }}{{ .Name | faint }}`, promptui.IconGood),
 			},
-			Size: len(fly.Regions),
+			Size: len(regions),
 		}
 
 		idx, _, err := selectRegion.Run()
@@ -199,128 +287,142 @@ func runInitCommand(cmd *cobra.Command, args []string) {
 			os.Exit(1)
 		}
 
-		nearestRegionCode = fly.Regions[idx].Code
+		nearestRegionCode = regions[idx].Code
 	} else {
 		nearestRegionCode = region.NearestRegion.Code
 	}
 
 	// Create dev environment
-	env, err := environments.CreateEnvironment(auth.Token, appName, envDockerImage, auth.Org, nearestRegionCode, string(keys.Public), !UseIpv4)
+	providerName := environments.FlyProvider
+	if InitVessel {
+		providerName = environments.VesselProvider
+	}
+
+	project := &config.EnvironmentConfig{
+		Name:     appName,
+		Provider: providerName,
+	}
+
+	if !InitVessel {
+		project.Image = envDockerImage
+		project.Machine = *machineSize
+		project.Volumes = volumes
+		project.Env = envVars
+		project.Services = initServices(PublicHttp)
+	}
+
+	provider, err := environments.NewProvider(project, auth, fly.LeaseOptions{})
 
 	if err != nil {
-		logger.GetLogger().Debug("cmd", "init", "msg", "could not create dev environment", "error", err)
+		logger.GetLogger().Error("command", "init", "msg", "could not create environment provider", "error", err)
 		PrintIfVerbose(Verbose, err, "error initializing app")
 		stopFlyctl()
 		os.Exit(1)
 	}
 
-	w := wow.New(os.Stdout, spin.Get(spin.Dots), " Environment registered, waiting for it to start")
+	w := wow.New(os.Stdout, spin.Get(spin.Dots), " Creating environment")
 	w.Start()
 
-	err = fly.WaitForMachine(auth.Token, appName, env.FlyMachine)
+	ctx, cancel := context.WithTimeout(context.Background(), fly.DefaultWaitTimeout)
+	env, err := provider.Create(ctx, &environments.CreateOptions{
+		Project:   project,
+		Region:    nearestRegionCode,
+		PublicKey: string(keys.Public),
+		Ipv6:      !UseIpv4,
+		WireGuard: InitWireGuard,
+	}, printStateChanges(w, "  Environment registered, machine is %s"))
+	cancel()
+
 	if err != nil {
-		logger.GetLogger().Debug("cmd", "init", "msg", "could not get dev environment status", "error", err)
-		PrintIfVerbose(Verbose, err, "error creating dev environment")
+		logger.GetLogger().Debug("cmd", "init", "msg", "could not create dev environment", "error", err)
+		PrintIfVerbose(Verbose, err, waitErrorMessage(err, "error creating dev environment"))
 		stopFlyctl()
 		os.Exit(1)
 	}
 
 	w.PersistWith(spin.Spinner{Frames: []string{"\033[1;32m\xE2\x9C\x94\033[0m"}}, " Environment ready!")
 
-	// Ask if we can add to ~/.ssh/config (if alias is not present)
-	canAddSSHAlias := promptui.Prompt{
-		Label:     "Can we add a Host entry to your ~/.ssh/config file ('N' will output to terminal instead)",
-		IsConfirm: true,
+	// The machine's host key is pinned on first use, in a known_hosts file shared with Vessel's own connections
+	knownHostsPath, err := config.EnvironmentKnownHostsPath(appName)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "could not find known_hosts path", "error", err)
+		PrintIfVerbose(Verbose, err, "error initializing app")
+		stopFlyctl()
+		os.Exit(1)
+	}
+
+	// Match the address family of the IP allocated to the environment
+	addressFamily := "inet6"
+
+	if UseIpv4 {
+		addressFamily = "inet"
 	}
 
 	sshConfig := fmt.Sprintf(`
 Host vessel-%s
     HostName %s
-    User vessel
+    User %s
     IdentityFile %s
     IdentitiesOnly yes
-    AddressFamily inet6
-    UserKnownHostsFile /dev/null
-    StrictHostKeyChecking no
-`, appName, env.FlyIp, privateKeyPath)
+    AddressFamily %s
+    UserKnownHostsFile "%s"
+    StrictHostKeyChecking accept-new
+`, appName, env.Host, image.User, privateKeyPath, addressFamily, knownHostsPath)
 
-	_, err = canAddSSHAlias.Run()
+	sshPort := 22
 
-	if err != nil {
-		fmt.Println("Here is what we would have added to ~/.ssh/config:")
-		fmt.Println(sshConfig)
-	} else {
-		// Only write if host doesn't exist yet
-		hostAlreadyExists := ssh_config.Get(appName, "HostName")
-		if len(hostAlreadyExists) == 0 {
-			if err = util.WriteToSshConfig(sshConfig); err != nil {
-				logger.GetLogger().Error("command", "init", "msg", "could not write to SSH config to ~/.ssh/config", "error", err)
-				PrintIfVerbose(Verbose, err, "error initializing app")
-				stopFlyctl()
-				os.Exit(1)
-			}
-		} else {
-			fmt.Printf("Warning: ~/.ssh/config file already contained Host %s", appName)
-		}
-	}
-
-	// TODO: Abstract so selected image has meta data
-	//       such as ignores, forwards, startup commands
-	var ignores string
-	if strings.Contains(envDockerImage, "php") {
-		ignores = `ignore:
-  - vendor
-  - node_modules`
+	// ssh (and Mutagen) reach the machine's SSH server directly, through the WireGuard tunnel
+	if InitWireGuard {
+		sshPort = privateSshPort
+		sshConfig = fmt.Sprintf(`
+Host vessel-%s
+    HostName %s
+    User %s
+    Port %d
+    IdentityFile %s
+    IdentitiesOnly yes
+    UserKnownHostsFile "%s"
+    StrictHostKeyChecking accept-new
+    ProxyCommand "%s" tunnel %s %%h %%p
+`, appName, env.Host, image.User, sshPort, privateKeyPath, knownHostsPath, vesselExecutable(), appName)
 	}
 
-	// Generate project configuration file
-	yaml := fmt.Sprintf(`name: %s
-
-remote:
-  hostname: %s
-  user: vessel
-  identityfile: %s
-  port: 22
-  path: /home/vessel/app
-  alias: vessel-%s
-
-forwarding:
-  - 8000:80
-
-%s
-`, appName, env.FlyIp, privateKeyPath, appName, ignores)
-
-	if err = os.WriteFile("vessel.yml", []byte(yaml), 0755); err != nil {
-		logger.GetLogger().Error("command", "init", "msg", "could not write yaml file to current directory", "error", err)
+	if err = addSshAlias(appName, sshConfig); err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "could not write to SSH config to ~/.ssh/config", "error", err)
 		PrintIfVerbose(Verbose, err, "error initializing app")
 		stopFlyctl()
 		os.Exit(1)
 	}
 
-	// Ensure Mutagen is installed
-	w2 := wow.New(os.Stdout, spin.Get(spin.Dots), " Configuring Mutagen")
-	w2.Start()
-
-	_, err = util.MakeBinDir()
+	// Generate project configuration file
+	project.Remote = config.RemoteConfig{
+		Hostname:     env.Host,
+		User:         image.User,
+		IdentityFile: privateKeyPath,
+		Port:         sshPort,
+		RemotePath:   image.Path,
+		Alias:        "vessel-" + appName,
+		WireGuard:    InitWireGuard,
+	}
+	project.Forwarding = image.Forwarding
+	project.Ignore = image.Ignore
 
-	if err != nil {
-		logger.GetLogger().Error("command", "init", "msg", "could not create ~/.vessel/bin directory", "error", err)
+	if err = config.WriteProjectConfig("vessel.yml", project); err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "could not write yaml file to current directory", "error", err)
 		PrintIfVerbose(Verbose, err, "error initializing app")
 		stopFlyctl()
 		os.Exit(1)
 	}
 
-	err = mutagen.InstallMutagen()
-
-	if err != nil {
+	// Ensure Mutagen is installed
+	if err = ensureMutagen(); err != nil {
 		logger.GetLogger().Error("command", "init", "msg", "could not install mutagen to ~/.vessel/bin/mutagen", "error", err)
 		PrintIfVerbose(Verbose, err, "error initializing app")
 		stopFlyctl()
 		os.Exit(1)
 	}
 
-	w2.PersistWith(spin.Spinner{Frames: []string{"\033[1;32m\xE2\x9C\x94\033[0m"}}, " Mutagen is ready")
-
 	// Wait for SSH to become available
 	w3 := wow.New(os.Stdout, spin.Get(spin.Dots), " Waiting for environment to become reachable")
 	w3.Start()
@@ -334,7 +436,17 @@ forwarding:
 		os.Exit(1)
 	}
 
-	connection := remote.NewConnection(&cfg.Remote)
+	connection, closeTunnel, err := remoteConnection(cfg)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "init", "error", err)
+		PrintIfVerbose(Verbose, err, "could not connect to dev environment")
+		stopFlyctl()
+		os.Exit(1)
+	}
+
+	defer closeTunnel()
+
 	if err := waitForConnection(connection); err != nil {
 		logger.GetLogger().Error("command", "init", "error", err)
 		PrintIfVerbose(Verbose, err, "could not connect to dev environment")
@@ -344,9 +456,262 @@ forwarding:
 
 	w3.PersistWith(spin.Spinner{Frames: []string{"\033[1;32m\xE2\x9C\x94\033[0m"}}, " Environment is reachable")
 
+	// Run the image's post-create commands within the project path
+	if len(image.PostCreate) > 0 {
+		if err := connection.Run("mkdir -p " + remote.ShellQuote(cfg.Remote.RemotePath)); err != nil {
+			logger.GetLogger().Error("command", "init", "msg", "could not create remote project path", "error", err)
+			PrintIfVerbose(Verbose, err, "could not run post-create commands")
+			stopFlyctl()
+			os.Exit(1)
+		}
+
+		for _, postCreate := range image.PostCreate {
+			fmt.Printf("Running: %s\n", postCreate)
+
+			if err := connection.Cmd(postCreate); err != nil {
+				logger.GetLogger().Error("command", "init", "msg", "post-create command failed", "command", postCreate, "error", err)
+				PrintIfVerbose(Verbose, err, "post-create command failed: "+postCreate)
+				stopFlyctl()
+				os.Exit(1)
+			}
+		}
+	}
+
 	fmt.Println("You're good to go! Run `vessel start` to begin developing!")
 }
 
+// addSshAlias asks if we can add a Host entry to ~/.ssh/config, outputting it to the terminal
+// instead if not. The entry is only written if the host isn't in ~/.ssh/config yet.
+func addSshAlias(appName, sshConfig string) error {
+	canAddSSHAlias := promptui.Prompt{
+		Label:     "Can we add a Host entry to your ~/.ssh/config file ('N' will output to terminal instead)",
+		IsConfirm: true,
+	}
+
+	if _, err := canAddSSHAlias.Run(); err != nil {
+		fmt.Println("Here is what we would have added to ~/.ssh/config:")
+		fmt.Println(sshConfig)
+
+		return nil
+	}
+
+	hostAlreadyExists := ssh_config.Get(appName, "HostName")
+	if len(hostAlreadyExists) > 0 {
+		fmt.Printf("Warning: ~/.ssh/config file already contained Host %s", appName)
+
+		return nil
+	}
+
+	return util.WriteToSshConfig(sshConfig)
+}
+
+// ensureMutagen installs Mutagen to ~/.vessel/bin, if it isn't installed yet
+func ensureMutagen() error {
+	w := wow.New(os.Stdout, spin.Get(spin.Dots), " Configuring Mutagen")
+	w.Start()
+
+	if _, err := util.MakeBinDir(); err != nil {
+		w.Stop()
+		return fmt.Errorf("could not create ~/.vessel/bin directory: %w", err)
+	}
+
+	if err := mutagen.InstallMutagen(); err != nil {
+		w.Stop()
+		return err
+	}
+
+	w.PersistWith(spin.Spinner{Frames: []string{"\033[1;32m\xE2\x9C\x94\033[0m"}}, " Mutagen is ready")
+
+	return nil
+}
+
+// generateEnvironmentKeys generates the SSH keys of an environment, storing them in its
+// ~/.vessel/envs/<app-name> directory. It returns the keys and the private key's path.
+func generateEnvironmentKeys(vesselAppDir string) (*util.Keys, string, error) {
+	keys, err := util.GenerateSSHKey()
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	privateKeyPath := filepath.FromSlash(vesselAppDir + "/id_ed25519")
+	if err = os.WriteFile(privateKeyPath, keys.Private, 0600); err != nil {
+		return nil, "", fmt.Errorf("could not store generated SSH private key: %w", err)
+	}
+
+	publicKeyPath := filepath.FromSlash(vesselAppDir + "/id_ed25519.pub")
+	if err = os.WriteFile(publicKeyPath, keys.Public, 0644); err != nil {
+		return nil, "", fmt.Errorf("could not store generated SSH public key: %w", err)
+	}
+
+	return keys, privateKeyPath, nil
+}
+
+// imageCatalog combines the team image catalog, if any, with the built-in catalog.
+// The --catalog flag takes precedence over VESSEL_CATALOG and ~/.vessel/config.yml.
+func imageCatalog(auth *config.AuthConfig) (*catalog.Catalog, error) {
+	source := CatalogSource
+
+	if len(source) == 0 {
+		source = os.Getenv("VESSEL_CATALOG")
+	}
+
+	if len(source) == 0 {
+		source = auth.Catalog
+	}
+
+	if len(source) == 0 {
+		return catalog.BuiltIn, nil
+	}
+
+	team, err := catalog.Load(source)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return catalog.Merge(team, catalog.BuiltIn), nil
+}
+
+// selectImage asks which image to use, from the catalog or any other image
+func selectImage(images *catalog.Catalog) (string, error) {
+	bundledTypes := images.Names()
+	typeIndex := -1
+	var envDockerImage string
+	var err error
+
+	for typeIndex < 0 {
+		typePrompt := promptui.SelectWithAdd{
+			Label:    "What Docker base image should we use?",
+			Items:    bundledTypes,
+			AddLabel: "Other",
+		}
+
+		typeIndex, envDockerImage, err = typePrompt.Run()
+
+		if typeIndex == -1 {
+			bundledTypes = append(bundledTypes, envDockerImage)
+		}
+	}
+
+	return envDockerImage, err
+}
+
+// vesselUnsupportedFlags lists the flags given to init that environments created through Vessel
+// don't support, as the Vessel API chooses their image and machine itself
+func vesselUnsupportedFlags(cmd *cobra.Command) []string {
+	unsupported := make([]string, 0)
+
+	for _, name := range []string{"image", "cpus", "memory", "cpu-kind", "volume", "env", "public-http", "catalog"} {
+		if cmd.Flags().Changed(name) {
+			unsupported = append(unsupported, "--"+name)
+		}
+	}
+
+	return unsupported
+}
+
+// parseVolumeFlags parses --volume flags in the form of name:/path[:size_gb]
+func parseVolumeFlags(flags []string) ([]config.VolumeConfig, error) {
+	volumes := make([]config.VolumeConfig, 0, len(flags))
+
+	for _, f := range flags {
+		parts := strings.Split(f, ":")
+
+		if len(parts) < 2 || len(parts) > 3 {
+			return nil, fmt.Errorf("invalid volume '%s', expected name:/path[:size_gb]", f)
+		}
+
+		v := config.VolumeConfig{
+			Name: parts[0],
+			Path: parts[1],
+		}
+
+		if len(parts) == 3 {
+			size, err := strconv.Atoi(parts[2])
+
+			if err != nil {
+				return nil, fmt.Errorf("invalid volume size '%s': %w", parts[2], err)
+			}
+
+			v.SizeGb = size
+		}
+
+		if valid, err := v.Valid(); !valid {
+			return nil, err
+		}
+
+		volumes = append(volumes, v)
+	}
+
+	if len(volumes) > 1 {
+		return nil, fmt.Errorf("only one volume can be mounted into the dev environment")
+	}
+
+	return volumes, nil
+}
+
+// parseEnvFlags parses --env flags in the form of KEY=VALUE
+func parseEnvFlags(flags []string) (map[string]string, error) {
+	env := make(map[string]string, len(flags))
+
+	for _, f := range flags {
+		key, value, found := strings.Cut(f, "=")
+
+		if !found {
+			return nil, fmt.Errorf("invalid env variable '%s', expected KEY=VALUE", f)
+		}
+
+		env[key] = value
+	}
+
+	if valid, err := config.ValidEnv(env); !valid {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// privateSshPort is the port SSH listens on within the machine. It's
+// published on port 22 of the environment's public IP, if it has one.
+const privateSshPort = 2222
+
+// vesselExecutable is the path of the running vessel binary, for ssh to run as a ProxyCommand
+func vesselExecutable() string {
+	exe, err := os.Executable()
+
+	if err != nil {
+		logger.GetLogger().Debug("cmd", "init", "msg", "could not find vessel executable", "error", err)
+		return "vessel"
+	}
+
+	return exe
+}
+
+// initServices publishes SSH, and optionally HTTP, on the environment's IP address
+func initServices(publicHttp bool) []config.ServiceConfig {
+	services := []config.ServiceConfig{
+		{
+			Protocol:     "tcp",
+			InternalPort: privateSshPort,
+			Ports:        []config.PortConfig{{Port: 22}},
+		},
+	}
+
+	if publicHttp {
+		services = append(services, config.ServiceConfig{
+			Protocol:     "tcp",
+			InternalPort: 80,
+			Ports: []config.PortConfig{
+				{Port: 80, Handlers: []string{"http"}},
+				{Port: 443, Handlers: []string{"tls", "http"}},
+			},
+		})
+	}
+
+	return services
+}
+
 // waitForConnection waits up to ~30 seconds for SSH to become available
 // (15 attempts, attempted every 2 seconds)
 func waitForConnection(connection *remote.Connection) error {
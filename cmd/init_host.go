@@ -0,0 +1,228 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gosimple/slug"
+	"github.com/manifoldco/promptui"
+	"github.com/vessel-app/vessel-cli/internal/catalog"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/environments"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+	"github.com/vessel-app/vessel-cli/internal/remote"
+	"github.com/vessel-app/vessel-cli/internal/util"
+)
+
+// runInitHostCommand sets up a dev environment on an existing SSH host, used with --host.
+// Nothing is created on Fly. It performs the following actions:
+//  1. Helps create an environment name
+//  2. Generates env files (SSH keys, etc)
+//  3. Installs the public key on the host, authenticating with ssh-agent or a password,
+//     after confirming the host's key if it isn't in ~/.ssh/known_hosts yet
+//  4. Generates project and SSH configuration
+//  5. Downloads Mutagen (if needed)
+func runInitHostCommand() {
+	remoteConfig, err := parseHostFlag(InitHost)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "invalid host", "error", err)
+		fmt.Println(err)
+
+		os.Exit(1)
+	}
+
+	if len(InitPath) == 0 {
+		logger.GetLogger().Error("command", "init", "msg", "no path given for host")
+		fmt.Println("--path is required with --host, e.g. --path /srv/app")
+
+		os.Exit(1)
+	}
+
+	remoteConfig.RemotePath = InitPath
+
+	envVars, err := parseEnvFlags(EnvVars)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "invalid env variable", "error", err)
+		fmt.Println(err)
+
+		os.Exit(1)
+	}
+
+	// Get/generate application name, prompting for it unless --name is used
+	appName := AppName
+
+	if len(appName) == 0 {
+		dir, err := os.Getwd()
+
+		if err != nil {
+			dir = "my-app"
+		}
+
+		askAppName := promptui.Prompt{
+			Label:   "App Name",
+			Default: slug.Make(filepath.Base(dir)),
+		}
+
+		if appName, err = askAppName.Run(); err != nil {
+			// No logging, user likely just bailed out
+			logger.GetLogger().Debug("cmd", "init", "msg", "prompt ui failure asking app name", "error", err)
+			os.Exit(1)
+		}
+	}
+
+	appName = slug.Make(appName)
+
+	// Create ~/.vessel/envs/<app-name>
+	vesselAppDir, err := util.MakeAppDir(appName)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "could not create vessel storage directory", "error", err, "dir", vesselAppDir)
+		PrintIfVerbose(Verbose, err, "error initializing app")
+		os.Exit(1)
+	}
+
+	// Generate and store SSH keys
+	keys, privateKeyPath, err := generateEnvironmentKeys(vesselAppDir)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "could not generate SSH keys", "error", err)
+		PrintIfVerbose(Verbose, err, "error initializing app")
+		os.Exit(1)
+	}
+
+	remoteConfig.IdentityFile = privateKeyPath
+	remoteConfig.Alias = "vessel-" + appName
+
+	// Install the key on the host
+	project := &config.EnvironmentConfig{
+		Name:     appName,
+		Provider: environments.SshProvider,
+		Remote:   *remoteConfig,
+		Env:      envVars,
+	}
+
+	provider, err := environments.NewProvider(project, nil, fly.LeaseOptions{})
+
+	if err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "could not create environment provider", "error", err)
+		PrintIfVerbose(Verbose, err, "error initializing app")
+		os.Exit(1)
+	}
+
+	_, err = provider.Create(context.Background(), &environments.CreateOptions{
+		Project:        project,
+		PublicKey:      string(keys.Public),
+		Password:       askHostPassword(remoteConfig),
+		ConfirmHostKey: askHostKey,
+	}, nil)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "could not install SSH key on host", "error", err)
+		PrintIfVerbose(Verbose, err, fmt.Sprintf("could not install SSH key on %s", remoteConfig.Hostname))
+		os.Exit(1)
+	}
+
+	fmt.Println("\033[1;32m\xE2\x9C\x94\033[0m SSH key installed")
+
+	sshConfig := fmt.Sprintf(`
+Host vessel-%s
+    HostName %s
+    User %s
+    Port %d
+    IdentityFile %s
+    IdentitiesOnly yes
+`, appName, remoteConfig.Hostname, remoteConfig.User, remoteConfig.Port, privateKeyPath)
+
+	if err = addSshAlias(appName, sshConfig); err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "could not write to SSH config to ~/.ssh/config", "error", err)
+		PrintIfVerbose(Verbose, err, "error initializing app")
+		os.Exit(1)
+	}
+
+	// Generate project configuration file
+	project.Forwarding = catalog.DefaultForwarding
+
+	if err = config.WriteProjectConfig("vessel.yml", project); err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "could not write yaml file to current directory", "error", err)
+		PrintIfVerbose(Verbose, err, "error initializing app")
+		os.Exit(1)
+	}
+
+	// Ensure Mutagen is installed
+	if err = ensureMutagen(); err != nil {
+		logger.GetLogger().Error("command", "init", "msg", "could not install mutagen to ~/.vessel/bin/mutagen", "error", err)
+		PrintIfVerbose(Verbose, err, "error initializing app")
+		os.Exit(1)
+	}
+
+	fmt.Println("You're good to go! Run `vessel start` to begin developing!")
+}
+
+// parseHostFlag parses the --host flag in the form of user@host[:port]
+func parseHostFlag(host string) (*config.RemoteConfig, error) {
+	user, address, found := strings.Cut(host, "@")
+
+	if !found || len(user) == 0 || len(address) == 0 {
+		return nil, fmt.Errorf("invalid host '%s', expected user@host[:port]", host)
+	}
+
+	cfg := &config.RemoteConfig{
+		Hostname: address,
+		User:     user,
+		Port:     22,
+	}
+
+	// IPv6 addresses need brackets when a port is given, e.g. user@[::1]:2222
+	if hostname, port, err := net.SplitHostPort(address); err == nil {
+		p, err := strconv.Atoi(port)
+
+		if err != nil {
+			return nil, fmt.Errorf("invalid host port '%s': %w", port, err)
+		}
+
+		cfg.Hostname = hostname
+		cfg.Port = p
+	}
+
+	return cfg, nil
+}
+
+// askHostPassword prompts for the password of the host's user, if ssh-agent can't be used
+func askHostPassword(cfg *config.RemoteConfig) remote.PasswordFunc {
+	return func() (string, error) {
+		askPassword := promptui.Prompt{
+			Label: fmt.Sprintf("Password for %s@%s", cfg.User, cfg.Hostname),
+			Mask:  '*',
+		}
+
+		return askPassword.Run()
+	}
+}
+
+// askHostKey asks whether to trust a host's key, the first time we connect to it
+func askHostKey(host, fingerprint string) (bool, error) {
+	fmt.Printf("The authenticity of host '%s' can't be established.\nIts key fingerprint is %s.\n", host, fingerprint)
+
+	trustHostKey := promptui.Prompt{
+		Label:     "Are you sure you want to continue connecting",
+		IsConfirm: true,
+	}
+
+	if _, err := trustHostKey.Run(); err != nil {
+		if err == promptui.ErrAbort {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+
+	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/environments"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+)
+
+var logsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "Show the dev environment's logs",
+	Long: `Show the output of processes running in the dev environment and its sidecars.
+Use -f to keep streaming new logs until ctrl+c is pressed.`,
+	Run: runLogsCommand,
+}
+
+var logsFollow bool
+var logsRegion string
+var logsMachine string
+var logsTimestamps bool
+
+func init() {
+	logsCmd.Flags().StringVarP(&ConfigPath, "config-file", "c", "vessel.yml", "Configuration file to read from")
+	logsCmd.Flags().BoolVarP(&logsFollow, "follow", "f", false, "Keep streaming new logs")
+	logsCmd.Flags().StringVarP(&logsRegion, "region", "r", "", "Only show logs from a region")
+	logsCmd.Flags().StringVarP(&logsMachine, "machine", "m", "", "Only show logs from a machine ID, or a sidecar name")
+	logsCmd.Flags().BoolVarP(&logsTimestamps, "timestamps", "t", false, "Show the timestamp of each log line")
+}
+
+func runLogsCommand(cmd *cobra.Command, args []string) {
+	cfg, err := config.RetrieveProjectConfig(ConfigPath)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "logs", "msg", "could not read configuration", "error", err)
+		PrintIfVerbose(Verbose, err, "error reading project configuration file")
+
+		os.Exit(1)
+	}
+
+	requireFlyProvider("logs", cfg)
+
+	auth, err := config.RetrieveVesselConfig()
+
+	if err != nil {
+		logger.GetLogger().Error("command", "logs", "msg", "could not get Fly API token from vessel config", "error", err)
+		PrintIfVerbose(Verbose, err, "error retrieving Fly API token")
+
+		os.Exit(1)
+	}
+
+	// Label log lines with sidecar names rather than machine IDs
+	names := make(map[string]string)
+	if state, err := config.RetrieveEnvironmentState(cfg.Name); err == nil {
+		for name, machineId := range state.Sidecars {
+			names[machineId] = name
+		}
+
+		if len(state.Machine) > 0 {
+			names[state.Machine] = cfg.Name
+		}
+	}
+
+	machine := logsMachine
+	if sidecars, err := environments.SidecarIds(cfg.Name); err == nil && len(sidecars[machine]) > 0 {
+		machine = sidecars[machine]
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+
+	opts := &fly.LogOptions{
+		Region:  logsRegion,
+		Machine: machine,
+		Follow:  logsFollow,
+	}
+
+	err = fly.StreamLogs(ctx, auth.Token, cfg.Name, opts, func(entry *fly.LogEntry) {
+		fmt.Println(formatLogEntry(entry, names))
+	})
+
+	if err != nil {
+		logger.GetLogger().Error("command", "logs", "msg", "could not read logs", "error", err)
+		PrintIfVerbose(Verbose, err, "could not read the dev environment's logs")
+
+		os.Exit(1)
+	}
+}
+
+func formatLogEntry(entry *fly.LogEntry, names map[string]string) string {
+	source := names[entry.Instance]
+	if len(source) == 0 {
+		source = entry.Instance
+	}
+
+	line := fmt.Sprintf("\033[0;36m%s[%s]\033[0m %s", entry.Region, source, entry.Message)
+
+	if logsTimestamps {
+		timestamp := entry.Timestamp
+		if t := entry.Time(); !t.IsZero() {
+			timestamp = t.Local().Format("2006-01-02 15:04:05.000")
+		}
+
+		line = timestamp + " " + line
+	}
+
+	return line
+}
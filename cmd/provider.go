@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/environments"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+)
+
+// environmentProvider returns the provider of the project's dev environment, as set by
+// the provider key of vessel.yml. The process exits if the provider can't be used.
+func environmentProvider(command string, cfg *config.EnvironmentConfig) environments.Provider {
+	// Providers not using Fly don't need `vessel auth` to have been run
+	auth, err := config.RetrieveVesselConfig()
+
+	if err != nil {
+		logger.GetLogger().Debug("command", command, "msg", "could not get vessel config", "error", err)
+		auth = nil
+	}
+
+	provider, err := environments.NewProvider(cfg, auth, leaseOptions())
+
+	if err != nil {
+		logger.GetLogger().Error("command", command, "msg", "could not create environment provider", "error", err)
+		PrintIfVerbose(Verbose, err, "the dev environment's provider could not be used")
+
+		os.Exit(1)
+	}
+
+	if cfg.Provider == environments.VesselProvider {
+		if unsupported := environments.VesselUnsupportedSettings(cfg); len(unsupported) > 0 {
+			logger.GetLogger().Warn("command", command, "msg", "settings not supported by provider", "provider", cfg.Provider, "settings", unsupported)
+			fmt.Printf("Warning: environments created through Vessel ignore the %s settings of vessel.yml\n", strings.Join(unsupported, ", "))
+		}
+	}
+
+	return provider
+}
+
+// usesFly determines if the project's dev environment runs on Fly, the default provider
+func usesFly(cfg *config.EnvironmentConfig) bool {
+	return len(cfg.Provider) == 0 || cfg.Provider == environments.FlyProvider
+}
+
+// ensureProviderApi calls ensureFlyApi for environments on Fly. Other providers don't use
+// Fly's API, so they don't need flyctl or `vessel auth`.
+func ensureProviderApi(command string, cfg *config.EnvironmentConfig) func() error {
+	if !usesFly(cfg) {
+		return func() error { return nil }
+	}
+
+	return ensureFlyApi(command)
+}
+
+// requireFlyProvider exits if the project's dev environment doesn't run on Fly,
+// for commands using Fly's APIs directly
+func requireFlyProvider(command string, cfg *config.EnvironmentConfig) {
+	if usesFly(cfg) {
+		return
+	}
+
+	logger.GetLogger().Error("command", command, "msg", "command not supported by provider", "provider", cfg.Provider)
+	fmt.Printf("`vessel %s` is only available for environments on Fly, this environment uses the %s provider\n", command, cfg.Provider)
+
+	os.Exit(1)
+}
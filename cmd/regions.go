@@ -0,0 +1,89 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/gernest/wow"
+	"github.com/gernest/wow/spin"
+	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+)
+
+var regionsCmd = &cobra.Command{
+	Use:   "regions",
+	Short: "List Fly.io regions",
+	Long: `List the Fly.io regions a dev environment can be created in. The list is fetched from Fly
+and cached for a day in ~/.vessel/regions.yml. Use --probe to measure the round trip time to each region, and rank them fastest first.`,
+	Run: runRegionsCommand,
+}
+
+var regionsProbe bool
+
+func init() {
+	regionsCmd.Flags().BoolVar(&regionsProbe, "probe", false, "Measure latency to each region and rank them")
+}
+
+// regionChoice is a region, along with its measured latency if it was probed
+type regionChoice struct {
+	Code    string
+	Name    string
+	Latency string
+}
+
+func runRegionsCommand(cmd *cobra.Command, args []string) {
+	// Without a token the built-in region list is used
+	token := ""
+	if auth, err := config.RetrieveVesselConfig(); err == nil {
+		token = auth.Token
+	}
+
+	regions := fly.AvailableRegions(token)
+
+	if !regionsProbe {
+		for _, r := range regions {
+			fmt.Printf("%s\t%s\n", r.Code, r.Name)
+		}
+
+		return
+	}
+
+	for _, r := range probeRegions(regions) {
+		fmt.Printf("%s\t%-10s\t%s\n", r.Code, r.Latency, r.Name)
+	}
+}
+
+// probeRegions ranks regions by latency, showing a spinner while they're probed
+func probeRegions(regions []fly.Region) []regionChoice {
+	w := wow.New(os.Stdout, spin.Get(spin.Dots), " Measuring latency to each region")
+	w.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	results := fly.ProbeRegions(ctx, regions)
+
+	w.PersistWith(spin.Spinner{Frames: []string{"\033[1;32m\xE2\x9C\x94\033[0m"}}, " Measured latency to each region")
+
+	choices := make([]regionChoice, 0, len(results))
+	for _, r := range results {
+		latency := "unreachable"
+		if r.Error == nil {
+			latency = r.Latency.Round(time.Millisecond).String()
+		} else if errors.Is(r.Error, fly.ErrLatencyUnknown) {
+			latency = "unknown"
+		}
+
+		choices = append(choices, regionChoice{
+			Code:    r.Region.Code,
+			Name:    r.Region.Name,
+			Latency: latency,
+		})
+	}
+
+	return choices
+}
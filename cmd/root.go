@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"errors"
 	"fmt"
 	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/fly"
 	"github.com/vessel-app/vessel-cli/internal/logger"
 	"os"
 )
@@ -21,6 +23,16 @@ func PrintIfVerbose(verbose bool, err error, fallback string) {
 	} else {
 		fmt.Println(fallback)
 	}
+
+	// Always say who is using the environment, so users can decide whether to use --force
+	var held *fly.LeaseHeldError
+	if errors.As(err, &held) {
+		if !verbose {
+			fmt.Println(held)
+		}
+
+		fmt.Println("Use --force to take over the environment anyway")
+	}
 }
 
 // rootCmd is the root/first command. All other commands are "under" this root command.
@@ -44,6 +56,16 @@ func Execute() {
 		startCmd,
 		stopCmd,
 		destroyCmd,
+		upCmd,
+		downCmd,
+		statusCmd,
+		eventsCmd,
+		logsCmd,
+		regionsCmd,
+		applyCmd,
+		secretsCmd,
+		imageCmd,
+		tunnelCmd,
 	}
 
 	rootCmd.Version = Version
@@ -0,0 +1,194 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/manifoldco/promptui"
+	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/environments"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+)
+
+var secretsCmd = &cobra.Command{
+	Use:   "secrets",
+	Short: "Manage secrets available in the dev environment",
+	Long: `Manage secrets that are set as environment variables in the dev environment.
+Secrets are stored by Fly.io, and are never written to vessel.yml.`,
+}
+
+var secretsSetCmd = &cobra.Command{
+	Use:   "set KEY=VALUE [KEY=VALUE...]",
+	Short: "Set secrets",
+	Long: `Set one or more secrets and restart the dev environment (and its sidecars) to apply them.
+Use KEY without a value to be prompted for it, keeping the value out of your shell history.`,
+	Args: cobra.MinimumNArgs(1),
+	Run:  runSecretsSetCommand,
+}
+
+var secretsListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List secret names",
+	Long:  `List the names and digests of secrets. Secret values cannot be retrieved.`,
+	Run:   runSecretsListCommand,
+}
+
+var secretsUnsetCmd = &cobra.Command{
+	Use:   "unset KEY [KEY...]",
+	Short: "Remove secrets",
+	Long:  `Remove one or more secrets and restart the dev environment (and its sidecars) to apply the change.`,
+	Args:  cobra.MinimumNArgs(1),
+	Run:   runSecretsUnsetCommand,
+}
+
+func init() {
+	secretsCmd.PersistentFlags().StringVarP(&ConfigPath, "config-file", "c", "vessel.yml", "Configuration file to read from")
+	secretsCmd.PersistentFlags().BoolVar(&forceLease, "force", false, "Take over the environment even if a teammate is using it")
+	secretsCmd.AddCommand(secretsSetCmd, secretsListCmd, secretsUnsetCmd)
+}
+
+func runSecretsSetCommand(cmd *cobra.Command, args []string) {
+	secrets := make(map[string]string)
+
+	for _, arg := range args {
+		key, value, hasValue := strings.Cut(arg, "=")
+
+		// Secrets are set as env variables, so the same names are reserved
+		if _, err := config.ValidEnv(map[string]string{key: value}); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		if !hasValue {
+			askValue := promptui.Prompt{
+				Label: key,
+				Mask:  '*',
+			}
+
+			var err error
+			if value, err = askValue.Run(); err != nil {
+				// User likely bailed out
+				os.Exit(1)
+			}
+		}
+
+		secrets[key] = value
+	}
+
+	cfg, auth, stopFlyctl := secretsSetup("secrets set")
+	defer stopFlyctl()
+
+	// Note that we log secret names, but never their values
+	if err := fly.SetSecrets(auth.Token, cfg.Name, secrets); err != nil {
+		logger.GetLogger().Error("command", "secrets set", "msg", "could not set secrets", "error", err)
+		PrintIfVerbose(Verbose, err, "could not set secrets")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	fmt.Printf("\033[1;32m\xE2\x9C\x94\033[0m Set %d secret(s)\n", len(secrets))
+	restartForSecrets(auth.Token, cfg.Name, stopFlyctl)
+}
+
+func runSecretsListCommand(cmd *cobra.Command, args []string) {
+	cfg, auth, stopFlyctl := secretsSetup("secrets list")
+	defer stopFlyctl()
+
+	secrets, err := fly.ListSecrets(auth.Token, cfg.Name)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "secrets list", "msg", "could not list secrets", "error", err)
+		PrintIfVerbose(Verbose, err, "could not list secrets")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	if len(secrets) == 0 {
+		fmt.Println("No secrets are set")
+		return
+	}
+
+	for _, s := range secrets {
+		fmt.Printf("%s\t%s\t%s\n", s.Name, s.Digest, s.CreatedAt)
+	}
+}
+
+func runSecretsUnsetCommand(cmd *cobra.Command, args []string) {
+	cfg, auth, stopFlyctl := secretsSetup("secrets unset")
+	defer stopFlyctl()
+
+	if err := fly.UnsetSecrets(auth.Token, cfg.Name, args); err != nil {
+		logger.GetLogger().Error("command", "secrets unset", "msg", "could not unset secrets", "error", err, "keys", strings.Join(args, ","))
+		PrintIfVerbose(Verbose, err, "could not unset secrets")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	fmt.Printf("\033[1;32m\xE2\x9C\x94\033[0m Removed %d secret(s)\n", len(args))
+	restartForSecrets(auth.Token, cfg.Name, stopFlyctl)
+}
+
+// secretsSetup retrieves the configuration and API access each secrets command needs
+func secretsSetup(command string) (*config.EnvironmentConfig, *config.AuthConfig, func() error) {
+	cfg, err := config.RetrieveProjectConfig(ConfigPath)
+
+	if err != nil {
+		logger.GetLogger().Error("command", command, "msg", "could not read configuration", "error", err)
+		PrintIfVerbose(Verbose, err, "error reading project configuration file")
+
+		os.Exit(1)
+	}
+
+	requireFlyProvider(command, cfg)
+
+	auth, err := config.RetrieveVesselConfig()
+
+	if err != nil {
+		logger.GetLogger().Error("command", command, "msg", "could not get Fly API token from vessel config", "error", err)
+		PrintIfVerbose(Verbose, err, "error retrieving Fly API token")
+
+		os.Exit(1)
+	}
+
+	return cfg, auth, ensureFlyApi(command)
+}
+
+// restartForSecrets restarts a running dev environment and its sidecars so the secret changes
+// are applied. Sidecars are restarted first, so they're ready when the machine comes back.
+func restartForSecrets(token, appName string, stopFlyctl func() error) {
+	sidecars, err := environments.RestartSidecars(token, appName, leaseOptions())
+
+	if err != nil {
+		logger.GetLogger().Error("command", "secrets", "msg", "could not restart sidecars", "error", err)
+		PrintIfVerbose(Verbose, err, "could not restart the dev environment's sidecars, run `vessel down` and `vessel up` to apply secrets")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	if len(sidecars) > 0 {
+		fmt.Printf("Restarted sidecar(s) %s to apply the change\n", strings.Join(sidecars, ", "))
+	}
+
+	restarted, err := environments.RestartMachine(token, appName, leaseOptions())
+
+	if err != nil {
+		logger.GetLogger().Error("command", "secrets", "msg", "could not restart machine", "error", err)
+		PrintIfVerbose(Verbose, err, "could not restart the dev environment, run `vessel down` and `vessel up` to apply secrets")
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	if restarted {
+		fmt.Println("Restarted the dev environment to apply the change")
+	} else {
+		fmt.Println("The change will apply when the dev environment next starts")
+	}
+}
@@ -6,7 +6,6 @@ import (
 	"github.com/spf13/cobra"
 	"github.com/vessel-app/vessel-cli/internal/config"
 	"github.com/vessel-app/vessel-cli/internal/logger"
-	"github.com/vessel-app/vessel-cli/internal/remote"
 	"os"
 	"os/signal"
 	"syscall"
@@ -53,9 +52,15 @@ func runSSHCommand(cmd *cobra.Command, args []string) {
 }
 
 func run(ctx context.Context, cfg *config.EnvironmentConfig) error {
-	connection := remote.NewConnection(&cfg.Remote)
+	connection, closeTunnel, err := remoteConnection(cfg)
 
-	err := connection.SSH(ctx)
+	if err != nil {
+		return err
+	}
+
+	defer closeTunnel()
+
+	err = connection.SSH(ctx)
 	if err != nil {
 		return fmt.Errorf("could not start ssh session: %w", err)
 	}
@@ -0,0 +1,10 @@
+//go:build !flytest
+
+package cmd
+
+import "github.com/vessel-app/vessel-cli/internal/remote"
+
+// sshDialer connects to dev environments directly in release builds, see ssh_dial_flytest.go
+func sshDialer() remote.DialFunc {
+	return nil
+}
@@ -0,0 +1,27 @@
+//go:build flytest
+
+package cmd
+
+import (
+	"context"
+	"net"
+	"os"
+
+	"github.com/vessel-app/vessel-cli/internal/remote"
+)
+
+// sshDialer sends SSH connections to the address in VESSEL_FAKE_SSH, if it's set. Environments of the
+// fake Fly API have no SSH servers of their own, so a local one can stand in for all of them.
+// It's only built with the flytest build tag, like fake-fly.
+func sshDialer() remote.DialFunc {
+	address := os.Getenv("VESSEL_FAKE_SSH")
+
+	if len(address) == 0 {
+		return nil
+	}
+
+	return func(ctx context.Context, network, _ string) (net.Conn, error) {
+		var d net.Dialer
+		return d.DialContext(ctx, network, address)
+	}
+}
@@ -1,10 +1,13 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
 	"github.com/gosimple/slug"
 	"github.com/spf13/cobra"
 	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/environments"
+	"github.com/vessel-app/vessel-cli/internal/fly"
 	"github.com/vessel-app/vessel-cli/internal/logger"
 	"github.com/vessel-app/vessel-cli/internal/mutagen"
 	"os"
@@ -22,6 +25,7 @@ var detach = false
 
 func init() {
 	startCmd.Flags().StringVarP(&ConfigPath, "config-file", "c", "vessel.yml", "Configuration file to read from")
+	startCmd.Flags().BoolVar(&forceLease, "force", false, "Take over the environment even if a teammate is using it")
 	startCmd.Flags().BoolVarP(&detach, "detach", "d", false, "Run in the background. Run `vessel stop` to stop the development session.")
 }
 
@@ -37,6 +41,62 @@ func runStartCommand(cmd *cobra.Command, args []string) {
 		os.Exit(1)
 	}
 
+	// Sidecar hostnames are needed to forward ports to sidecars
+	sidecars, err := environments.SidecarIds(cfg.Name)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "start", "msg", "could not read sidecars", "error", err)
+		PrintIfVerbose(Verbose, err, "error starting syncing session")
+
+		os.Exit(1)
+	}
+
+	sidecarHosts := make(map[string]string, len(sidecars))
+	for sidecar := range sidecars {
+		sidecarHosts[sidecar] = environments.SidecarHost(cfg.Name, sidecar)
+	}
+
+	// The environment's machine starts when Mutagen connects to it through Fly's proxy. Machines
+	// reached over WireGuard bypass the proxy, so they're started along with their sidecars.
+	if cfg.Remote.WireGuard {
+		provider := environmentProvider("start", cfg)
+
+		stopFlyctl := ensureFlyApi("start")
+		ctx, cancel := context.WithTimeout(context.Background(), fly.DefaultWaitTimeout)
+		err = provider.Start(ctx, cfg.Name, nil)
+		cancel()
+		stopFlyctl()
+
+		if err != nil {
+			logger.GetLogger().Error("command", "start", "msg", "could not start dev environment", "error", err)
+			PrintIfVerbose(Verbose, err, waitErrorMessage(err, "error starting the dev environment"))
+
+			os.Exit(1)
+		}
+	} else if len(sidecars) > 0 {
+		auth, err := config.RetrieveVesselConfig()
+
+		if err != nil {
+			logger.GetLogger().Error("command", "start", "msg", "could not get Fly API token from vessel config", "error", err)
+			PrintIfVerbose(Verbose, err, "error retrieving Fly API token")
+
+			os.Exit(1)
+		}
+
+		stopFlyctl := ensureFlyApi("start")
+		ctx, cancel := context.WithTimeout(context.Background(), fly.DefaultWaitTimeout)
+		err = environments.StartSidecars(ctx, auth.Token, cfg.Name, leaseOptions())
+		cancel()
+		stopFlyctl()
+
+		if err != nil {
+			logger.GetLogger().Error("command", "start", "msg", "could not start sidecars", "error", err)
+			PrintIfVerbose(Verbose, err, waitErrorMessage(err, "error starting the dev environment's sidecars"))
+
+			os.Exit(1)
+		}
+	}
+
 	// Get mutagen session name
 	name := slug.Make("vessel-" + cfg.Name)
 
@@ -45,7 +105,7 @@ func runStartCommand(cmd *cobra.Command, args []string) {
 	mutagen.StopSession(name)
 
 	// todo: We assume local path is "."
-	err = mutagen.StartSession(name, ".", cfg)
+	err = mutagen.StartSession(name, ".", cfg, sidecarHosts)
 
 	if err != nil {
 		logger.GetLogger().Error("command", "start", "msg", "error starting syncing session", "error", err)
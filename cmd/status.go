@@ -0,0 +1,307 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+
+	"github.com/gosimple/slug"
+	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/environments"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+	"github.com/vessel-app/vessel-cli/internal/mutagen"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the status of the dev environment",
+	Long:  `Show the state of the dev environment's machine, network and file syncing/port forwarding sessions.`,
+	Run:   runStatusCommand,
+}
+
+var statusJson bool
+
+func init() {
+	statusCmd.Flags().StringVarP(&ConfigPath, "config-file", "c", "vessel.yml", "Configuration file to read from")
+	statusCmd.Flags().BoolVar(&statusJson, "json", false, "Output the status report as JSON")
+}
+
+type statusReport struct {
+	App      string          `json:"app"`
+	Machine  machineStatus   `json:"machine"`
+	Sidecars []sidecarStatus `json:"sidecars"`
+	Network  networkStatus   `json:"network"`
+	Syncs    []sessionStatus `json:"syncs"`
+	Forwards []sessionStatus `json:"forwards"`
+	// SessionError is set if the Mutagen sessions could not be listed
+	SessionError string `json:"session_error,omitempty"`
+}
+
+type machineStatus struct {
+	Id     string `json:"id,omitempty"`
+	State  string `json:"state,omitempty"`
+	Region string `json:"region,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+type sidecarStatus struct {
+	Name  string `json:"name"`
+	Host  string `json:"host"`
+	State string `json:"state,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+type networkStatus struct {
+	Ip           string `json:"ip,omitempty"`
+	IpType       string `json:"ip_type,omitempty"`
+	SshReachable bool   `json:"ssh_reachable"`
+	SshChecked   bool   `json:"ssh_checked"`
+	Error        string `json:"error,omitempty"`
+}
+
+type sessionStatus struct {
+	Name      string `json:"name"`
+	Status    string `json:"status,omitempty"`
+	Connected bool   `json:"connected"`
+	Paused    bool   `json:"paused"`
+}
+
+// runStatusCommand reports on the dev environment. Each part of the report is gathered
+// independently, so a failure (e.g. mutagen not running) doesn't hide the rest.
+func runStatusCommand(cmd *cobra.Command, args []string) {
+	cfg, err := config.RetrieveProjectConfig(ConfigPath)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "status", "msg", "could not read configuration", "error", err)
+		PrintIfVerbose(Verbose, err, "error reading project configuration file")
+
+		os.Exit(1)
+	}
+
+	provider := environmentProvider("status", cfg)
+
+	stopFlyctl := ensureProviderApi("status", cfg)
+	defer stopFlyctl()
+
+	report := &statusReport{
+		App:      cfg.Name,
+		Sidecars: make([]sidecarStatus, 0),
+		Syncs:    make([]sessionStatus, 0),
+		Forwards: make([]sessionStatus, 0),
+	}
+
+	// Machine state
+	machine, err := provider.Get(cfg.Name)
+
+	if machine != nil {
+		report.Machine.Id = machine.Id
+		report.Machine.State = machine.State
+		report.Machine.Region = machine.Region
+	}
+
+	if err != nil {
+		logger.GetLogger().Debug("command", "status", "msg", "could not get machine", "error", err)
+		report.Machine.Error = err.Error()
+	}
+
+	// Sidecar states
+	sidecars, err := environments.SidecarIds(cfg.Name)
+
+	if err != nil {
+		logger.GetLogger().Debug("command", "status", "msg", "could not read sidecars", "error", err)
+	}
+
+	// Only environments on Fly have sidecars, so the Fly API token is only needed for them
+	token := ""
+	if len(cfg.Sidecars) > 0 {
+		auth, err := config.RetrieveVesselConfig()
+
+		if err != nil {
+			logger.GetLogger().Error("command", "status", "msg", "could not get Fly API token from vessel config", "error", err)
+			PrintIfVerbose(Verbose, err, "error retrieving Fly API token")
+			stopFlyctl()
+
+			os.Exit(1)
+		}
+
+		token = auth.Token
+	}
+
+	for _, sc := range cfg.Sidecars {
+		status := sidecarStatus{Name: sc.Name}
+
+		if machineId, ok := sidecars[sc.Name]; !ok {
+			status.Error = "not created, run `vessel apply`"
+		} else {
+			status.Host = environments.SidecarHost(cfg.Name, sc.Name)
+			if machine, err := fly.GetMachine(token, cfg.Name, machineId); err != nil {
+				logger.GetLogger().Debug("command", "status", "msg", "could not get sidecar machine", "sidecar", sc.Name, "error", err)
+				status.Error = err.Error()
+			} else {
+				status.State = machine.State
+			}
+		}
+
+		report.Sidecars = append(report.Sidecars, status)
+	}
+
+	// Network
+	endpoint, err := provider.Endpoint(cfg.Name)
+
+	if err != nil {
+		logger.GetLogger().Debug("command", "status", "msg", "could not get environment endpoint", "error", err)
+		report.Network.Error = err.Error()
+	} else {
+		report.Network.Ip = endpoint
+		report.Network.IpType = endpointType(endpoint)
+	}
+
+	// Connecting to a stopped machine would start it, so we only test SSH on a running machine
+	if report.Machine.State == "started" {
+		report.Network.SshChecked = true
+		connection, closeTunnel, err := remoteConnection(cfg)
+
+		if err == nil {
+			err = connection.TestConnection()
+			closeTunnel()
+		}
+
+		if err != nil {
+			logger.GetLogger().Debug("command", "status", "msg", "could not connect over ssh", "error", err)
+		} else {
+			report.Network.SshReachable = true
+		}
+	}
+
+	// Syncing and forwarding
+	syncs, forwards, err := mutagen.ListSessions(slug.Make("vessel-" + cfg.Name))
+
+	if err != nil {
+		logger.GetLogger().Debug("command", "status", "msg", "could not list mutagen sessions", "error", err)
+		report.SessionError = err.Error()
+	}
+
+	for _, s := range syncs {
+		report.Syncs = append(report.Syncs, sessionStatus{
+			Name:      s.Name,
+			Status:    s.Status,
+			Connected: s.IsConnected(),
+			Paused:    s.Paused,
+		})
+	}
+
+	for _, f := range forwards {
+		report.Forwards = append(report.Forwards, sessionStatus{
+			Name:      f.Name,
+			Status:    f.Status,
+			Connected: f.IsConnected(),
+			Paused:    f.Paused,
+		})
+	}
+
+	if statusJson {
+		output, err := json.MarshalIndent(report, "", "  ")
+
+		if err != nil {
+			logger.GetLogger().Error("command", "status", "msg", "could not marshal status report", "error", err)
+			PrintIfVerbose(Verbose, err, "could not output status report")
+			stopFlyctl()
+
+			os.Exit(1)
+		}
+
+		fmt.Println(string(output))
+		return
+	}
+
+	printStatusReport(report)
+}
+
+func printStatusReport(r *statusReport) {
+	fmt.Printf("Environment: %s\n", r.App)
+
+	if len(r.Machine.Error) > 0 {
+		fmt.Printf("Machine:     unknown (%s)\n", r.Machine.Error)
+	} else if len(r.Machine.Region) == 0 {
+		fmt.Printf("Machine:     %s (%s)\n", r.Machine.State, r.Machine.Id)
+	} else {
+		fmt.Printf("Machine:     %s (id %s, region %s)\n", r.Machine.State, r.Machine.Id, r.Machine.Region)
+	}
+
+	for _, sc := range r.Sidecars {
+		if len(sc.Error) > 0 {
+			fmt.Printf("Sidecar:     %s unknown (%s)\n", sc.Name, sc.Error)
+		} else {
+			fmt.Printf("Sidecar:     %s %s (%s)\n", sc.Name, sc.State, sc.Host)
+		}
+	}
+
+	if len(r.Network.Error) > 0 {
+		fmt.Printf("IP address:  unknown (%s)\n", r.Network.Error)
+	} else {
+		fmt.Printf("IP address:  %s (%s)\n", r.Network.Ip, r.Network.IpType)
+	}
+
+	switch {
+	case !r.Network.SshChecked:
+		fmt.Println("SSH:         not checked, machine is not running")
+	case r.Network.SshReachable:
+		fmt.Println("SSH:         reachable")
+	default:
+		fmt.Println("SSH:         unreachable")
+	}
+
+	if len(r.SessionError) > 0 {
+		fmt.Printf("Sessions:    unknown (%s)\n", r.SessionError)
+		return
+	}
+
+	if len(r.Syncs) == 0 {
+		fmt.Println("Syncing:     not running, run `vessel start` to begin")
+	}
+
+	for _, s := range r.Syncs {
+		fmt.Printf("Syncing:     %s %s\n", s.Name, sessionState(s))
+	}
+
+	if len(r.Forwards) == 0 {
+		fmt.Println("Forwarding:  not running")
+	}
+
+	for _, f := range r.Forwards {
+		fmt.Printf("Forwarding:  %s %s\n", f.Name, sessionState(f))
+	}
+}
+
+func sessionState(s sessionStatus) string {
+	state := "disconnected"
+
+	if s.Paused {
+		state = "paused"
+	} else if s.Connected {
+		state = "connected"
+	}
+
+	if len(s.Status) > 0 {
+		return fmt.Sprintf("%s (%s)", state, s.Status)
+	}
+
+	return state
+}
+
+// endpointType describes an environment's endpoint as v4 or v6 for IP addresses, else as a hostname
+func endpointType(endpoint string) string {
+	ip := net.ParseIP(endpoint)
+
+	switch {
+	case ip == nil:
+		return "hostname"
+	case ip.To4() != nil:
+		return "v4"
+	}
+
+	return "v6"
+}
@@ -0,0 +1,116 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/environments"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+	"github.com/vessel-app/vessel-cli/internal/remote"
+	"github.com/vessel-app/vessel-cli/internal/tunnel"
+)
+
+var tunnelCmd = &cobra.Command{
+	Use:   "tunnel <app> <host> <port>",
+	Short: "Connect to a host over an environment's WireGuard tunnel",
+	Long: `Connect stdin and stdout to a host within Fly's private network, over the WireGuard tunnel of
+an environment created with vessel init --wireguard. It's used as the SSH ProxyCommand of such
+environments, so ssh and Mutagen can reach them.`,
+	Hidden: true,
+	Args:   cobra.ExactArgs(3),
+	Run:    runTunnelCommand,
+}
+
+func runTunnelCommand(cmd *cobra.Command, args []string) {
+	t, err := openTunnel(args[0])
+
+	if err != nil {
+		logger.GetLogger().Error("command", "tunnel", "msg", "could not start wireguard tunnel", "error", err)
+		fmt.Fprintln(os.Stderr, err)
+
+		os.Exit(1)
+	}
+
+	defer t.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	conn, err := t.DialContext(ctx, "tcp", net.JoinHostPort(args[1], args[2]))
+	cancel()
+
+	if err != nil {
+		logger.GetLogger().Error("command", "tunnel", "msg", "could not connect through wireguard tunnel", "error", err)
+		fmt.Fprintln(os.Stderr, err)
+
+		os.Exit(1)
+	}
+
+	defer conn.Close()
+
+	go func() {
+		_, _ = io.Copy(conn, os.Stdin)
+
+		// Let the host know we're done sending, while still reading its reply
+		if c, ok := conn.(interface{ CloseWrite() error }); ok {
+			_ = c.CloseWrite()
+		}
+	}()
+
+	_, _ = io.Copy(os.Stdout, conn)
+}
+
+// openTunnel starts the WireGuard tunnel of an environment, using the peer in its state
+func openTunnel(appName string) (*tunnel.Tunnel, error) {
+	state, err := config.RetrieveEnvironmentState(appName)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read environment state: %w", err)
+	}
+
+	if state.WireGuard == nil {
+		return nil, fmt.Errorf("environment %s has no wireguard peer", appName)
+	}
+
+	return tunnel.Up(state.WireGuard)
+}
+
+// remoteConnection connects to the project's dev environment, through its WireGuard tunnel if it's
+// reached over Fly's private network. The returned function closes the tunnel, if one was started.
+func remoteConnection(cfg *config.EnvironmentConfig) (*remote.Connection, func(), error) {
+	connection := remote.NewConnection(&cfg.Remote).WithEnv(cfg.Env)
+
+	if dial := sshDialer(); dial != nil {
+		connection.WithDialer(dial)
+	}
+
+	// Hosts of the ssh provider are long-lived, so their keys were trusted by vessel init. Machines
+	// of other providers have their key pinned in the environment's own known_hosts file.
+	if cfg.Provider == environments.SshProvider {
+		connection.WithKnownHosts()
+	} else {
+		knownHostsPath, err := config.EnvironmentKnownHostsPath(cfg.Name)
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		connection.WithPinnedHostKey(knownHostsPath)
+	}
+
+	if !cfg.Remote.WireGuard {
+		return connection, func() {}, nil
+	}
+
+	t, err := openTunnel(cfg.Name)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not start wireguard tunnel: %w", err)
+	}
+
+	return connection.WithDialer(t.DialContext), t.Close, nil
+}
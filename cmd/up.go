@@ -0,0 +1,63 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/gernest/wow"
+	"github.com/gernest/wow/spin"
+	"github.com/spf13/cobra"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+)
+
+var upCmd = &cobra.Command{
+	Use:   "up",
+	Short: "Start the dev environment's machine",
+	Long:  `Start the dev environment's machine, along with any sidecars, and wait for it to be running.`,
+	Run:   runUpCommand,
+}
+
+func init() {
+	upCmd.Flags().StringVarP(&ConfigPath, "config-file", "c", "vessel.yml", "Configuration file to read from")
+	upCmd.Flags().BoolVar(&forceLease, "force", false, "Take over the environment even if a teammate is using it")
+}
+
+// runUpCommand starts the sidecars and machine of a dev environment, rather than
+// waiting for it to be started by a new connection
+func runUpCommand(cmd *cobra.Command, args []string) {
+	cfg, err := config.RetrieveProjectConfig(ConfigPath)
+
+	if err != nil {
+		logger.GetLogger().Error("command", "up", "msg", "could not read configuration", "error", err)
+		PrintIfVerbose(Verbose, err, "error reading project configuration file")
+
+		os.Exit(1)
+	}
+
+	provider := environmentProvider("up", cfg)
+
+	stopFlyctl := ensureProviderApi("up", cfg)
+	defer stopFlyctl()
+
+	w := wow.New(os.Stdout, spin.Get(spin.Dots), " Starting environment")
+	w.Start()
+
+	ctx, cancel := context.WithTimeout(context.Background(), fly.DefaultWaitTimeout)
+	defer cancel()
+
+	err = provider.Start(ctx, cfg.Name, printStateChanges(w, "  Starting environment, machine is %s"))
+
+	if err != nil {
+		logger.GetLogger().Error("command", "up", "msg", "could not start dev environment", "error", err)
+		PrintIfVerbose(Verbose, err, waitErrorMessage(err, "could not start the dev environment"))
+		stopFlyctl()
+
+		os.Exit(1)
+	}
+
+	w.PersistWith(spin.Spinner{Frames: []string{"\033[1;32m\xE2\x9C\x94\033[0m"}}, " Environment is running")
+	fmt.Println("Run `vessel start` to begin developing!")
+}
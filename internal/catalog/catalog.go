@@ -0,0 +1,188 @@
+package catalog
+
+import (
+	"fmt"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+	"gopkg.in/yaml.v3"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Defaults for images that don't define their own settings.
+// These match the vesselapp/base image.
+const (
+	DefaultUser = "vessel"
+	DefaultPath = "/home/vessel/app"
+)
+
+var DefaultForwarding = []string{"8000:80"}
+
+// Entry describes a dev environment image, and how Vessel should use it
+type Entry struct {
+	Image       string `yaml:"image"`
+	Description string `yaml:"description,omitempty"`
+	// User is the user Vessel connects to the environment as
+	User string `yaml:"user,omitempty"`
+	// Path is where the project is synced to in the environment
+	Path       string   `yaml:"path,omitempty"`
+	Forwarding []string `yaml:"forwarding,omitempty"`
+	Ignore     []string `yaml:"ignore,omitempty"`
+	// PostCreate commands are run in Path once a new environment is reachable
+	PostCreate []string `yaml:"post_create,omitempty"`
+}
+
+// Catalog is a list of images to choose from when creating a dev environment.
+// Teams can provide their own catalog as a YAML file or URL.
+type Catalog struct {
+	Images []Entry `yaml:"images"`
+}
+
+// BuiltIn is the catalog of images provided by Vessel
+var BuiltIn = &Catalog{
+	Images: []Entry{
+		phpEntry("8.1"),
+		phpEntry("8.0"),
+		phpEntry("7.4"),
+	},
+}
+
+func phpEntry(version string) Entry {
+	return Entry{
+		Image:       "vesselapp/php:" + version,
+		Description: "PHP " + version + ", Composer and Node",
+		User:        DefaultUser,
+		Path:        DefaultPath,
+		Forwarding:  DefaultForwarding,
+		Ignore:      phpIgnore,
+	}
+}
+
+// phpIgnore are the paths of PHP projects' dependencies, which are installed within the environment
+var phpIgnore = []string{"vendor", "node_modules"}
+
+// Default describes an image that is not in any catalog
+func Default(image string) *Entry {
+	e := &Entry{
+		Image: image,
+	}
+	e.fillDefaults()
+
+	// Guess the dependencies of custom PHP images shouldn't be synced, as for the built-in ones
+	if strings.Contains(image, "php") {
+		e.Ignore = phpIgnore
+	}
+
+	return e
+}
+
+func (e *Entry) fillDefaults() {
+	if len(e.User) == 0 {
+		e.User = DefaultUser
+	}
+
+	if len(e.Path) == 0 {
+		e.Path = DefaultPath
+	}
+
+	if len(e.Forwarding) == 0 {
+		e.Forwarding = DefaultForwarding
+	}
+}
+
+// Names lists the image names within the catalog
+func (c *Catalog) Names() []string {
+	images := make([]string, 0, len(c.Images))
+	for _, e := range c.Images {
+		images = append(images, e.Image)
+	}
+
+	return images
+}
+
+// Find returns the catalog entry of an image
+func (c *Catalog) Find(image string) (*Entry, bool) {
+	for i := range c.Images {
+		if c.Images[i].Image == image {
+			return &c.Images[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// Merge combines catalogs. Entries of earlier catalogs take precedence
+// over entries for the same image in later catalogs.
+func Merge(catalogs ...*Catalog) *Catalog {
+	merged := &Catalog{}
+
+	for _, c := range catalogs {
+		if c == nil {
+			continue
+		}
+
+		for _, e := range c.Images {
+			if _, exists := merged.Find(e.Image); !exists {
+				merged.Images = append(merged.Images, e)
+			}
+		}
+	}
+
+	return merged
+}
+
+// Load reads a catalog from a YAML file path or an http(s) URL
+func Load(source string) (*Catalog, error) {
+	var data []byte
+	var err error
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		data, err = download(source)
+	} else {
+		data, err = os.ReadFile(source)
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read catalog '%s': %w", source, err)
+	}
+
+	c := &Catalog{}
+
+	if err = yaml.Unmarshal(data, c); err != nil {
+		return nil, fmt.Errorf("error parsing catalog %s: %w", source, err)
+	}
+
+	for i := range c.Images {
+		if len(c.Images[i].Image) == 0 {
+			return nil, fmt.Errorf("catalog %s has an entry without an image", source)
+		}
+
+		c.Images[i].fillDefaults()
+	}
+
+	return c, nil
+}
+
+func download(url string) ([]byte, error) {
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+	}
+
+	logger.GetLogger().Debug("caller", "catalog.download", "msg", "downloading catalog", "url", url)
+
+	resp, err := client.Get(url)
+
+	if err != nil {
+		return nil, fmt.Errorf("http client error: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("catalog url %s returned wrong status code: got %v want %v", url, resp.StatusCode, http.StatusOK)
+	}
+
+	return io.ReadAll(resp.Body)
+}
@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+// dockerHubCredentialKey is the key Docker stores Docker Hub credentials under
+const dockerHubCredentialKey = "https://index.docker.io/v1/"
+
+// dockerConfig is the part of ~/.docker/config.json holding registry credentials
+type dockerConfig struct {
+	// Auths holds credentials stored in config.json itself, as base64 encoded "username:password"
+	Auths map[string]struct {
+		Auth string `json:"auth"`
+	} `json:"auths"`
+	// CredsStore is the credential helper used for all registries, e.g. "osxkeychain"
+	CredsStore string `json:"credsStore"`
+	// CredHelpers are credential helpers used for specific registries
+	CredHelpers map[string]string `json:"credHelpers"`
+}
+
+// DockerCredential reads the credentials of a registry saved by `docker login`. Credentials kept in an
+// OS keychain are read with Docker's credential helper, e.g. docker-credential-osxkeychain.
+func DockerCredential(host string) (*RegistryCredential, error) {
+	cfg, err := retrieveDockerConfig()
+
+	if err != nil {
+		return nil, err
+	}
+
+	key := host
+	if host == "docker.io" {
+		key = dockerHubCredentialKey
+	}
+
+	helper := cfg.CredHelpers[host]
+	if len(helper) == 0 {
+		helper = cfg.CredsStore
+	}
+
+	if len(helper) > 0 {
+		return credentialFromHelper(helper, key)
+	}
+
+	auth, ok := cfg.Auths[key]
+
+	if !ok || len(auth.Auth) == 0 {
+		return nil, fmt.Errorf("no docker credentials found for %s, run `docker login %s`", host, host)
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(auth.Auth)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not decode docker credentials for %s: %w", host, err)
+	}
+
+	username, password, found := strings.Cut(string(decoded), ":")
+
+	if !found {
+		return nil, fmt.Errorf("invalid docker credentials for %s", host)
+	}
+
+	return &RegistryCredential{
+		Username: username,
+		Password: password,
+	}, nil
+}
+
+// credentialFromHelper gets a registry's credentials from a Docker credential helper
+func credentialFromHelper(helper, key string) (*RegistryCredential, error) {
+	cmd := exec.Command("docker-credential-"+helper, "get")
+	cmd.Stdin = strings.NewReader(key)
+
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+
+	output, err := cmd.Output()
+
+	if err != nil {
+		return nil, fmt.Errorf("docker-credential-%s could not get credentials for %s: %w: %s", helper, key, err, strings.TrimSpace(stderr.String()+string(output)))
+	}
+
+	var cred struct {
+		Username string `json:"Username"`
+		Secret   string `json:"Secret"`
+	}
+
+	if err = json.Unmarshal(output, &cred); err != nil {
+		return nil, fmt.Errorf("could not parse output of docker-credential-%s: %w", helper, err)
+	}
+
+	return &RegistryCredential{
+		Username: cred.Username,
+		Password: cred.Secret,
+	}, nil
+}
+
+// retrieveDockerConfig reads Docker's config.json, from $DOCKER_CONFIG or ~/.docker
+func retrieveDockerConfig() (*dockerConfig, error) {
+	dir := os.Getenv("DOCKER_CONFIG")
+
+	if len(dir) == 0 {
+		home, err := homedir.Dir()
+
+		if err != nil {
+			return nil, fmt.Errorf("could not find home dir: %w", err)
+		}
+
+		dir = filepath.Join(home, ".docker")
+	}
+
+	configPath := filepath.Join(dir, "config.json")
+	file, err := os.ReadFile(configPath)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read docker config '%s': %w", configPath, err)
+	}
+
+	cfg := &dockerConfig{}
+
+	if err = json.Unmarshal(file, cfg); err != nil {
+		return nil, fmt.Errorf("error parsing docker config %s: %w", configPath, err)
+	}
+
+	return cfg, nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+)
+
+func writeDockerConfig(t *testing.T, contents string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	t.Setenv("DOCKER_CONFIG", dir)
+
+	if err := os.WriteFile(filepath.Join(dir, "config.json"), []byte(contents), 0600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDockerCredentialFromConfig(t *testing.T) {
+	// "user:secret" and "hub:token", base64 encoded
+	writeDockerConfig(t, `{"auths":{"ghcr.io":{"auth":"dXNlcjpzZWNyZXQ="},"https://index.docker.io/v1/":{"auth":"aHViOnRva2Vu"}}}`)
+
+	cred, err := DockerCredential("ghcr.io")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cred.Username != "user" || cred.Password != "secret" {
+		t.Errorf("unexpected credentials %+v", cred)
+	}
+
+	// Docker Hub credentials are stored under its old index URL
+	if cred, err = DockerCredential("docker.io"); err != nil || cred.Username != "hub" || cred.Password != "token" {
+		t.Errorf("expected Docker Hub credentials, got %+v (%v)", cred, err)
+	}
+
+	if _, err = DockerCredential("quay.io"); err == nil {
+		t.Error("expected an error for a registry Docker isn't logged into")
+	}
+}
+
+func TestDockerCredentialFromHelper(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake credential helper is a shell script")
+	}
+
+	writeDockerConfig(t, `{"auths":{"ghcr.io":{}},"credsStore":"desktop","credHelpers":{"registry.acme.dev":"acme"}}`)
+
+	// Helpers are given the registry on stdin, and answer with its credentials
+	bin := t.TempDir()
+	helper := `#!/bin/sh
+read registry
+echo "{\"ServerURL\":\"$registry\",\"Username\":\"$(basename $0)\",\"Secret\":\"$registry\"}"
+`
+
+	for _, name := range []string{"docker-credential-desktop", "docker-credential-acme"} {
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(helper), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	cred, err := DockerCredential("ghcr.io")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if cred.Username != "docker-credential-desktop" || cred.Password != "ghcr.io" {
+		t.Errorf("expected the credentials store to be used, got %+v", cred)
+	}
+
+	// Registries with their own helper use it instead
+	if cred, err = DockerCredential("registry.acme.dev"); err != nil || cred.Username != "docker-credential-acme" {
+		t.Errorf("expected the registry's credential helper to be used, got %+v (%v)", cred, err)
+	}
+}
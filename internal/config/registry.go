@@ -0,0 +1,118 @@
+package config
+
+import (
+	"fmt"
+	"github.com/vessel-app/vessel-cli/internal/util"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+)
+
+// RegistryCredential is a login for a private container image registry
+type RegistryCredential struct {
+	Username string `yaml:"username,omitempty"`
+	Password string `yaml:"password,omitempty"`
+	// Docker reads the login from Docker's credentials (e.g. the OS keychain) when it's used,
+	// instead of storing it in registries.yml
+	Docker bool `yaml:"docker,omitempty"`
+}
+
+// Resolve returns the username and password of a registry host's login, reading them
+// from Docker's credentials if that's where they're kept
+func (c *RegistryCredential) Resolve(host string) (*RegistryCredential, error) {
+	if !c.Docker {
+		return c, nil
+	}
+
+	return DockerCredential(host)
+}
+
+// RegistryCredentials are keyed by registry host, e.g. "ghcr.io" or "docker.io". They are stored
+// in plain text in ~/.vessel/registries.yml, readable only by the current user, unless they're
+// read from Docker's credentials.
+type RegistryCredentials struct {
+	Registries map[string]RegistryCredential `yaml:"registries"`
+}
+
+// Get returns the credential for a registry host, if one is stored
+func (r *RegistryCredentials) Get(host string) (*RegistryCredential, bool) {
+	cred, ok := r.Registries[host]
+
+	if !ok {
+		return nil, false
+	}
+
+	return &cred, true
+}
+
+// RetrieveRegistryCredentials reads stored registry credentials.
+// A missing file results in no credentials.
+func RetrieveRegistryCredentials() (*RegistryCredentials, error) {
+	credentialsPath, err := registryCredentialsPath()
+
+	if err != nil {
+		return nil, err
+	}
+
+	creds := &RegistryCredentials{
+		Registries: make(map[string]RegistryCredential),
+	}
+
+	file, err := os.ReadFile(credentialsPath)
+
+	if os.IsNotExist(err) {
+		return creds, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read yaml file '%s': %w", credentialsPath, err)
+	}
+
+	err = yaml.Unmarshal(file, creds)
+
+	if err != nil {
+		return nil, fmt.Errorf("error parsing yaml file %s: %w", credentialsPath, err)
+	}
+
+	if creds.Registries == nil {
+		creds.Registries = make(map[string]RegistryCredential)
+	}
+
+	return creds, nil
+}
+
+// SaveRegistryCredentials writes registry credentials, readable only by the current user
+func SaveRegistryCredentials(creds *RegistryCredentials) error {
+	credentialsPath, err := registryCredentialsPath()
+
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(creds)
+
+	if err != nil {
+		return fmt.Errorf("could not marshal registry credentials: %w", err)
+	}
+
+	if err = os.WriteFile(credentialsPath, data, 0600); err != nil {
+		return fmt.Errorf("could not write registry credentials file '%s': %w", credentialsPath, err)
+	}
+
+	// WriteFile does not change the permissions of an existing file
+	if err = os.Chmod(credentialsPath, 0600); err != nil {
+		return fmt.Errorf("could not set permissions of registry credentials file '%s': %w", credentialsPath, err)
+	}
+
+	return nil
+}
+
+func registryCredentialsPath() (string, error) {
+	vesselDir, err := util.MakeStorageDir()
+
+	if err != nil {
+		return "", fmt.Errorf("could not create vessel storage dir: %w", err)
+	}
+
+	return filepath.FromSlash(vesselDir + "/registries.yml"), nil
+}
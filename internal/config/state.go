@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"github.com/vessel-app/vessel-cli/internal/util"
+	"gopkg.in/yaml.v3"
+	"os"
+	"path/filepath"
+)
+
+// EnvironmentState tracks resources Vessel created for an environment, so they
+// can be managed later. It is stored in ~/.vessel/envs/<app-name>/state.yml
+type EnvironmentState struct {
+	Machine string   `yaml:"machine,omitempty"`
+	Volumes []string `yaml:"volumes,omitempty"`
+	// Sidecars maps sidecar names to their machine IDs
+	Sidecars map[string]string `yaml:"sidecars,omitempty"`
+	// VesselEnvironment is the ID of an environment created through the hosted Vessel API
+	VesselEnvironment uint64 `yaml:"vessel_environment,omitempty"`
+	// WireGuard is the peer used to reach the environment over Fly's private network
+	WireGuard *WireGuardState `yaml:"wireguard,omitempty"`
+}
+
+// WireGuardState is a WireGuard peer created in the environment's Fly organization
+type WireGuardState struct {
+	Name string `yaml:"name"`
+	// PrivateKey is our (base64) private key, its public key was given to Fly
+	PrivateKey string `yaml:"private_key"`
+	// PeerIp is our address within the private network
+	PeerIp     string `yaml:"peer_ip"`
+	EndpointIp string `yaml:"endpoint_ip"`
+	// PublicKey is the (base64) public key of Fly's WireGuard gateway
+	PublicKey string `yaml:"public_key"`
+}
+
+// RetrieveEnvironmentState reads the state of an environment. A missing state file
+// results in an empty state, as older environments were created without one.
+func RetrieveEnvironmentState(appName string) (*EnvironmentState, error) {
+	statePath, err := environmentStatePath(appName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	state := &EnvironmentState{}
+
+	file, err := os.ReadFile(statePath)
+
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read yaml file '%s': %w", statePath, err)
+	}
+
+	err = yaml.Unmarshal(file, state)
+
+	if err != nil {
+		return nil, fmt.Errorf("error parsing yaml file %s: %w", statePath, err)
+	}
+
+	return state, nil
+}
+
+// SaveEnvironmentState writes the state of an environment to its env directory
+func SaveEnvironmentState(appName string, state *EnvironmentState) error {
+	statePath, err := environmentStatePath(appName)
+
+	if err != nil {
+		return err
+	}
+
+	data, err := yaml.Marshal(state)
+
+	if err != nil {
+		return fmt.Errorf("could not marshal environment state: %w", err)
+	}
+
+	if err = os.WriteFile(statePath, data, 0600); err != nil {
+		return fmt.Errorf("could not write environment state file '%s': %w", statePath, err)
+	}
+
+	// WriteFile does not change the permissions of an existing file, which holds a WireGuard private key
+	if err = os.Chmod(statePath, 0600); err != nil {
+		return fmt.Errorf("could not set permissions of environment state file '%s': %w", statePath, err)
+	}
+
+	return nil
+}
+
+// DeleteEnvironmentState removes the state file of an environment, if there is one
+func DeleteEnvironmentState(appName string) error {
+	statePath, err := environmentStatePath(appName)
+
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(statePath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not delete environment state file '%s': %w", statePath, err)
+	}
+
+	return nil
+}
+
+// EnvironmentKnownHostsPath is the known_hosts file pinning the host key of an environment's machine,
+// which is trusted when Vessel or ssh first connects to it
+func EnvironmentKnownHostsPath(appName string) (string, error) {
+	appEnvDir, err := util.GetAppEnvDir(appName)
+
+	if err != nil {
+		return "", fmt.Errorf("could not find environment directory: %w", err)
+	}
+
+	return filepath.FromSlash(appEnvDir + "/known_hosts"), nil
+}
+
+// ForgetEnvironmentHostKey removes the pinned host key of an environment, e.g. once its machine is
+// replaced, so the new machine's key is trusted on the next connection
+func ForgetEnvironmentHostKey(appName string) error {
+	knownHostsPath, err := EnvironmentKnownHostsPath(appName)
+
+	if err != nil {
+		return err
+	}
+
+	if err = os.Remove(knownHostsPath); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("could not delete known hosts file '%s': %w", knownHostsPath, err)
+	}
+
+	return nil
+}
+
+func environmentStatePath(appName string) (string, error) {
+	appEnvDir, err := util.GetAppEnvDir(appName)
+
+	if err != nil {
+		return "", fmt.Errorf("could not find environment directory: %w", err)
+	}
+
+	return filepath.FromSlash(appEnvDir + "/state.yml"), nil
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"os"
+	"runtime"
+	"testing"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/vessel-app/vessel-cli/internal/util"
+)
+
+func TestSaveEnvironmentStateRestrictsExistingFile(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("file permissions aren't enforced on windows")
+	}
+
+	t.Setenv("HOME", t.TempDir())
+	homedir.DisableCache = true
+	t.Cleanup(func() { homedir.DisableCache = false })
+
+	if _, err := util.MakeAppDir("state"); err != nil {
+		t.Fatal(err)
+	}
+
+	statePath, err := environmentStatePath("state")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A state file written before it held secrets
+	if err = os.WriteFile(statePath, []byte("machine: m1\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = SaveEnvironmentState("state", &EnvironmentState{Machine: "m1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	info, err := os.Stat(statePath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if info.Mode().Perm() != 0600 {
+		t.Errorf("expected the state file to be readable only by its owner, got %s", info.Mode().Perm())
+	}
+}
@@ -1,6 +1,10 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
 
 type FlyConfig struct {
 	Token string `yaml:"access_token"`
@@ -9,14 +13,26 @@ type FlyConfig struct {
 type AuthConfig struct {
 	Token string `yaml:"access_token"`
 	Org   string `yaml:"org"`
+	// Catalog is a path or URL to a team's image catalog
+	Catalog string `yaml:"catalog,omitempty"`
+	// VesselToken and VesselTeam (the team's GUID) are used with the hosted Vessel API
+	VesselToken string `yaml:"vessel_token,omitempty"`
+	VesselTeam  string `yaml:"vessel_team,omitempty"`
 }
 
 type EnvironmentConfig struct {
-	Name       string       `yaml:"name"`
-	Image      string       `yaml:"image"`
-	Remote     RemoteConfig `yaml:"remote"`
-	Forwarding []string     `yaml:"forwarding"`
-	Ignore     []string     `yaml:"ignore"`
+	Name string `yaml:"name"`
+	// Provider is the backend the environment runs on, defaults to "fly"
+	Provider   string            `yaml:"provider,omitempty"`
+	Image      string            `yaml:"image,omitempty"`
+	Remote     RemoteConfig      `yaml:"remote"`
+	Forwarding []string          `yaml:"forwarding"`
+	Ignore     []string          `yaml:"ignore,omitempty"`
+	Machine    MachineConfig     `yaml:"machine,omitempty"`
+	Volumes    []VolumeConfig    `yaml:"volumes,omitempty"`
+	Env        map[string]string `yaml:"env,omitempty"`
+	Services   []ServiceConfig   `yaml:"services,omitempty"`
+	Sidecars   []SidecarConfig   `yaml:"sidecars,omitempty"`
 }
 
 type RemoteConfig struct {
@@ -26,6 +42,184 @@ type RemoteConfig struct {
 	Port         int    `yaml:"port"`
 	RemotePath   string `yaml:"path"`
 	Alias        string `yaml:"alias,omitempty"`
+	// WireGuard reaches the host through a userspace WireGuard tunnel
+	// into Fly's private network, instead of a public IP address
+	WireGuard bool `yaml:"wireguard,omitempty"`
+}
+
+// MachineConfig is the size of the machine running the dev environment.
+// Zero values are left out, letting Fly use its defaults.
+type MachineConfig struct {
+	CpuKind  string `yaml:"cpu_kind,omitempty"`
+	Cpus     int    `yaml:"cpus,omitempty"`
+	MemoryMb int    `yaml:"memory_mb,omitempty"`
+}
+
+func (m *MachineConfig) Valid() (bool, error) {
+	if m.CpuKind != "" && m.CpuKind != "shared" && m.CpuKind != "performance" {
+		return false, fmt.Errorf("machine cpu_kind must be 'shared' or 'performance', got '%s'", m.CpuKind)
+	}
+
+	if m.Cpus < 0 {
+		return false, fmt.Errorf("machine cpus cannot be negative")
+	}
+
+	if m.MemoryMb < 0 || m.MemoryMb%256 != 0 {
+		return false, fmt.Errorf("machine memory_mb must be a multiple of 256, got %d", m.MemoryMb)
+	}
+
+	return true, nil
+}
+
+// VolumeConfig is a persistent Fly volume mounted into the dev environment,
+// so files at Path survive the machine being stopped and started.
+type VolumeConfig struct {
+	Name   string `yaml:"name"`
+	Path   string `yaml:"path"`
+	SizeGb int    `yaml:"size_gb,omitempty"`
+}
+
+// volumeNamePattern matches the volume names Fly accepts
+var volumeNamePattern = regexp.MustCompile(`^[a-z0-9_]{1,30}$`)
+
+func (v *VolumeConfig) Valid() (bool, error) {
+	if !volumeNamePattern.MatchString(v.Name) {
+		return false, fmt.Errorf("volume name '%s' must be 1-30 lowercase letters, numbers or underscores", v.Name)
+	}
+
+	if len(v.Path) < 2 || v.Path[0] != '/' {
+		return false, fmt.Errorf("volume '%s' must be mounted at an absolute path", v.Name)
+	}
+
+	if v.SizeGb < 0 {
+		return false, fmt.Errorf("volume '%s' size_gb cannot be negative", v.Name)
+	}
+
+	return true, nil
+}
+
+// ServiceConfig publishes a port within the dev environment on its public IP address.
+// Handlers (e.g. "tls", "http") are applied to connections by Fly's proxy.
+type ServiceConfig struct {
+	Protocol     string       `yaml:"protocol,omitempty"`
+	InternalPort int          `yaml:"internal_port"`
+	Ports        []PortConfig `yaml:"ports"`
+}
+
+type PortConfig struct {
+	Port     int      `yaml:"port"`
+	Handlers []string `yaml:"handlers,omitempty"`
+}
+
+var serviceHandlers = []string{"tls", "http", "proxy_proto", "pg_tls"}
+
+func (s *ServiceConfig) Valid() (bool, error) {
+	if s.Protocol != "" && s.Protocol != "tcp" && s.Protocol != "udp" {
+		return false, fmt.Errorf("service protocol must be 'tcp' or 'udp', got '%s'", s.Protocol)
+	}
+
+	if s.InternalPort < 1 || s.InternalPort > 65535 {
+		return false, fmt.Errorf("invalid service internal_port %d", s.InternalPort)
+	}
+
+	if len(s.Ports) == 0 {
+		return false, fmt.Errorf("service for internal_port %d has no public ports", s.InternalPort)
+	}
+
+	for _, p := range s.Ports {
+		if p.Port < 1 || p.Port > 65535 {
+			return false, fmt.Errorf("invalid public port %d for internal_port %d", p.Port, s.InternalPort)
+		}
+
+		for _, h := range p.Handlers {
+			if !contains(serviceHandlers, h) {
+				return false, fmt.Errorf("unknown handler '%s' for public port %d", h, p.Port)
+			}
+		}
+	}
+
+	return true, nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SidecarConfig is a service (such as a database) run on its own machine within the
+// dev environment's Fly app. Sidecars are only reachable over Fly's private network.
+type SidecarConfig struct {
+	Name    string            `yaml:"name"`
+	Image   string            `yaml:"image"`
+	Env     map[string]string `yaml:"env,omitempty"`
+	Machine MachineConfig     `yaml:"machine,omitempty"`
+	Volume  *VolumeConfig     `yaml:"volume,omitempty"`
+	// Forwarding forwards local ports to the sidecar, as local_port:sidecar_port
+	Forwarding []string `yaml:"forwarding,omitempty"`
+}
+
+// sidecarNamePattern keeps sidecar names usable in machine names and env variable names
+var sidecarNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_]{0,29}$`)
+
+func (s *SidecarConfig) Valid() (bool, error) {
+	if !sidecarNamePattern.MatchString(s.Name) {
+		return false, fmt.Errorf("sidecar name '%s' must be 1-30 lowercase letters, numbers or underscores, starting with a letter", s.Name)
+	}
+
+	if len(s.Image) == 0 {
+		return false, fmt.Errorf("sidecar '%s' has no image defined", s.Name)
+	}
+
+	if valid, err := s.Machine.Valid(); !valid {
+		return false, fmt.Errorf("sidecar '%s': %w", s.Name, err)
+	}
+
+	if s.Volume != nil {
+		if valid, err := s.Volume.Valid(); !valid {
+			return false, fmt.Errorf("sidecar '%s': %w", s.Name, err)
+		}
+	}
+
+	if valid, err := ValidEnv(s.Env); !valid {
+		return false, fmt.Errorf("sidecar '%s': %w", s.Name, err)
+	}
+
+	for _, f := range s.Forwarding {
+		if len(strings.Split(f, ":")) != 2 {
+			return false, fmt.Errorf("invalid forwarding '%s' for sidecar '%s', expected local_port:sidecar_port", f, s.Name)
+		}
+	}
+
+	return true, nil
+}
+
+// envKeyPattern matches valid environment variable names
+var envKeyPattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+
+// ValidEnv checks environment variables set in the dev environment. VESSEL_PUBLIC_KEY
+// is reserved, as it's used to install the SSH key into the environment. VESSEL_SIDECAR_
+// variables are reserved for the hostnames of sidecars.
+func ValidEnv(env map[string]string) (bool, error) {
+	for k := range env {
+		if !envKeyPattern.MatchString(k) {
+			return false, fmt.Errorf("invalid env variable name '%s'", k)
+		}
+
+		if k == "VESSEL_PUBLIC_KEY" {
+			return false, fmt.Errorf("env variable VESSEL_PUBLIC_KEY is reserved by Vessel")
+		}
+
+		if strings.HasPrefix(k, "VESSEL_SIDECAR_") {
+			return false, fmt.Errorf("env variable '%s' is reserved by Vessel for sidecars", k)
+		}
+	}
+
+	return true, nil
 }
 
 func (c *EnvironmentConfig) Valid() (bool, error) {
@@ -57,5 +251,57 @@ func (c *EnvironmentConfig) Valid() (bool, error) {
 		return false, fmt.Errorf("no remote port (to SSH into) defined")
 	}
 
+	if valid, err := c.Machine.Valid(); !valid {
+		return false, err
+	}
+
+	// Fly machines can only mount a single volume
+	if len(c.Volumes) > 1 {
+		return false, fmt.Errorf("only one volume can be mounted into the dev environment")
+	}
+
+	for _, v := range c.Volumes {
+		if valid, err := v.Valid(); !valid {
+			return false, err
+		}
+	}
+
+	if valid, err := ValidEnv(c.Env); !valid {
+		return false, err
+	}
+
+	for _, svc := range c.Services {
+		if valid, err := svc.Valid(); !valid {
+			return false, err
+		}
+	}
+
+	// Volumes are matched to machines by name, so names must be unique
+	volumes := make([]string, 0, len(c.Volumes)+len(c.Sidecars))
+	for _, v := range c.Volumes {
+		volumes = append(volumes, v.Name)
+	}
+
+	sidecars := make([]string, 0, len(c.Sidecars))
+	for _, sc := range c.Sidecars {
+		if valid, err := sc.Valid(); !valid {
+			return false, err
+		}
+
+		if contains(sidecars, sc.Name) {
+			return false, fmt.Errorf("sidecar name '%s' is used more than once", sc.Name)
+		}
+
+		sidecars = append(sidecars, sc.Name)
+
+		if sc.Volume != nil {
+			if contains(volumes, sc.Volume.Name) {
+				return false, fmt.Errorf("volume name '%s' is used more than once", sc.Volume.Name)
+			}
+
+			volumes = append(volumes, sc.Volume.Name)
+		}
+	}
+
 	return true, nil
 }
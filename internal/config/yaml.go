@@ -1,6 +1,7 @@
 package config
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/mitchellh/go-homedir"
 	"gopkg.in/yaml.v3"
@@ -35,6 +36,23 @@ func RetrieveProjectConfig(path string) (*EnvironmentConfig, error) {
 	return cfg, nil
 }
 
+// WriteProjectConfig writes a project's vessel.yml file
+func WriteProjectConfig(path string, cfg *EnvironmentConfig) error {
+	var out bytes.Buffer
+	encoder := yaml.NewEncoder(&out)
+	encoder.SetIndent(2)
+
+	if err := encoder.Encode(cfg); err != nil {
+		return fmt.Errorf("could not marshal project config: %w", err)
+	}
+
+	if err := os.WriteFile(path, out.Bytes(), 0644); err != nil {
+		return fmt.Errorf("could not write yaml file '%s': %w", path, err)
+	}
+
+	return nil
+}
+
 func RetrieveVesselConfig() (*AuthConfig, error) {
 	home, err := homedir.Dir()
 
@@ -49,6 +67,13 @@ func RetrieveVesselConfig() (*AuthConfig, error) {
 		return nil, fmt.Errorf("could not read yaml file '%s': %w", configPath, err)
 	}
 
+	// The file holds API tokens, but was once written readable by everyone
+	if info, err := os.Stat(configPath); err == nil && info.Mode().Perm()&0077 != 0 {
+		if err = os.Chmod(configPath, 0600); err != nil {
+			return nil, fmt.Errorf("could not set permissions of '%s': %w", configPath, err)
+		}
+	}
+
 	cfg := &AuthConfig{}
 
 	err = yaml.Unmarshal(file, cfg)
@@ -0,0 +1,374 @@
+package environments
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+)
+
+// MachinePlan describes the changes needed to bring a machine's config in line with vessel.yml
+type MachinePlan struct {
+	Current fly.MachineConfig
+	Desired fly.MachineConfig
+	// NewVolumes are created before the machine is updated to mount them
+	NewVolumes []config.VolumeConfig
+	// PrivateImage is copied into Fly's registry before the machine is updated to run it
+	PrivateImage string
+	// NewSidecars are created before the machine is updated to point at them
+	NewSidecars []config.SidecarConfig
+	// RemovedSidecars maps the names of sidecars no longer in vessel.yml to their machine IDs
+	RemovedSidecars map[string]string
+	// Changes are human-readable lines describing the difference
+	Changes []string
+}
+
+// HasChanges determines if applying the plan would change the machine
+func (p *MachinePlan) HasChanges() bool {
+	return len(p.Changes) > 0
+}
+
+// PlanMachineUpdate compares the desired state in vessel.yml with the machine's current config.
+// Settings not defined in vessel.yml keep their current value, as do settings Vessel doesn't
+// manage, such as the machine's restart policy, metadata and checks. The SSH public key is always
+// kept, so the environment stays reachable with the same keys. Sidecars are created or removed
+// to match vessel.yml, but existing sidecars are left as they are.
+func PlanMachineUpdate(token string, cfg *config.EnvironmentConfig, machine *fly.Machine) (*MachinePlan, error) {
+	current := machine.Config
+
+	sidecars, err := SidecarIds(cfg.Name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	var newSidecars []config.SidecarConfig
+	keptSidecars := make(map[string]string)
+	for _, sc := range cfg.Sidecars {
+		if id, exists := sidecars[sc.Name]; exists {
+			keptSidecars[sc.Name] = id
+		} else {
+			newSidecars = append(newSidecars, sc)
+		}
+	}
+
+	removedSidecars := make(map[string]string)
+	for name, id := range sidecars {
+		if _, kept := keptSidecars[name]; !kept {
+			removedSidecars[name] = id
+		}
+	}
+
+	image := current.Image
+	privateImage := ""
+	if len(cfg.Image) > 0 {
+		var private bool
+		var err error
+		if image, private, err = imageToRun(cfg.Name, cfg.Image); err != nil {
+			return nil, err
+		}
+
+		// Only copy a private image when the machine isn't already running it
+		if private && image != current.Image {
+			privateImage = cfg.Image
+		}
+	}
+
+	guest := guestFromConfig(&cfg.Machine)
+	if guest == nil {
+		guest = current.Guest
+	} else if current.Guest != nil {
+		guest.Extra = current.Guest.Extra
+	}
+
+	// Mount existing volumes by name, noting which volumes still need to be created
+	existing, err := fly.ListVolumes(token, cfg.Name)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not list volumes: %w", err)
+	}
+
+	var mounts []fly.Mount
+	var newVolumes []config.VolumeConfig
+	for _, v := range cfg.Volumes {
+		volumeId := ""
+		for _, e := range existing {
+			if e.Name == v.Name {
+				volumeId = e.Id
+				break
+			}
+		}
+
+		if len(volumeId) == 0 {
+			newVolumes = append(newVolumes, v)
+		}
+
+		mounts = append(mounts, fly.Mount{
+			Volume: volumeId,
+			Path:   v.Path,
+		})
+	}
+
+	env := sidecarEnv(cfg.Name, keptSidecars)
+	for k, v := range cfg.Env {
+		env[k] = v
+	}
+
+	desired := machineConfig(image, current.Env["VESSEL_PUBLIC_KEY"], env, guest, servicesFromConfig(cfg.Services), mounts)
+	desired.Extra = current.Extra
+
+	plan := &MachinePlan{
+		Current:         current,
+		Desired:         *desired,
+		NewVolumes:      newVolumes,
+		PrivateImage:    privateImage,
+		NewSidecars:     newSidecars,
+		RemovedSidecars: removedSidecars,
+	}
+
+	plan.Changes = diffMachineConfig(&plan.Current, &plan.Desired, newVolumes)
+
+	for _, sc := range newSidecars {
+		plan.Changes = append(plan.Changes, fmt.Sprintf("+ sidecar: create %s (%s)", sc.Name, sc.Image))
+	}
+
+	for _, name := range sortedSidecars(removedSidecars) {
+		plan.Changes = append(plan.Changes, fmt.Sprintf("- sidecar: remove %s", name))
+	}
+
+	return plan, nil
+}
+
+// ApplyMachinePlan creates any new volumes and sidecars, removes sidecars no longer needed,
+// and updates the machine with the desired config. The IP address, app and SSH keys of the
+// environment are left as they are.
+func ApplyMachinePlan(token, appName string, machine *fly.Machine, plan *MachinePlan, lease fly.LeaseOptions) (*fly.Machine, error) {
+	state, err := config.RetrieveEnvironmentState(appName)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read environment state: %w", err)
+	}
+
+	if len(plan.PrivateImage) > 0 {
+		if _, err = mirrorPrivateImage(token, appName, plan.PrivateImage); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range plan.NewVolumes {
+		sizeGb := v.SizeGb
+		if sizeGb == 0 {
+			sizeGb = defaultVolumeSizeGb
+		}
+
+		// Volumes must be in the same region as the machine mounting them
+		volume, err := fly.CreateVolume(token, appName, v.Name, machine.Region, sizeGb)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not create volume '%s': %w", v.Name, err)
+		}
+
+		state.Volumes = append(state.Volumes, volume.Id)
+
+		for i, m := range plan.Desired.Mounts {
+			if m.Path == v.Path && len(m.Volume) == 0 {
+				plan.Desired.Mounts[i].Volume = volume.Id
+			}
+		}
+	}
+
+	if state.Sidecars == nil {
+		state.Sidecars = make(map[string]string)
+	}
+
+	for i := range plan.NewSidecars {
+		sidecar := &plan.NewSidecars[i]
+		sidecarId, volumeId, err := createSidecar(token, appName, machine.Region, sidecar)
+
+		if err != nil {
+			return nil, err
+		}
+
+		state.Sidecars[sidecar.Name] = sidecarId
+		if len(volumeId) > 0 {
+			state.Volumes = append(state.Volumes, volumeId)
+		}
+
+		plan.Desired.Env[sidecarEnvKey(sidecar.Name)] = SidecarHost(appName, sidecar.Name)
+	}
+
+	// A removed sidecar's volume is kept until the environment is destroyed
+	for _, name := range sortedSidecars(plan.RemovedSidecars) {
+		if err = fly.DeleteMachine(token, appName, plan.RemovedSidecars[name], true, lease); err != nil {
+			return nil, fmt.Errorf("could not remove sidecar '%s': %w", name, err)
+		}
+
+		delete(state.Sidecars, name)
+	}
+
+	if len(plan.NewVolumes) > 0 || len(plan.NewSidecars) > 0 || len(plan.RemovedSidecars) > 0 {
+		if err = config.SaveEnvironmentState(appName, state); err != nil {
+			return nil, fmt.Errorf("could not save environment state: %w", err)
+		}
+	}
+
+	updated, err := fly.UpdateMachine(token, appName, machine.Id, &plan.Desired, lease)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not update machine: %w", err)
+	}
+
+	return updated, nil
+}
+
+// machineConfig builds the config of a dev environment's machine. The env variables
+// from vessel.yml are merged with the public key Vessel uses to SSH into the machine.
+func machineConfig(image, pubKey string, env map[string]string, guest *fly.Guest, services []fly.Service, mounts []fly.Mount) *fly.MachineConfig {
+	machineEnv := make(map[string]string, len(env)+1)
+	for k, v := range env {
+		machineEnv[k] = v
+	}
+	machineEnv["VESSEL_PUBLIC_KEY"] = strings.TrimSpace(pubKey)
+
+	return &fly.MachineConfig{
+		Image:    image,
+		Env:      machineEnv,
+		Guest:    guest,
+		Services: services,
+		Mounts:   mounts,
+	}
+}
+
+func diffMachineConfig(current, desired *fly.MachineConfig, newVolumes []config.VolumeConfig) []string {
+	changes := make([]string, 0)
+
+	if current.Image != desired.Image {
+		changes = append(changes, fmt.Sprintf("~ image: %s -> %s", current.Image, desired.Image))
+	}
+
+	changes = append(changes, diffEnv(current.Env, desired.Env)...)
+
+	if !reflect.DeepEqual(current.Guest, desired.Guest) {
+		changes = append(changes, fmt.Sprintf("~ machine: %s -> %s", describeGuest(current.Guest), describeGuest(desired.Guest)))
+	}
+
+	changes = append(changes, diffLines("service", describeServices(current.Services), describeServices(desired.Services))...)
+
+	for _, v := range newVolumes {
+		changes = append(changes, fmt.Sprintf("+ volume: create %s", v.Name))
+	}
+
+	changes = append(changes, diffLines("mount", describeMounts(current.Mounts), describeMounts(desired.Mounts))...)
+
+	return changes
+}
+
+func diffEnv(current, desired map[string]string) []string {
+	keys := make([]string, 0)
+	for k := range current {
+		keys = append(keys, k)
+	}
+	for k := range desired {
+		if _, ok := current[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	changes := make([]string, 0)
+	for _, k := range keys {
+		// Sidecar hostnames are described by sidecar changes instead
+		if strings.HasPrefix(k, sidecarEnvPrefix) {
+			continue
+		}
+
+		c, inCurrent := current[k]
+		d, inDesired := desired[k]
+
+		// Values are left out, as they may be credentials
+		switch {
+		case !inDesired:
+			changes = append(changes, fmt.Sprintf("- env: %s", k))
+		case !inCurrent:
+			changes = append(changes, fmt.Sprintf("+ env: %s", k))
+		case c != d:
+			changes = append(changes, fmt.Sprintf("~ env: %s (value changed)", k))
+		}
+	}
+
+	return changes
+}
+
+// diffLines reports lines removed from current and added in desired
+func diffLines(kind string, current, desired []string) []string {
+	changes := make([]string, 0)
+
+	for _, c := range current {
+		if !contains(desired, c) {
+			changes = append(changes, fmt.Sprintf("- %s: %s", kind, c))
+		}
+	}
+
+	for _, d := range desired {
+		if !contains(current, d) {
+			changes = append(changes, fmt.Sprintf("+ %s: %s", kind, d))
+		}
+	}
+
+	return changes
+}
+
+func contains(lines []string, line string) bool {
+	for _, l := range lines {
+		if l == line {
+			return true
+		}
+	}
+
+	return false
+}
+
+func describeGuest(g *fly.Guest) string {
+	if g == nil {
+		return "default size"
+	}
+
+	return fmt.Sprintf("%s, %d cpus, %dMB", g.CpuKind, g.Cpus, g.MemoryMb)
+}
+
+func describeServices(services []fly.Service) []string {
+	described := make([]string, 0, len(services))
+
+	for _, s := range services {
+		ports := make([]string, 0, len(s.Ports))
+		for _, p := range s.Ports {
+			if len(p.Handlers) > 0 {
+				ports = append(ports, fmt.Sprintf("%d [%s]", p.Port, strings.Join(p.Handlers, ",")))
+			} else {
+				ports = append(ports, fmt.Sprintf("%d", p.Port))
+			}
+		}
+
+		described = append(described, fmt.Sprintf("%s %d -> %s", s.Protocol, s.InternalPort, strings.Join(ports, ", ")))
+	}
+
+	return described
+}
+
+func describeMounts(mounts []fly.Mount) []string {
+	described := make([]string, 0, len(mounts))
+
+	for _, m := range mounts {
+		volume := m.Volume
+		if len(volume) == 0 {
+			volume = "(new volume)"
+		}
+
+		described = append(described, fmt.Sprintf("%s at %s", volume, m.Path))
+	}
+
+	return described
+}
@@ -0,0 +1,114 @@
+package environments
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+)
+
+func TestApplyMachinePlanKeepsUnmanagedSettings(t *testing.T) {
+	server := newFlyTest(t)
+	_, env := createFlyEnvironment(t, "apply")
+
+	machine, err := fly.GetMachine("token", "apply", env.FlyMachine)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Settings Vessel doesn't manage, e.g. set with flyctl
+	cfg := machine.Config
+	cfg.Extra = map[string]json.RawMessage{
+		"restart":  json.RawMessage(`{"policy":"always"}`),
+		"metadata": json.RawMessage(`{"team":"backend"}`),
+	}
+	cfg.Guest = &fly.Guest{CpuKind: "shared", Cpus: 1, MemoryMb: 256, Extra: map[string]json.RawMessage{
+		"kernel_args": json.RawMessage(`["quiet"]`),
+	}}
+
+	if machine, err = fly.UpdateMachine("token", "apply", env.FlyMachine, &cfg, fly.LeaseOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	project := applyProject("apply")
+	plan, err := PlanMachineUpdate("token", project, machine)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = ApplyMachinePlan("token", "apply", machine, plan, fly.LeaseOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	updated, _ := server.Machine("apply", env.FlyMachine)
+
+	if updated.Config.Guest == nil || updated.Config.Guest.MemoryMb != 1024 {
+		t.Errorf("expected the machine size to be updated, got %+v", updated.Config.Guest)
+	}
+
+	if updated.Config.Env["APP_ENV"] != "local" {
+		t.Errorf("expected env to be updated, got %v", updated.Config.Env)
+	}
+
+	expected := map[string]string{
+		"restart":  `{"policy":"always"}`,
+		"metadata": `{"team":"backend"}`,
+	}
+
+	for k, v := range expected {
+		if string(updated.Config.Extra[k]) != v {
+			t.Errorf("expected %s to be kept as %s, got %s", k, v, updated.Config.Extra[k])
+		}
+	}
+
+	if string(updated.Config.Guest.Extra["kernel_args"]) != `["quiet"]` {
+		t.Errorf("expected guest kernel_args to be kept, got %v", updated.Config.Guest.Extra)
+	}
+}
+
+func TestPlanMachineUpdateMasksEnvValues(t *testing.T) {
+	newFlyTest(t)
+	_, env := createFlyEnvironment(t, "masked")
+
+	machine, err := fly.GetMachine("token", "masked", env.FlyMachine)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	project := applyProject("masked")
+	project.Env["API_KEY"] = "super-secret"
+
+	plan, err := PlanMachineUpdate("token", project, machine)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	changes := strings.Join(plan.Changes, "\n")
+
+	if strings.Contains(changes, "super-secret") || strings.Contains(changes, "local") {
+		t.Errorf("expected env values to be masked, got:\n%s", changes)
+	}
+
+	if !strings.Contains(changes, "+ env: API_KEY") {
+		t.Errorf("expected API_KEY to be added, got:\n%s", changes)
+	}
+}
+
+// applyProject matches the environment created by createFlyEnvironment, with a bigger machine and env
+func applyProject(name string) *config.EnvironmentConfig {
+	return &config.EnvironmentConfig{
+		Name:    name,
+		Image:   "vesselapp/php:8.2",
+		Machine: config.MachineConfig{CpuKind: "shared", Cpus: 1, MemoryMb: 1024},
+		Env:     map[string]string{"APP_ENV": "local"},
+		Sidecars: []config.SidecarConfig{
+			{Name: "mysql", Image: "mysql:8.0"},
+		},
+	}
+}
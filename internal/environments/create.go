@@ -2,17 +2,36 @@ package environments
 
 import (
 	"fmt"
+	"github.com/vessel-app/vessel-cli/internal/config"
 	"github.com/vessel-app/vessel-cli/internal/fly"
 )
 
+// defaultVolumeSizeGb is used for volumes that don't define a size_gb
+const defaultVolumeSizeGb = 1
+
+// machineName is the name given to the dev environment's machine
+const machineName = "vessel-php"
+
 type Environment struct {
-	FlyApp     string
-	FlyOrg     string
-	FlyIp      string
+	FlyApp string
+	FlyOrg string
+	// Host is where the environment is reached over SSH, e.g. its IP address
+	Host       string
 	FlyMachine string
+	FlyVolumes []string
+	// FlySidecars maps sidecar names to their machine IDs
+	FlySidecars map[string]string
 }
 
-func CreateEnvironment(token, appName, image, org, region, pubKey string, ipv6 bool) (*Environment, error) {
+// CreateEnvironment creates the Fly app, volumes, sidecars and machine of a dev environment as
+// described by the project configuration. The remote settings of the project are not used,
+// as they are only known once the environment (and its IP address) exists. Environments on
+// the private network get no public IP, and are reached at their machine's .internal hostname.
+// If anything fails once the app is created, the app and everything created in it is destroyed,
+// so creating the environment can be retried.
+func CreateEnvironment(token, org, region, pubKey string, ipv6, privateNetwork bool, project *config.EnvironmentConfig) (env *Environment, err error) {
+	appName := project.Name
+
 	// Create App
 	app, err := fly.CreateApp(token, appName, org)
 
@@ -20,24 +39,167 @@ func CreateEnvironment(token, appName, image, org, region, pubKey string, ipv6 b
 		return nil, fmt.Errorf("could not register app: %w", err)
 	}
 
-	// Run Machine (image + env var)
-	machine, err := fly.RunMachine(token, appName, region, image, pubKey)
+	var volumeIds []string
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		if cleanupErr := DestroyEnvironment(token, appName, volumeIds, fly.LeaseOptions{}); cleanupErr != nil {
+			err = fmt.Errorf("%w (could not clean up app %s, delete it with `fly apps destroy %s`: %v)", err, appName, appName, cleanupErr)
+		}
+	}()
+
+	// Private images are copied into Fly's registry, so the machine can pull them
+	image, private, err := imageToRun(appName, project.Image)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if private {
+		if image, err = mirrorPrivateImage(token, appName, project.Image); err != nil {
+			return nil, err
+		}
+	}
+
+	// Create volumes (in the machine's region) before the machine mounts them
+	var mounts []fly.Mount
+	for _, v := range project.Volumes {
+		sizeGb := v.SizeGb
+		if sizeGb == 0 {
+			sizeGb = defaultVolumeSizeGb
+		}
+
+		volume, err := fly.CreateVolume(token, appName, v.Name, region, sizeGb)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not create volume '%s': %w", v.Name, err)
+		}
+
+		volumeIds = append(volumeIds, volume.Id)
+		mounts = append(mounts, fly.Mount{
+			Volume: volume.Id,
+			Path:   v.Path,
+		})
+	}
+
+	// Create sidecars first, so the machine can be told where to find them
+	sidecars := make(map[string]string, len(project.Sidecars))
+	for i := range project.Sidecars {
+		sidecarId, volumeId, err := createSidecar(token, appName, region, &project.Sidecars[i])
+
+		// The volume may have been created even if the sidecar's machine wasn't
+		if len(volumeId) > 0 {
+			volumeIds = append(volumeIds, volumeId)
+		}
+
+		if err != nil {
+			return nil, err
+		}
+
+		sidecars[project.Sidecars[i].Name] = sidecarId
+	}
+
+	machineEnv := sidecarEnv(appName, sidecars)
+	for k, v := range project.Env {
+		machineEnv[k] = v
+	}
+
+	// Run Machine (image + env vars)
+	machine, err := fly.RunMachine(token, appName, machineName, region, machineConfig(image, pubKey, machineEnv, guestFromConfig(&project.Machine), servicesFromConfig(project.Services), mounts))
 
 	if err != nil {
 		return nil, fmt.Errorf("could not run machine: %w", err)
 	}
 
+	host := privateHost(appName, machine.Id)
+
 	// Allocate IP
-	ip, err := fly.AllocateIp(token, appName, ipv6)
+	if !privateNetwork {
+		ip, err := fly.AllocateIp(token, appName, ipv6)
 
-	if err != nil {
-		return nil, fmt.Errorf("could not allocate ip: %w", err)
+		if err != nil {
+			return nil, fmt.Errorf("could not allocate ip: %w", err)
+		}
+
+		host = ip.IpAddress.Address
 	}
 
 	return &Environment{
-		FlyApp:     app.AppName,
-		FlyOrg:     org,
-		FlyIp:      ip.IpAddress.Address,
-		FlyMachine: machine.Id,
+		FlyApp:      app.AppName,
+		FlyOrg:      org,
+		Host:        host,
+		FlyMachine:  machine.Id,
+		FlyVolumes:  volumeIds,
+		FlySidecars: sidecars,
 	}, nil
 }
+
+// guestFromConfig converts the vessel.yml machine size into a Fly machine guest.
+// A nil guest is returned if no size was configured, so Fly uses its defaults.
+func guestFromConfig(size *config.MachineConfig) *fly.Guest {
+	if size == nil || (size.CpuKind == "" && size.Cpus == 0 && size.MemoryMb == 0) {
+		return nil
+	}
+
+	return &fly.Guest{
+		CpuKind:  size.CpuKind,
+		Cpus:     size.Cpus,
+		MemoryMb: size.MemoryMb,
+	}
+}
+
+// servicesFromConfig converts the vessel.yml services into Fly machine services.
+// Environments without a services section keep the services Vessel used to always publish.
+func servicesFromConfig(services []config.ServiceConfig) []fly.Service {
+	if services == nil {
+		return legacyServices()
+	}
+
+	flyServices := make([]fly.Service, 0, len(services))
+	for _, s := range services {
+		protocol := s.Protocol
+		if len(protocol) == 0 {
+			protocol = "tcp"
+		}
+
+		ports := make([]fly.Port, 0, len(s.Ports))
+		for _, p := range s.Ports {
+			ports = append(ports, fly.Port{
+				Port:     p.Port,
+				Handlers: p.Handlers,
+			})
+		}
+
+		flyServices = append(flyServices, fly.Service{
+			Protocol:     protocol,
+			InternalPort: s.InternalPort,
+			Ports:        ports,
+		})
+	}
+
+	return flyServices
+}
+
+// legacyServices exposes SSH (port 2222 within the machine) on port 22,
+// and HTTP on ports 80 and 443
+func legacyServices() []fly.Service {
+	return []fly.Service{
+		{
+			Protocol:     "tcp",
+			InternalPort: 2222,
+			Ports: []fly.Port{
+				{Port: 22},
+			},
+		},
+		{
+			Protocol:     "tcp",
+			InternalPort: 80,
+			Ports: []fly.Port{
+				{Port: 80, Handlers: []string{"http"}},
+				{Port: 443, Handlers: []string{"tls", "http"}},
+			},
+		},
+	}
+}
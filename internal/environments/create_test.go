@@ -0,0 +1,42 @@
+package environments
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly/flytest"
+)
+
+func TestCreateEnvironmentCleansUpOnError(t *testing.T) {
+	server := newFlyTest(t)
+
+	project := &config.EnvironmentConfig{
+		Name:    "half-created",
+		Image:   "vesselapp/php:8.2",
+		Volumes: []config.VolumeConfig{{Name: "data", Path: "/data"}},
+		Sidecars: []config.SidecarConfig{
+			{Name: "mysql", Image: "mysql:8.0", Volume: &config.VolumeConfig{Name: "mysql_data", Path: "/var/lib/mysql"}},
+		},
+	}
+
+	// The sidecar's machine fails, after the app and both volumes were created
+	server.Inject(flytest.Fault{Method: http.MethodPost, Path: "/v1/apps/half-created/machines", Status: http.StatusInternalServerError, Times: 1})
+
+	if _, err := CreateEnvironment("token", "personal", "iad", "ssh-ed25519 AAAA test", true, false, project); err == nil {
+		t.Fatal("expected creating the environment to fail")
+	}
+
+	if server.HasApp("half-created") {
+		t.Error("expected the app to be destroyed")
+	}
+
+	// With nothing left behind, creating the environment can be retried
+	if _, err := CreateEnvironment("token", "personal", "iad", "ssh-ed25519 AAAA test", true, false, project); err != nil {
+		t.Fatalf("could not create environment after cleaning up: %v", err)
+	}
+
+	if !server.HasApp("half-created") {
+		t.Error("expected the app to exist")
+	}
+}
@@ -0,0 +1,70 @@
+package environments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+	"net/http"
+	"time"
+)
+
+// machineStopTimeout is how long a running machine gets to stop before it's deleted by force
+const machineStopTimeout = time.Minute
+
+// DestroyEnvironment deletes the machines and Fly app of an environment, along with the volumes Vessel
+// created for it. Machines (including sidecars) are deleted first, while holding their leases, so a
+// teammate using the environment isn't interrupted. Volumes can't be deleted while a machine has them mounted.
+func DestroyEnvironment(token, appName string, volumes []string, lease fly.LeaseOptions) error {
+	machines, err := fly.ListMachines(token, appName)
+
+	if err != nil {
+		return fmt.Errorf("could not list machines: %w", err)
+	}
+
+	for _, m := range machines.Machines {
+		if err = deleteMachine(token, appName, m.Id, lease); err != nil {
+			return fmt.Errorf("could not delete machine '%s': %w", m.Id, err)
+		}
+	}
+
+	for _, v := range volumes {
+		if err = fly.DeleteVolume(token, appName, v); err != nil {
+			return fmt.Errorf("could not delete volume '%s': %w", v, err)
+		}
+	}
+
+	if err = fly.DeleteApp(token, appName); err != nil {
+		return fmt.Errorf("could not delete app: %w", err)
+	}
+
+	return nil
+}
+
+// deleteMachine deletes a machine, stopping it first if it's running. The delete is only
+// forced if the machine doesn't stop in time.
+func deleteMachine(token, appName, machineId string, lease fly.LeaseOptions) error {
+	err := fly.DeleteMachine(token, appName, machineId, false, lease)
+
+	// Fly refuses to delete running machines without force
+	var reqErr *fly.RequestError
+	if err == nil || !errors.As(err, &reqErr) || reqErr.StatusCode != http.StatusPreconditionFailed {
+		return err
+	}
+
+	if err = fly.StopMachine(token, appName, machineId, lease); err != nil {
+		return fmt.Errorf("could not stop machine: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), machineStopTimeout)
+	defer cancel()
+
+	if _, err = fly.WaitForMachineState(ctx, token, appName, machineId, "stopped", nil); err != nil {
+		logger.GetLogger().Debug("caller", "environments.deleteMachine", "msg", "machine did not stop, forcing delete", "machine", machineId, "error", err)
+
+		return fly.DeleteMachine(token, appName, machineId, true, lease)
+	}
+
+	return fly.DeleteMachine(token, appName, machineId, false, lease)
+}
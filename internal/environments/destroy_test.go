@@ -0,0 +1,54 @@
+package environments
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vessel-app/vessel-cli/internal/fly"
+)
+
+func TestDestroyEnvironmentStopsMachinesBeforeDeleting(t *testing.T) {
+	server := newFlyTest(t)
+	_, env := createFlyEnvironment(t, "destroyed")
+
+	// The sidecar is stopped already, the environment's machine is running
+	if err := StopSidecars("token", "destroyed", fly.LeaseOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if _, err := fly.WaitForMachineState(ctx, "token", "destroyed", env.FlySidecars["mysql"], "stopped", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	before := len(server.Requests())
+
+	if err := DestroyEnvironment("token", "destroyed", env.FlyVolumes, fly.LeaseOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if server.HasApp("destroyed") {
+		t.Error("expected the app to be deleted")
+	}
+
+	requests := server.Requests()[before:]
+
+	if !slices.Contains(requests, "POST /v1/apps/destroyed/machines/"+env.FlyMachine+"/stop") {
+		t.Errorf("expected the running machine to be stopped, got %v", requests)
+	}
+
+	if slices.Contains(requests, "POST /v1/apps/destroyed/machines/"+env.FlySidecars["mysql"]+"/stop") {
+		t.Errorf("expected the stopped sidecar to be deleted right away, got %v", requests)
+	}
+
+	for _, r := range requests {
+		if strings.Contains(r, "force=true") {
+			t.Errorf("expected machines to be deleted without force, got %s", r)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package environments
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/wait"
+)
+
+// flyProvider runs each environment as a Fly app, with a machine
+// (plus any sidecars and volumes) created through the Machines API
+type flyProvider struct {
+	token string
+	org   string
+	lease fly.LeaseOptions
+}
+
+// Create destroys the environment again if anything fails after the app was created (such as
+// adding the WireGuard peer, or waiting for the machine), so creating it can be retried.
+func (p *flyProvider) Create(ctx context.Context, opts *CreateOptions, onChange wait.StateFunc) (_ *Environment, err error) {
+	env, err := CreateEnvironment(p.token, p.org, opts.Region, opts.PublicKey, opts.Ipv6, opts.WireGuard, opts.Project)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Track the machine, sidecars and created volumes, so other commands
+	// can manage the machine and `vessel destroy` can remove the volumes
+	state := &config.EnvironmentState{
+		Machine:  env.FlyMachine,
+		Volumes:  env.FlyVolumes,
+		Sidecars: env.FlySidecars,
+	}
+
+	defer func() {
+		if err == nil {
+			return
+		}
+
+		if cleanupErr := p.cleanUp(env.FlyApp, state); cleanupErr != nil {
+			err = fmt.Errorf("%w (could not clean up app %s, delete it with `fly apps destroy %s`: %v)", err, env.FlyApp, env.FlyApp, cleanupErr)
+		}
+	}()
+
+	if err = config.SaveEnvironmentState(env.FlyApp, state); err != nil {
+		return nil, fmt.Errorf("could not save environment state: %w", err)
+	}
+
+	// The peer is saved along with the environment, so the tunnel can be started by later commands
+	if opts.WireGuard {
+		if state.WireGuard, err = createWireGuardPeer(p.token, p.org, opts.Region, env.FlyApp); err != nil {
+			return nil, err
+		}
+
+		if err = config.SaveEnvironmentState(env.FlyApp, state); err != nil {
+			return nil, fmt.Errorf("could not save environment state: %w", err)
+		}
+	}
+
+	if _, err = fly.WaitForMachine(ctx, p.token, env.FlyApp, env.FlyMachine, onChange); err != nil {
+		return nil, err
+	}
+
+	return env, nil
+}
+
+// cleanUp destroys an environment that couldn't be created, along with its WireGuard peer and state
+func (p *flyProvider) cleanUp(appName string, state *config.EnvironmentState) error {
+	if err := DestroyEnvironment(p.token, appName, state.Volumes, p.lease); err != nil {
+		return err
+	}
+
+	if state.WireGuard != nil {
+		if err := fly.RemoveWireGuardPeer(p.token, p.org, state.WireGuard.Name); err != nil {
+			return fmt.Errorf("could not remove wireguard peer: %w", err)
+		}
+	}
+
+	return config.DeleteEnvironmentState(appName)
+}
+
+func (p *flyProvider) Get(name string) (*Status, error) {
+	machineId, err := MachineId(p.token, name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	machine, err := fly.GetMachine(p.token, name, machineId)
+
+	if err != nil {
+		return &Status{Id: machineId}, fmt.Errorf("could not get machine: %w", err)
+	}
+
+	return &Status{
+		Id:     machineId,
+		State:  machine.State,
+		Region: machine.Region,
+	}, nil
+}
+
+// Start starts sidecars first, so they're available to the environment when it starts.
+// Machines that are already running are left alone.
+func (p *flyProvider) Start(ctx context.Context, name string, onChange wait.StateFunc) error {
+	machineId, err := MachineId(p.token, name)
+
+	if err != nil {
+		return err
+	}
+
+	if err = StartSidecars(ctx, p.token, name, p.lease); err != nil {
+		return fmt.Errorf("could not start sidecars: %w", err)
+	}
+
+	if err = startMachine(ctx, p.token, name, machineId, p.lease); err != nil {
+		return fmt.Errorf("could not start machine: %w", err)
+	}
+
+	if _, err = fly.WaitForMachineState(ctx, p.token, name, machineId, "started", onChange); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Stop stops the machine before its sidecars, so it isn't left without them
+func (p *flyProvider) Stop(name string) error {
+	machineId, err := MachineId(p.token, name)
+
+	if err != nil {
+		return err
+	}
+
+	if err = fly.StopMachine(p.token, name, machineId, p.lease); err != nil {
+		return fmt.Errorf("could not stop machine: %w", err)
+	}
+
+	if err = StopSidecars(p.token, name, p.lease); err != nil {
+		return fmt.Errorf("could not stop sidecars: %w", err)
+	}
+
+	return nil
+}
+
+func (p *flyProvider) Destroy(name string) error {
+	state, err := config.RetrieveEnvironmentState(name)
+
+	if err != nil {
+		return fmt.Errorf("could not read environment state: %w", err)
+	}
+
+	if err = DestroyEnvironment(p.token, name, state.Volumes, p.lease); err != nil {
+		return err
+	}
+
+	// WireGuard peers belong to the organization, so they outlive the app
+	if state.WireGuard != nil {
+		if err = fly.RemoveWireGuardPeer(p.token, p.org, state.WireGuard.Name); err != nil {
+			return fmt.Errorf("could not remove wireguard peer: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// Endpoint is the public IP address allocated to the environment's app, or
+// the machine's private hostname if it's reached over WireGuard
+func (p *flyProvider) Endpoint(name string) (string, error) {
+	state, err := config.RetrieveEnvironmentState(name)
+
+	if err != nil {
+		return "", fmt.Errorf("could not read environment state: %w", err)
+	}
+
+	if state.WireGuard != nil && len(state.Machine) > 0 {
+		return privateHost(name, state.Machine), nil
+	}
+
+	ip, err := fly.GetAppIp(p.token, name)
+
+	if err != nil {
+		return "", err
+	}
+
+	return ip.Address, nil
+}
@@ -0,0 +1,34 @@
+package environments
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestFlyProviderStartsRunningEnvironment(t *testing.T) {
+	server := newFlyTest(t)
+	provider, env := createFlyEnvironment(t, "running")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// The environment is running once created, so starting it is a no-op
+	if err := provider.Start(ctx, "running", nil); err != nil {
+		t.Fatalf("could not start running environment: %v", err)
+	}
+
+	if err := provider.Stop("running"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := provider.Start(ctx, "running", nil); err != nil {
+		t.Fatalf("could not start stopped environment: %v", err)
+	}
+
+	m, ok := server.Machine("running", env.FlyMachine)
+
+	if !ok || m.State != "started" {
+		t.Errorf("expected machine to be started, got %v", m)
+	}
+}
@@ -0,0 +1,95 @@
+package environments
+
+import (
+	"fmt"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/registry"
+	"regexp"
+	"strings"
+)
+
+// FlyRegistry is Fly's registry, where each app has its own repository
+const FlyRegistry = "registry.fly.io"
+
+// invalidTagChars are characters that can't be used in an image tag
+var invalidTagChars = regexp.MustCompile(`[^a-zA-Z0-9_.-]`)
+
+// maxTagLength is the longest tag registries accept
+const maxTagLength = 128
+
+// FlyRegistryClient authenticates with Fly's registry using a Fly API token
+func FlyRegistryClient(token string) *registry.Client {
+	return registry.NewClient(registry.BaseUrl(FlyRegistry), "x", token)
+}
+
+// imageToRun determines the image a machine runs. Fly machines can't pull from private registries,
+// so images from registries with saved credentials are copied into the app's repository on
+// Fly's registry. The second return value is true if the image needs to be copied.
+func imageToRun(appName, image string) (string, bool, error) {
+	ref := registry.ParseReference(image)
+
+	if ref.Registry == FlyRegistry {
+		return image, false, nil
+	}
+
+	creds, err := config.RetrieveRegistryCredentials()
+
+	if err != nil {
+		return "", false, fmt.Errorf("could not read registry credentials: %w", err)
+	}
+
+	if _, private := creds.Get(ref.Registry); !private {
+		return image, false, nil
+	}
+
+	return mirroredImage(appName, ref).String(), true, nil
+}
+
+// mirroredImage is where a private image is copied to within the app's repository. The machine
+// and its sidecars share the repository, so the tag is made of the source repository and tag,
+// e.g. ghcr.io/acme/php:latest is copied to registry.fly.io/<app>:acme-php-latest.
+func mirroredImage(appName string, ref registry.Reference) registry.Reference {
+	tag := invalidTagChars.ReplaceAllString(ref.Repository+"-"+ref.Tag, "-")
+
+	// Keep the end of long tags, and tags must start with a letter, digit or underscore
+	if len(tag) > maxTagLength {
+		tag = strings.TrimLeft(tag[len(tag)-maxTagLength:], ".-")
+	}
+
+	return registry.Reference{
+		Registry:   FlyRegistry,
+		Repository: appName,
+		Tag:        tag,
+	}
+}
+
+// mirrorPrivateImage copies a private image into the app's repository on Fly's registry,
+// returning the name of the copied image
+func mirrorPrivateImage(token, appName, image string) (string, error) {
+	ref := registry.ParseReference(image)
+
+	creds, err := config.RetrieveRegistryCredentials()
+
+	if err != nil {
+		return "", fmt.Errorf("could not read registry credentials: %w", err)
+	}
+
+	cred, ok := creds.Get(ref.Registry)
+
+	if !ok {
+		return "", fmt.Errorf("no credentials saved for registry %s", ref.Registry)
+	}
+
+	if cred, err = cred.Resolve(ref.Registry); err != nil {
+		return "", fmt.Errorf("could not read credentials for registry %s: %w", ref.Registry, err)
+	}
+
+	src := registry.NewClient(registry.BaseUrl(ref.Registry), cred.Username, cred.Password)
+	dst := mirroredImage(appName, ref)
+
+	if err = registry.CopyImage(src, ref.Repository, ref.Tag, FlyRegistryClient(token), dst.Repository, dst.Tag); err != nil {
+		return "", fmt.Errorf("could not copy private image %s to %s: %w", image, dst.String(), err)
+	}
+
+	return dst.String(), nil
+}
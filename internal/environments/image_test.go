@@ -0,0 +1,32 @@
+package environments
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/vessel-app/vessel-cli/internal/registry"
+)
+
+func TestMirroredImage(t *testing.T) {
+	tests := map[string]string{
+		"ghcr.io/acme/php:latest":      "registry.fly.io/my-app:acme-php-latest",
+		"ghcr.io/acme/mysql:latest":    "registry.fly.io/my-app:acme-mysql-latest",
+		"docker.io/mysql:8.0":          "registry.fly.io/my-app:library-mysql-8.0",
+		"ghcr.io/acme/php@sha256:abc1": "registry.fly.io/my-app:acme-php-sha256-abc1",
+	}
+
+	for image, expected := range tests {
+		if mirrored := mirroredImage("my-app", registry.ParseReference(image)).String(); mirrored != expected {
+			t.Errorf("%s: expected %s, got %s", image, expected, mirrored)
+		}
+	}
+}
+
+func TestMirroredImageTruncatesLongTags(t *testing.T) {
+	ref := registry.ParseReference("ghcr.io/acme/" + strings.Repeat("a", 200) + ":8.2")
+	tag := mirroredImage("my-app", ref).Tag
+
+	if len(tag) > maxTagLength || !strings.HasSuffix(tag, "-8.2") {
+		t.Errorf("expected a tag of up to %d characters, ending with the source tag, got %s", maxTagLength, tag)
+	}
+}
@@ -0,0 +1,117 @@
+package environments
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+)
+
+// privateHost is the hostname of a machine on Fly's private network
+func privateHost(appName, machineId string) string {
+	return fmt.Sprintf("%s.vm.%s.internal", machineId, appName)
+}
+
+// MachineId returns the ID of an environment's machine from the environment's state.
+// Environments created before the ID was stored fall back to listing the app's
+// machines (skipping sidecars), and the ID found is saved so the lookup happens only once.
+func MachineId(token, appName string) (string, error) {
+	state, err := config.RetrieveEnvironmentState(appName)
+
+	if err != nil {
+		return "", fmt.Errorf("could not read environment state: %w", err)
+	}
+
+	if len(state.Machine) > 0 {
+		return state.Machine, nil
+	}
+
+	machines, err := fly.ListMachines(token, appName)
+
+	if err != nil {
+		return "", fmt.Errorf("could not list machines: %w", err)
+	}
+
+	for _, m := range machines.Machines {
+		if !strings.HasPrefix(m.Name, sidecarMachinePrefix) {
+			state.Machine = m.Id
+			break
+		}
+	}
+
+	if len(state.Machine) == 0 {
+		return "", fmt.Errorf("no machines found for app: %s", appName)
+	}
+
+	if err = config.SaveEnvironmentState(appName, state); err != nil {
+		return "", fmt.Errorf("could not save environment state: %w", err)
+	}
+
+	return state.Machine, nil
+}
+
+// RestartMachine restarts an environment's machine so it picks up changes such as new secrets.
+// A machine that isn't running is left alone, it'll pick up changes when it next starts.
+func RestartMachine(token, appName string, lease fly.LeaseOptions) (bool, error) {
+	machineId, err := MachineId(token, appName)
+
+	if err != nil {
+		return false, err
+	}
+
+	return restartIfStarted(token, appName, machineId, lease)
+}
+
+// restartIfStarted restarts a machine if it's running, reporting whether it was restarted
+func restartIfStarted(token, appName, machineId string, lease fly.LeaseOptions) (bool, error) {
+	machine, err := fly.GetMachine(token, appName, machineId)
+
+	if err != nil {
+		return false, fmt.Errorf("could not get machine: %w", err)
+	}
+
+	if machine.State != "started" {
+		return false, nil
+	}
+
+	if err = fly.RestartMachine(token, appName, machineId, lease); err != nil {
+		return false, fmt.Errorf("could not restart machine: %w", err)
+	}
+
+	return true, nil
+}
+
+// startMachine starts a machine, unless it's already running. Fly refuses to start a machine
+// that isn't stopped with 412 Precondition Failed, so a machine that's stopping is waited on
+// first, and a 412 from a machine that started in between is ignored.
+func startMachine(ctx context.Context, token, appName, machineId string, lease fly.LeaseOptions) error {
+	machine, err := fly.GetMachine(token, appName, machineId)
+
+	if err != nil {
+		return fmt.Errorf("could not get machine: %w", err)
+	}
+
+	switch machine.State {
+	case "started", "starting":
+		return nil
+	case "stopping":
+		if _, err = fly.WaitForMachineState(ctx, token, appName, machineId, "stopped", nil); err != nil {
+			return err
+		}
+	}
+
+	err = fly.StartMachine(token, appName, machineId, lease)
+
+	var reqErr *fly.RequestError
+	if errors.As(err, &reqErr) && reqErr.StatusCode == http.StatusPreconditionFailed {
+		if machine, getErr := fly.GetMachine(token, appName, machineId); getErr == nil && (machine.State == "started" || machine.State == "starting") {
+			return nil
+		}
+	}
+
+	return err
+}
@@ -0,0 +1,94 @@
+package environments
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/remote"
+	"github.com/vessel-app/vessel-cli/internal/wait"
+)
+
+// FlyProvider runs environments as Fly machines, it's the default provider
+const FlyProvider = "fly"
+
+// SshProvider uses an existing host reached over SSH, e.g. a server or VM the user manages
+const SshProvider = "ssh"
+
+// VesselProvider creates environments through the hosted Vessel API
+const VesselProvider = "vessel"
+
+// Provider is a backend dev environments run on, chosen by the provider key of vessel.yml.
+// Environments are identified by their name (the name key of vessel.yml).
+type Provider interface {
+	// Create creates an environment and waits for it to be ready, reporting each
+	// state it passes through to onChange
+	Create(ctx context.Context, opts *CreateOptions, onChange wait.StateFunc) (*Environment, error)
+	// Get returns the current status of an environment
+	Get(name string) (*Status, error)
+	// Start starts an environment and waits for it to be running
+	Start(ctx context.Context, name string, onChange wait.StateFunc) error
+	// Stop stops an environment
+	Stop(name string) error
+	// Destroy deletes an environment, along with everything created for it
+	Destroy(name string) error
+	// Endpoint is the host an environment is reached at over SSH
+	Endpoint(name string) (string, error)
+}
+
+// CreateOptions describe the environment to create
+type CreateOptions struct {
+	Project *config.EnvironmentConfig
+	Region  string
+	// PublicKey is installed in the environment, so we can SSH in
+	PublicKey string
+	Ipv6      bool
+	// WireGuard skips allocating a public IP, the environment is reached
+	// through a WireGuard tunnel into Fly's private network instead
+	WireGuard bool
+	// Password is asked for if the public key can't be installed on an SSH host with ssh-agent
+	Password remote.PasswordFunc
+	// ConfirmHostKey is asked whether to trust an SSH host's key, if it isn't in known_hosts yet
+	ConfirmHostKey remote.HostKeyConfirmFunc
+}
+
+// Status is the state of an environment's machine
+type Status struct {
+	Id     string
+	State  string
+	Region string
+}
+
+// NewProvider returns the provider named by the project's provider key. An empty name is the
+// default provider. The auth config isn't needed by the ssh provider, and may be nil. The
+// lease options are used when the Fly provider changes machines.
+func NewProvider(project *config.EnvironmentConfig, auth *config.AuthConfig, lease fly.LeaseOptions) (Provider, error) {
+	switch project.Provider {
+	case "", FlyProvider:
+		if auth == nil || len(auth.Token) == 0 {
+			return nil, fmt.Errorf("no Fly API token, run `vessel auth` first")
+		}
+
+		return &flyProvider{
+			token: auth.Token,
+			org:   auth.Org,
+			lease: lease,
+		}, nil
+	case VesselProvider:
+		if auth == nil || len(auth.VesselToken) == 0 || len(auth.VesselTeam) == 0 {
+			return nil, fmt.Errorf("no Vessel API token or team, run `vessel auth --vessel` first")
+		}
+
+		return &vesselProvider{
+			token: auth.VesselToken,
+			team:  auth.VesselTeam,
+		}, nil
+	case SshProvider:
+		return &sshProvider{
+			remote: &project.Remote,
+		}, nil
+	}
+
+	return nil, fmt.Errorf("unknown provider '%s'", project.Provider)
+}
@@ -0,0 +1,195 @@
+package environments
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+)
+
+// sidecarMachinePrefix is prepended to sidecar names to name their machines, e.g. vessel-sidecar-mysql
+const sidecarMachinePrefix = "vessel-sidecar-"
+
+// sidecarProcessGroupPrefix is prepended to sidecar names to name their process groups, e.g. sidecar-mysql
+const sidecarProcessGroupPrefix = "sidecar-"
+
+// sidecarEnvPrefix is used for the env variables telling the dev environment where to find sidecars
+const sidecarEnvPrefix = "VESSEL_SIDECAR_"
+
+// SidecarHost is the hostname of a sidecar on Fly's private network. It's based on the sidecar's
+// name rather than its machine, so it stays the same if the sidecar's machine is recreated.
+func SidecarHost(appName, name string) string {
+	return fmt.Sprintf("%s.process.%s.internal", sidecarProcessGroup(name), appName)
+}
+
+// sidecarProcessGroup is the process group of a sidecar's machine, which Fly's private DNS resolves.
+// Underscores aren't valid in hostnames, so they're replaced.
+func sidecarProcessGroup(name string) string {
+	return sidecarProcessGroupPrefix + strings.ReplaceAll(name, "_", "-")
+}
+
+// sidecarEnvKey is the env variable holding a sidecar's hostname, e.g. VESSEL_SIDECAR_MYSQL_HOST
+func sidecarEnvKey(name string) string {
+	return sidecarEnvPrefix + strings.ToUpper(name) + "_HOST"
+}
+
+// sidecarEnv generates the env variables pointing the dev environment at its sidecars
+func sidecarEnv(appName string, sidecars map[string]string) map[string]string {
+	env := make(map[string]string, len(sidecars))
+	for name := range sidecars {
+		env[sidecarEnvKey(name)] = SidecarHost(appName, name)
+	}
+
+	return env
+}
+
+// createSidecar creates a sidecar's volume (if any) and machine in the given region.
+// The IDs of the created machine and volume are returned, the volume's even if the machine fails.
+func createSidecar(token, appName, region string, sidecar *config.SidecarConfig) (string, string, error) {
+	image, private, err := imageToRun(appName, sidecar.Image)
+
+	if err != nil {
+		return "", "", err
+	}
+
+	if private {
+		if image, err = mirrorPrivateImage(token, appName, sidecar.Image); err != nil {
+			return "", "", err
+		}
+	}
+
+	var mounts []fly.Mount
+	volumeId := ""
+	if sidecar.Volume != nil {
+		sizeGb := sidecar.Volume.SizeGb
+		if sizeGb == 0 {
+			sizeGb = defaultVolumeSizeGb
+		}
+
+		volume, err := fly.CreateVolume(token, appName, sidecar.Volume.Name, region, sizeGb)
+
+		if err != nil {
+			return "", "", fmt.Errorf("could not create volume '%s' for sidecar '%s': %w", sidecar.Volume.Name, sidecar.Name, err)
+		}
+
+		volumeId = volume.Id
+		mounts = append(mounts, fly.Mount{
+			Volume: volume.Id,
+			Path:   sidecar.Volume.Path,
+		})
+	}
+
+	// Sidecars publish no services, they're only reachable over the private network
+	machine, err := fly.RunMachine(token, appName, sidecarMachinePrefix+sidecar.Name, region, &fly.MachineConfig{
+		Image:  image,
+		Env:    sidecar.Env,
+		Guest:  guestFromConfig(&sidecar.Machine),
+		Mounts: mounts,
+		// Metadata isn't managed by Vessel, besides naming the process group
+		Extra: map[string]json.RawMessage{
+			"metadata": json.RawMessage(fmt.Sprintf(`{"fly_process_group":%q}`, sidecarProcessGroup(sidecar.Name))),
+		},
+	})
+
+	if err != nil {
+		return "", volumeId, fmt.Errorf("could not run machine for sidecar '%s': %w", sidecar.Name, err)
+	}
+
+	return machine.Id, volumeId, nil
+}
+
+// SidecarIds returns the machine IDs of an environment's sidecars, by sidecar name
+func SidecarIds(appName string) (map[string]string, error) {
+	state, err := config.RetrieveEnvironmentState(appName)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read environment state: %w", err)
+	}
+
+	if state.Sidecars == nil {
+		return map[string]string{}, nil
+	}
+
+	return state.Sidecars, nil
+}
+
+// StartSidecars starts an environment's sidecars and waits for them to be running,
+// so they're available by the time the dev environment's machine starts. Sidecars
+// that are already running are left alone.
+func StartSidecars(ctx context.Context, token, appName string, lease fly.LeaseOptions) error {
+	sidecars, err := SidecarIds(appName)
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range sortedSidecars(sidecars) {
+		if err = startMachine(ctx, token, appName, sidecars[name], lease); err != nil {
+			return fmt.Errorf("could not start sidecar '%s': %w", name, err)
+		}
+	}
+
+	for _, name := range sortedSidecars(sidecars) {
+		if _, err = fly.WaitForMachineState(ctx, token, appName, sidecars[name], "started", nil); err != nil {
+			return fmt.Errorf("could not wait for sidecar '%s' to start: %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// StopSidecars stops an environment's sidecars
+func StopSidecars(token, appName string, lease fly.LeaseOptions) error {
+	sidecars, err := SidecarIds(appName)
+
+	if err != nil {
+		return err
+	}
+
+	for _, name := range sortedSidecars(sidecars) {
+		if err = fly.StopMachine(token, appName, sidecars[name], lease); err != nil {
+			return fmt.Errorf("could not stop sidecar '%s': %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// RestartSidecars restarts an environment's running sidecars so they pick up changes such as
+// new secrets, returning the names of the sidecars restarted. Stopped sidecars are left alone.
+func RestartSidecars(token, appName string, lease fly.LeaseOptions) ([]string, error) {
+	sidecars, err := SidecarIds(appName)
+
+	if err != nil {
+		return nil, err
+	}
+
+	restarted := make([]string, 0, len(sidecars))
+	for _, name := range sortedSidecars(sidecars) {
+		ok, err := restartIfStarted(token, appName, sidecars[name], lease)
+
+		if err != nil {
+			return restarted, fmt.Errorf("could not restart sidecar '%s': %w", name, err)
+		}
+
+		if ok {
+			restarted = append(restarted, name)
+		}
+	}
+
+	return restarted, nil
+}
+
+func sortedSidecars(sidecars map[string]string) []string {
+	names := make([]string, 0, len(sidecars))
+	for name := range sidecars {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names
+}
@@ -0,0 +1,159 @@
+package environments
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/fly/flytest"
+	"github.com/vessel-app/vessel-cli/internal/util"
+)
+
+// newFlyTest points the fly package at a fake Fly API, storing ~/.vessel in a temporary directory
+func newFlyTest(t *testing.T) *flytest.Server {
+	t.Helper()
+
+	t.Setenv("HOME", t.TempDir())
+	homedir.DisableCache = true
+	t.Cleanup(func() { homedir.DisableCache = false })
+
+	server := flytest.NewServer()
+	server.StateDelay = 10 * time.Millisecond
+	t.Cleanup(server.Close)
+
+	urls := fly.CurrentApiUrls()
+	t.Cleanup(func() { fly.UseApiUrls(urls) })
+	fly.UseApiUrls(server.Urls())
+
+	return server
+}
+
+// createFlyEnvironment creates an environment with a sidecar through the fly provider
+func createFlyEnvironment(t *testing.T, name string) (Provider, *Environment) {
+	t.Helper()
+
+	if _, err := util.MakeAppDir(name); err != nil {
+		t.Fatal(err)
+	}
+
+	project := &config.EnvironmentConfig{
+		Name:  name,
+		Image: "vesselapp/php:8.2",
+		Sidecars: []config.SidecarConfig{
+			{Name: "mysql", Image: "mysql:8.0"},
+		},
+	}
+
+	provider, err := NewProvider(project, &config.AuthConfig{Token: "token", Org: "personal"}, fly.LeaseOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	env, err := provider.Create(context.Background(), &CreateOptions{
+		Project:   project,
+		Region:    "iad",
+		PublicKey: "ssh-ed25519 AAAA test",
+		Ipv6:      true,
+	}, nil)
+
+	if err != nil {
+		t.Fatalf("could not create environment: %v", err)
+	}
+
+	return provider, env
+}
+
+func TestStartSidecarsSkipsStartedSidecars(t *testing.T) {
+	server := newFlyTest(t)
+	_, env := createFlyEnvironment(t, "sidecars")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Sidecars are started along with the environment, starting them again is a no-op
+	for i := 0; i < 2; i++ {
+		if err := StartSidecars(ctx, "token", "sidecars", fly.LeaseOptions{}); err != nil {
+			t.Fatalf("could not start sidecars (attempt %d): %v", i+1, err)
+		}
+	}
+
+	m, ok := server.Machine("sidecars", env.FlySidecars["mysql"])
+
+	if !ok || m.State != "started" {
+		t.Errorf("expected mysql sidecar to be started, got %v", m)
+	}
+
+	// Stopped sidecars are started again
+	if err := StopSidecars("token", "sidecars", fly.LeaseOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := fly.WaitForMachineState(ctx, "token", "sidecars", env.FlySidecars["mysql"], "stopped", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := StartSidecars(ctx, "token", "sidecars", fly.LeaseOptions{}); err != nil {
+		t.Fatalf("could not start stopped sidecars: %v", err)
+	}
+}
+
+func TestRestartSidecarsSkipsStoppedSidecars(t *testing.T) {
+	server := newFlyTest(t)
+	_, env := createFlyEnvironment(t, "restarts")
+
+	restarted, err := RestartSidecars("token", "restarts", fly.LeaseOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(restarted) != 1 || restarted[0] != "mysql" {
+		t.Errorf("expected mysql to be restarted, got %v", restarted)
+	}
+
+	if !slices.Contains(server.Requests(), "POST /v1/apps/restarts/machines/"+env.FlySidecars["mysql"]+"/restart") {
+		t.Errorf("expected a restart request, got %v", server.Requests())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err = StopSidecars("token", "restarts", fly.LeaseOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = fly.WaitForMachineState(ctx, "token", "restarts", env.FlySidecars["mysql"], "stopped", nil); err != nil {
+		t.Fatal(err)
+	}
+
+	if restarted, err = RestartSidecars("token", "restarts", fly.LeaseOptions{}); err != nil || len(restarted) != 0 {
+		t.Errorf("expected stopped sidecars to be left alone, got %v, %v", restarted, err)
+	}
+}
+
+func TestSidecarHostOutlivesItsMachine(t *testing.T) {
+	server := newFlyTest(t)
+	_, env := createFlyEnvironment(t, "named")
+
+	sidecar, _ := server.Machine("named", env.FlySidecars["mysql"])
+
+	if string(sidecar.Config.Extra["metadata"]) != `{"fly_process_group":"sidecar-mysql"}` {
+		t.Errorf("expected the sidecar to be in its own process group, got %s", sidecar.Config.Extra["metadata"])
+	}
+
+	// The dev environment finds the sidecar by name, not by its machine ID
+	machine, _ := server.Machine("named", env.FlyMachine)
+
+	if host := machine.Config.Env["VESSEL_SIDECAR_MYSQL_HOST"]; host != "sidecar-mysql.process.named.internal" {
+		t.Errorf("expected a name-based sidecar host, got %s", host)
+	}
+
+	if host := SidecarHost("named", "redis_cache"); host != "sidecar-redis-cache.process.named.internal" {
+		t.Errorf("expected underscores to be replaced in sidecar hosts, got %s", host)
+	}
+}
@@ -0,0 +1,86 @@
+package environments
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/remote"
+	"github.com/vessel-app/vessel-cli/internal/wait"
+)
+
+// sshProvider uses a host the user manages themselves, as set by the remote section of vessel.yml.
+// Nothing is created on Fly, and the host is never started or stopped by Vessel.
+type sshProvider struct {
+	remote *config.RemoteConfig
+}
+
+// Create installs the environment's public key on the host, and creates the project path
+func (p *sshProvider) Create(ctx context.Context, opts *CreateOptions, onChange wait.StateFunc) (*Environment, error) {
+	if err := remote.InstallKey(p.remote, opts.PublicKey, opts.Password, opts.ConfirmHostKey); err != nil {
+		return nil, err
+	}
+
+	connection := p.connection()
+
+	if err := connection.Run("mkdir -p " + remote.ShellQuote(p.remote.RemotePath)); err != nil {
+		return nil, fmt.Errorf("could not create remote project path: %w", err)
+	}
+
+	return &Environment{
+		Host: p.remote.Hostname,
+	}, nil
+}
+
+// Get reports the host as started if we can connect to it
+func (p *sshProvider) Get(name string) (*Status, error) {
+	status := &Status{
+		Id:    p.remote.Hostname,
+		State: "started",
+	}
+
+	if err := p.connection().TestConnection(); err != nil {
+		status.State = "unreachable"
+	}
+
+	return status, nil
+}
+
+// Start only checks the host is reachable, as it's not managed by Vessel
+func (p *sshProvider) Start(ctx context.Context, name string, onChange wait.StateFunc) error {
+	if err := p.connection().TestConnection(); err != nil {
+		return fmt.Errorf("could not reach ssh host: %w", err)
+	}
+
+	return nil
+}
+
+// Stop does nothing, the host keeps running
+func (p *sshProvider) Stop(name string) error {
+	return nil
+}
+
+// Destroy removes the environment's public key from the host. Project files are left in place.
+func (p *sshProvider) Destroy(name string) error {
+	publicKey, err := os.ReadFile(p.remote.IdentityFile + ".pub")
+
+	if err != nil {
+		return fmt.Errorf("could not read public key: %w", err)
+	}
+
+	if err = p.connection().RemoveKey(string(publicKey)); err != nil {
+		return fmt.Errorf("could not remove key from authorized_keys: %w", err)
+	}
+
+	return nil
+}
+
+func (p *sshProvider) Endpoint(name string) (string, error) {
+	return p.remote.Hostname, nil
+}
+
+// connection connects to the host, verifying its key as it was trusted by Create
+func (p *sshProvider) connection() *remote.Connection {
+	return remote.NewConnection(p.remote).WithKnownHosts()
+}
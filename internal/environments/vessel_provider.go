@@ -0,0 +1,182 @@
+package environments
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/vessel"
+	"github.com/vessel-app/vessel-cli/internal/wait"
+)
+
+// vesselProvider creates environments through the hosted Vessel API, within the team
+// chosen with `vessel auth --vessel`. The API manages the machine itself.
+type vesselProvider struct {
+	token string
+	team  string
+}
+
+func (p *vesselProvider) Create(ctx context.Context, opts *CreateOptions, onChange wait.StateFunc) (*Environment, error) {
+	if unsupported := VesselUnsupportedSettings(opts.Project); len(unsupported) > 0 {
+		return nil, fmt.Errorf("the Vessel API doesn't support setting %s, remove them from vessel.yml", strings.Join(unsupported, ", "))
+	}
+
+	env, err := vessel.CreateEnvironment(p.team, opts.Project.Name, opts.PublicKey, opts.Region, p.token)
+
+	if err != nil {
+		return nil, err
+	}
+
+	// Track the environment's ID, so other commands can find it
+	state := &config.EnvironmentState{
+		VesselEnvironment: env.Id,
+	}
+
+	if err = config.SaveEnvironmentState(opts.Project.Name, state); err != nil {
+		return nil, fmt.Errorf("could not save environment state: %w", err)
+	}
+
+	if env, err = vessel.WaitForEnvironment(ctx, p.team, env.Id, p.token, onChange); err != nil {
+		return nil, err
+	}
+
+	return &Environment{
+		Host: env.IpAddress,
+	}, nil
+}
+
+func (p *vesselProvider) Get(name string) (*Status, error) {
+	env, err := p.environment(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &Status{
+		Id:     strconv.FormatUint(env.Id, 10),
+		State:  vesselState(env),
+		Region: env.Region,
+	}, nil
+}
+
+// Start waits for the environment to be ready, as the API starts it as needed
+func (p *vesselProvider) Start(ctx context.Context, name string, onChange wait.StateFunc) error {
+	id, err := p.environmentId(name)
+
+	if err != nil {
+		return err
+	}
+
+	if _, err = vessel.WaitForEnvironment(ctx, p.team, id, p.token, onChange); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Stop isn't supported by the Vessel API, environments stop on their own when idle
+func (p *vesselProvider) Stop(name string) error {
+	return fmt.Errorf("environments created through Vessel can't be stopped right away, they stop when idle")
+}
+
+func (p *vesselProvider) Destroy(name string) error {
+	id, err := p.environmentId(name)
+
+	if err != nil {
+		return err
+	}
+
+	if err = vessel.DeleteEnvironment(p.team, id, p.token); err != nil {
+		return fmt.Errorf("could not delete environment: %w", err)
+	}
+
+	return nil
+}
+
+func (p *vesselProvider) Endpoint(name string) (string, error) {
+	env, err := p.environment(name)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(env.IpAddress) == 0 {
+		return "", fmt.Errorf("environment has no ip address yet")
+	}
+
+	return env.IpAddress, nil
+}
+
+// environmentId finds the environment's ID in its state file
+func (p *vesselProvider) environmentId(name string) (uint64, error) {
+	state, err := config.RetrieveEnvironmentState(name)
+
+	if err != nil {
+		return 0, fmt.Errorf("could not read environment state: %w", err)
+	}
+
+	if state.VesselEnvironment == 0 {
+		return 0, fmt.Errorf("no Vessel environment found for %s", name)
+	}
+
+	return state.VesselEnvironment, nil
+}
+
+func (p *vesselProvider) environment(name string) (*vessel.Environment, error) {
+	id, err := p.environmentId(name)
+
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := vessel.GetEnvironment(p.team, id, p.token)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not get environment: %w", err)
+	}
+
+	return env, nil
+}
+
+// VesselUnsupportedSettings lists the vessel.yml sections set in the project that the Vessel API
+// doesn't support. The API chooses the environment's image and machine itself.
+func VesselUnsupportedSettings(project *config.EnvironmentConfig) []string {
+	unsupported := make([]string, 0)
+
+	if len(project.Image) > 0 {
+		unsupported = append(unsupported, "image")
+	}
+
+	if project.Machine != (config.MachineConfig{}) {
+		unsupported = append(unsupported, "machine")
+	}
+
+	if len(project.Volumes) > 0 {
+		unsupported = append(unsupported, "volumes")
+	}
+
+	if len(project.Env) > 0 {
+		unsupported = append(unsupported, "env")
+	}
+
+	if len(project.Services) > 0 {
+		unsupported = append(unsupported, "services")
+	}
+
+	if len(project.Sidecars) > 0 {
+		unsupported = append(unsupported, "sidecars")
+	}
+
+	return unsupported
+}
+
+// vesselState reports initialized environments as started, matching Fly's machine states
+func vesselState(env *vessel.Environment) string {
+	if env.Initialized && env.Status != "failed" {
+		return "started"
+	}
+
+	return env.Status
+}
@@ -0,0 +1,44 @@
+package environments
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+)
+
+func TestVesselProviderRejectsUnsupportedSettings(t *testing.T) {
+	project := &config.EnvironmentConfig{
+		Name:     "hosted",
+		Provider: VesselProvider,
+		Image:    "vesselapp/php:8.2",
+		Machine:  config.MachineConfig{CpuKind: "shared", Cpus: 1, MemoryMb: 256},
+		Sidecars: []config.SidecarConfig{
+			{Name: "mysql", Image: "mysql:8.0"},
+		},
+	}
+
+	unsupported := VesselUnsupportedSettings(project)
+
+	if !slices.Equal(unsupported, []string{"image", "machine", "sidecars"}) {
+		t.Errorf("expected image, machine and sidecars to be unsupported, got %v", unsupported)
+	}
+
+	// Fly credentials aren't needed
+	provider, err := NewProvider(project, &config.AuthConfig{VesselToken: "token", VesselTeam: "team"}, fly.LeaseOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The Vessel API isn't called, so no server is needed
+	if _, err = provider.Create(context.Background(), &CreateOptions{Project: project, Region: "iad"}, nil); err == nil {
+		t.Error("expected unsupported settings to be rejected")
+	}
+
+	if unsupported = VesselUnsupportedSettings(&config.EnvironmentConfig{Name: "hosted", Provider: VesselProvider}); len(unsupported) != 0 {
+		t.Errorf("expected no unsupported settings, got %v", unsupported)
+	}
+}
@@ -0,0 +1,34 @@
+package environments
+
+import (
+	"fmt"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/tunnel"
+)
+
+// createWireGuardPeer adds a WireGuard peer to the organization, for reaching the environment
+// over Fly's private network. The peer uses the WireGuard gateway of the environment's region.
+func createWireGuardPeer(token, org, region, appName string) (*config.WireGuardState, error) {
+	privateKey, publicKey, err := tunnel.GenerateKeys()
+
+	if err != nil {
+		return nil, err
+	}
+
+	name := "vessel-" + appName
+	peer, err := fly.AddWireGuardPeer(token, org, region, name, publicKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not add wireguard peer: %w", err)
+	}
+
+	return &config.WireGuardState{
+		Name:       name,
+		PrivateKey: privateKey,
+		PeerIp:     peer.PeerIp,
+		EndpointIp: peer.EndpointIp,
+		PublicKey:  peer.PublicKey,
+	}, nil
+}
@@ -13,15 +13,11 @@ import (
 )
 
 // ShouldStartFlyMachineApiProxy will attempt to run the `fly machine api-proxy` command
-// if FLY_HOST env var is not set and no connection to 127.0.0.1:4280 can be made (user
-// did not already start the machine api-proxy).
+// if the Machines API is set to the local proxy (FLY_MACHINES_URL=http://127.0.0.1:4280)
+// and no connection to it can be made (user did not already start the machine api-proxy).
+// By default Fly's public Machines API is used, and the proxy isn't needed.
 func ShouldStartFlyMachineApiProxy() bool {
-	// If FLY_HOST is set, we do nothing, as we assume
-	// the user is VPNed into Fly and using _api.internal:4280
-	flyHost := os.Getenv("FLY_HOST")
-
-	if len(flyHost) > 0 {
-		flyApiHost = flyHost
+	if machinesApiUrl != machinesProxyUrl {
 		return false
 	}
 
@@ -80,7 +76,7 @@ func StartMachineProxy(exe string) (func() error, error) {
 // isProxyRunning determines if the fly machine API proxy is running by
 // attempting to open a TCP connection to the proxy port.
 func isProxyRunning() bool {
-	c, err := net.Dial("tcp", "127.0.0.1:4280")
+	c, err := net.Dial("tcp", strings.TrimPrefix(machinesProxyUrl, "http://"))
 	if err != nil {
 		return false
 	}
@@ -12,15 +12,18 @@ import (
 ****************/
 
 type CreateAppRequest struct {
-	AppName string
-	OrgSlug string
+	AppName string `json:"app_name"`
+	OrgSlug string `json:"org_slug"`
 }
 
 func (r *CreateAppRequest) ToRequest(token string) (*http.Request, error) {
-	data := []byte(fmt.Sprintf(`{"app_name": "%s", "org_slug": "%s"}`, r.AppName, r.OrgSlug))
+	data, err := json.Marshal(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal app request: %w", err)
+	}
 
-	// TODO: Decide on url to use (vpn vs proxy)
-	req, err := http.NewRequest(http.MethodPost, "http://"+flyApiHost+":4280/v1/apps", bytes.NewBuffer(data))
+	req, err := http.NewRequest(http.MethodPost, machinesApiUrl+"/v1/apps", bytes.NewBuffer(data))
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create http request object: %w", err)
@@ -62,8 +65,7 @@ type GetAppRequest struct {
 }
 
 func (r *GetAppRequest) ToRequest(token string) (*http.Request, error) {
-	// TODO: Decide on url to use (vpn vs proxy)
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://"+flyApiHost+":4280/v1/apps/%s", r.AppName), nil)
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s", r.AppName), nil)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create http request object: %w", err)
@@ -106,8 +108,7 @@ type DeleteAppRequest struct {
 }
 
 func (r *DeleteAppRequest) ToRequest(token string) (*http.Request, error) {
-	// TODO: Decide on url to use (vpn vs proxy)
-	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://"+flyApiHost+":4280/v1/apps/%s", r.AppName), nil)
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s", r.AppName), nil)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create http request object: %w", err)
@@ -0,0 +1,104 @@
+package flytest
+
+import (
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// Fault makes matching requests fail, to test how Vessel handles API errors
+type Fault struct {
+	// Method matches the request method, e.g. POST. Empty matches any method.
+	Method string `json:"method"`
+	// Path is a path.Match pattern, e.g. /v1/apps/*/machines. Empty matches any path.
+	Path string `json:"path"`
+	// Query matches GraphQL requests whose query contains it, e.g. allocateIpAddress
+	Query string `json:"query"`
+
+	// Delay waits before responding. Use a delay longer than the client's timeout to simulate timeouts.
+	Delay time.Duration `json:"-"`
+	// Status responds with an error status code, e.g. 503
+	Status int `json:"status"`
+	// GraphQLError responds with a 200 whose "errors" array has this message
+	GraphQLError string `json:"graphql_error"`
+
+	// Times is how many matching requests fail, 0 for every matching request
+	Times int `json:"times"`
+
+	hits int
+}
+
+// Inject adds a fault. Faults are matched in the order they were added.
+func (s *Server) Inject(f Fault) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = append(s.faults, &f)
+}
+
+// ClearFaults removes all faults
+func (s *Server) ClearFaults() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.faults = nil
+}
+
+// matchFault finds the first fault matching a request, counting it as hit.
+// Must be called while holding the lock.
+func (s *Server) matchFault(r *http.Request, body []byte) *Fault {
+	for _, f := range s.faults {
+		if f.Times > 0 && f.hits >= f.Times {
+			continue
+		}
+
+		if len(f.Method) > 0 && !strings.EqualFold(f.Method, r.Method) {
+			continue
+		}
+
+		if len(f.Path) > 0 {
+			if matched, _ := path.Match(f.Path, r.URL.Path); !matched {
+				continue
+			}
+		}
+
+		if len(f.Query) > 0 && !strings.Contains(string(body), f.Query) {
+			continue
+		}
+
+		f.hits++
+		copied := *f
+
+		return &copied
+	}
+
+	return nil
+}
+
+// apply delays and responds to a faulted request. It returns false if
+// the request should still be handled, e.g. after a delay.
+func (f *Fault) apply(w http.ResponseWriter, r *http.Request) bool {
+	if f.Delay > 0 {
+		select {
+		case <-r.Context().Done():
+			return true
+		case <-time.After(f.Delay):
+		}
+	}
+
+	if f.Status > 0 {
+		writeError(w, f.Status, "injected fault")
+		return true
+	}
+
+	if len(f.GraphQLError) > 0 {
+		writeJson(w, http.StatusOK, map[string]interface{}{
+			"data":   nil,
+			"errors": []graphError{newGraphError(f.GraphQLError, "INJECTED_FAULT")},
+		})
+		return true
+	}
+
+	return false
+}
@@ -0,0 +1,227 @@
+package flytest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/vessel-app/vessel-cli/internal/fly"
+)
+
+type graphRequest struct {
+	Query     string                     `json:"query"`
+	Variables map[string]json.RawMessage `json:"variables"`
+}
+
+type graphError struct {
+	Message    string            `json:"message"`
+	Extensions map[string]string `json:"extensions"`
+}
+
+func newGraphError(msg, code string) graphError {
+	return graphError{
+		Message:    msg,
+		Extensions: map[string]string{"code": code},
+	}
+}
+
+// graphInput is the "input" variable of the mutations Vessel makes
+type graphInput struct {
+	AppId   string   `json:"appId"`
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Pubkey  string   `json:"pubkey"`
+	Keys    []string `json:"keys"`
+	Secrets []struct {
+		Key   string `json:"key"`
+		Value string `json:"value"`
+	} `json:"secrets"`
+}
+
+// graphql answers the GraphQL queries Vessel makes, telling them apart by their root fields
+func (s *Server) graphql(w http.ResponseWriter, r *http.Request, body []byte) {
+	req := &graphRequest{}
+	if err := json.Unmarshal(body, req); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid graphql request")
+		return
+	}
+
+	// The nearest region and region list don't need a token
+	public := strings.Contains(req.Query, "nearestRegion") || strings.Contains(req.Query, "platform")
+	if !public && !authorized(r) {
+		writeJson(w, http.StatusUnauthorized, map[string]interface{}{
+			"errors": []graphError{newGraphError("You must be authenticated to view this.", "UNAUTHORIZED")},
+		})
+		return
+	}
+
+	input := &graphInput{}
+	if raw, ok := req.Variables["input"]; ok {
+		_ = json.Unmarshal(raw, input)
+	}
+
+	appName := input.AppId
+	if raw, ok := req.Variables["appName"]; ok {
+		_ = json.Unmarshal(raw, &appName)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a := s.apps[appName]
+
+	switch {
+	case strings.Contains(req.Query, "nearestRegion"):
+		writeGraphData(w, map[string]interface{}{
+			"nearestRegion": fly.Region{Code: "iad", Name: "Ashburn, Virginia (US)", GatewayAvailable: true},
+		})
+	case strings.Contains(req.Query, "platform"):
+		writeGraphData(w, map[string]interface{}{
+			"platform": map[string]interface{}{"regions": platformRegions()},
+		})
+	case strings.Contains(req.Query, "currentUser"):
+		writeGraphData(w, map[string]interface{}{
+			"currentUser": map[string]string{"email": "vessel@example.com"},
+			"organizations": map[string]interface{}{
+				"nodes": []fly.Organization{{Id: "org-personal", Slug: "personal", Name: "Personal"}},
+			},
+		})
+	case strings.Contains(req.Query, "addWireGuardPeer"):
+		writeGraphData(w, map[string]interface{}{
+			"addWireGuardPeer": s.addWireGuardPeer(input.Name),
+		})
+	case strings.Contains(req.Query, "removeWireGuardPeer"):
+		if _, ok := s.peers[input.Name]; !ok {
+			writeGraphErrors(w, newGraphError(fmt.Sprintf("Could not find WireGuard peer \"%s\"", input.Name), "NOT_FOUND"))
+			return
+		}
+
+		delete(s.peers, input.Name)
+		writeGraphData(w, map[string]interface{}{
+			"removeWireGuardPeer": map[string]interface{}{"organization": map[string]string{"id": "org-personal"}},
+		})
+	case strings.Contains(req.Query, "organization("):
+		writeGraphData(w, map[string]interface{}{
+			"organization": fly.Organization{Id: "org-personal", Slug: "personal", Name: "Personal"},
+		})
+	case a == nil:
+		writeGraphErrors(w, newGraphError(fmt.Sprintf("Could not find App \"%s\"", appName), "NOT_FOUND"))
+	case strings.Contains(req.Query, "allocateIpAddress"):
+		ip := s.allocateIp(input.Type)
+		a.IpAddresses.Nodes = append(a.IpAddresses.Nodes, ip)
+		writeGraphData(w, map[string]interface{}{
+			"allocateIpAddress": fly.IpAddressAllocation{IpAddress: ip},
+		})
+	case strings.Contains(req.Query, "ipAddresses"):
+		writeGraphData(w, map[string]interface{}{
+			"app": map[string]interface{}{"ipAddresses": a.IpAddresses},
+		})
+	case strings.Contains(req.Query, "unsetSecrets"):
+		for _, k := range input.Keys {
+			delete(a.secrets, k)
+		}
+
+		writeGraphData(w, releaseData("unsetSecrets"))
+	case strings.Contains(req.Query, "setSecrets"):
+		for _, secret := range input.Secrets {
+			digest := sha256.Sum256([]byte(secret.Value))
+			a.secrets[secret.Key] = fly.Secret{
+				Name:      secret.Key,
+				Digest:    hex.EncodeToString(digest[:8]),
+				CreatedAt: time.Now().UTC().Format(time.RFC3339),
+			}
+		}
+
+		writeGraphData(w, releaseData("setSecrets"))
+	case strings.Contains(req.Query, "secrets"):
+		secrets := make([]fly.Secret, 0, len(a.secrets))
+		for _, secret := range a.secrets {
+			secrets = append(secrets, secret)
+		}
+
+		sort.Slice(secrets, func(i, j int) bool {
+			return secrets[i].Name < secrets[j].Name
+		})
+
+		writeGraphData(w, map[string]interface{}{
+			"app": map[string]interface{}{"secrets": secrets},
+		})
+	default:
+		writeGraphErrors(w, newGraphError("The fake Fly API does not support this query", "UNSUPPORTED"))
+	}
+}
+
+// allocateIp gives out a public address, or IpAddress if it's set.
+// Must be called while holding the lock.
+func (s *Server) allocateIp(ipType string) fly.IpAddress {
+	s.nextId++
+
+	address := fmt.Sprintf("2a09:8280:1::%x", s.nextId)
+	if ipType == "v4" {
+		address = fmt.Sprintf("137.66.%d.%d", s.nextId/256%256, s.nextId%256)
+	}
+
+	if len(s.IpAddress) > 0 {
+		address = s.IpAddress
+	}
+
+	return fly.IpAddress{
+		Address: address,
+		Type:    ipType,
+		Region:  "global",
+	}
+}
+
+// addWireGuardPeer gives out an address within the private network. There's no gateway
+// behind the peer, so tunnels using it won't connect. Must be called while holding the lock.
+func (s *Server) addWireGuardPeer(name string) fly.WireGuardPeer {
+	s.nextId++
+
+	peer := fly.WireGuardPeer{
+		PeerIp:     fmt.Sprintf("fdaa:0:1:a7b:%x::2", s.nextId),
+		EndpointIp: "127.0.0.1",
+		PublicKey:  "AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=",
+	}
+	s.peers[name] = peer
+
+	return peer
+}
+
+// platformRegions lists the built-in regions, in the shape of the platform query
+func platformRegions() []map[string]interface{} {
+	regions := make([]map[string]interface{}, 0, len(fly.Regions))
+	for _, r := range fly.Regions {
+		regions = append(regions, map[string]interface{}{
+			"code":             r.Code,
+			"name":             r.Name,
+			"latitude":         r.Location.Lat,
+			"longitude":        r.Location.Lon,
+			"gatewayAvailable": r.GatewayAvailable,
+			"requiresPaidPlan": false,
+			"deprecated":       false,
+		})
+	}
+
+	return regions
+}
+
+func releaseData(mutation string) map[string]interface{} {
+	return map[string]interface{}{
+		mutation: map[string]interface{}{
+			"release": map[string]interface{}{"id": "release", "version": 1},
+		},
+	}
+}
+
+func writeGraphData(w http.ResponseWriter, data interface{}) {
+	writeJson(w, http.StatusOK, map[string]interface{}{"data": data})
+}
+
+func writeGraphErrors(w http.ResponseWriter, errs ...graphError) {
+	writeJson(w, http.StatusOK, map[string]interface{}{"data": nil, "errors": errs})
+}
@@ -0,0 +1,68 @@
+package flytest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vessel-app/vessel-cli/internal/fly"
+)
+
+type logsPage struct {
+	Data []logsPageEntry `json:"data"`
+	Meta struct {
+		NextToken string `json:"next_token"`
+	} `json:"meta"`
+}
+
+type logsPageEntry struct {
+	Id         string       `json:"id"`
+	Attributes fly.LogEntry `json:"attributes"`
+}
+
+func logEntry(m *machine, msg string) fly.LogEntry {
+	return fly.LogEntry{
+		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
+		Message:   msg,
+		Level:     "info",
+		Instance:  m.Id,
+		Region:    m.Region,
+	}
+}
+
+// logs responds with a page of an app's log entries, the next token is the number of entries read
+func (s *Server) logs(w http.ResponseWriter, r *http.Request, appName string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[appName]
+	if !ok {
+		writeError(w, http.StatusNotFound, "app not found")
+		return
+	}
+
+	from, _ := strconv.Atoi(r.URL.Query().Get("next_token"))
+	if from > len(a.logs) {
+		from = len(a.logs)
+	}
+
+	page := &logsPage{Data: make([]logsPageEntry, 0)}
+	for i, entry := range a.logs[from:] {
+		if region := r.URL.Query().Get("region"); len(region) > 0 && entry.Region != region {
+			continue
+		}
+
+		if instance := r.URL.Query().Get("instance"); len(instance) > 0 && entry.Instance != instance {
+			continue
+		}
+
+		page.Data = append(page.Data, logsPageEntry{
+			Id:         strconv.Itoa(from + i),
+			Attributes: entry,
+		})
+	}
+
+	page.Meta.NextToken = strconv.Itoa(len(a.logs))
+
+	writeJson(w, http.StatusOK, page)
+}
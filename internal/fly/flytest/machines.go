@@ -0,0 +1,455 @@
+package flytest
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vessel-app/vessel-cli/internal/fly"
+)
+
+// leaseNonceHeader passes the nonce of a held lease to mutating machine requests
+const leaseNonceHeader = "fly-machine-lease-nonce"
+
+// maxWaitSeconds caps how long a /wait request blocks
+const maxWaitSeconds = 60
+
+type app struct {
+	fly.App
+	machines map[string]*machine
+	volumes  map[string]*fly.Volume
+	secrets  map[string]fly.Secret
+	logs     []fly.LogEntry
+}
+
+type machine struct {
+	fly.Machine
+	// next is the state the machine is moving to, reached at nextAt
+	next   string
+	nextAt time.Time
+	lease  *fly.Lease
+}
+
+// moveTo puts the machine in a transitional state, reaching the final state after delay
+func (m *machine) moveTo(transitional, final string, delay time.Duration) {
+	m.State = transitional
+	m.next = final
+	m.nextAt = time.Now().Add(delay)
+	m.UpdatedAt = time.Now().UTC().Format(time.RFC3339)
+}
+
+// settle completes a state change once its delay has passed, returning true if it did
+func (m *machine) settle(now time.Time) bool {
+	if len(m.next) == 0 || now.Before(m.nextAt) {
+		return false
+	}
+
+	m.State = m.next
+	m.next = ""
+
+	switch m.State {
+	case "started":
+		m.event("start", "started", "flyd", nil)
+	case "stopped":
+		m.event("exit", "stopped", "flyd", json.RawMessage(`{"exit_event":{"exit_code":0,"requested_stop":true}}`))
+	case "destroyed":
+		m.event("destroy", "destroyed", "flyd", nil)
+	}
+
+	return true
+}
+
+// event records a lifecycle event, newest first as Fly does
+func (m *machine) event(eventType, status, source string, request json.RawMessage) {
+	e := fly.MachineEvent{
+		Id:        fmt.Sprintf("%s-%d", m.Id, len(m.Events)),
+		Type:      eventType,
+		Status:    status,
+		Source:    source,
+		Timestamp: time.Now().UnixMilli(),
+		Request:   request,
+	}
+
+	m.Events = append([]fly.MachineEvent{e}, m.Events...)
+}
+
+// leasedByOther is true if someone holds the machine's lease, and the request doesn't have its nonce
+func (m *machine) leasedByOther(r *http.Request) bool {
+	if m.lease == nil || time.Now().Unix() > m.lease.ExpiresAt {
+		return false
+	}
+
+	return r.Header.Get(leaseNonceHeader) != m.lease.Nonce
+}
+
+// machinesApi handles /v1/apps/... requests, segments are the path after /v1/apps
+func (s *Server) machinesApi(w http.ResponseWriter, r *http.Request, body []byte, segments []string) {
+	if len(segments) == 0 {
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		s.createApp(w, body)
+		return
+	}
+
+	if r.Method == http.MethodGet && len(segments) == 5 && segments[1] == "machines" && segments[3] == "wait" {
+		// Waiting blocks, so it manages the lock itself
+		s.waitForState(w, r, segments[0], segments[2])
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[segments[0]]
+	if !ok {
+		writeError(w, http.StatusNotFound, "app not found")
+		return
+	}
+
+	switch {
+	case len(segments) == 1 && r.Method == http.MethodGet:
+		writeJson(w, http.StatusOK, a.App)
+	case len(segments) == 1 && r.Method == http.MethodDelete:
+		delete(s.apps, a.AppName)
+		w.WriteHeader(http.StatusAccepted)
+	case len(segments) == 2 && segments[1] == "machines" && r.Method == http.MethodGet:
+		s.listMachines(w, a)
+	case len(segments) == 2 && segments[1] == "machines" && r.Method == http.MethodPost:
+		s.runMachine(w, a, body)
+	case len(segments) == 2 && segments[1] == "volumes" && r.Method == http.MethodGet:
+		s.listVolumes(w, a)
+	case len(segments) == 2 && segments[1] == "volumes" && r.Method == http.MethodPost:
+		s.createVolume(w, a, body)
+	case len(segments) == 3 && segments[1] == "volumes" && r.Method == http.MethodDelete:
+		s.deleteVolume(w, a, segments[2])
+	case len(segments) >= 3 && segments[1] == "machines":
+		m, ok := a.machines[segments[2]]
+		if !ok {
+			writeError(w, http.StatusNotFound, "machine not found")
+			return
+		}
+
+		m.settle(time.Now())
+
+		action := ""
+		if len(segments) == 4 {
+			action = segments[3]
+		}
+
+		s.machineAction(w, r, a, m, action, body)
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) createApp(w http.ResponseWriter, body []byte) {
+	req := &fly.CreateAppRequest{}
+	if err := json.Unmarshal(body, req); err != nil || len(req.AppName) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid app request")
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.apps[req.AppName]; exists {
+		writeError(w, http.StatusUnprocessableEntity, "app name already taken")
+		return
+	}
+
+	s.apps[req.AppName] = &app{
+		App: fly.App{
+			AppName:      req.AppName,
+			Organization: fly.Organization{Slug: req.OrgSlug},
+		},
+		machines: make(map[string]*machine),
+		volumes:  make(map[string]*fly.Volume),
+		secrets:  make(map[string]fly.Secret),
+	}
+
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) listMachines(w http.ResponseWriter, a *app) {
+	machines := make([]fly.Machine, 0, len(a.machines))
+	for _, m := range a.machines {
+		m.settle(time.Now())
+
+		if m.State != "destroyed" {
+			machines = append(machines, m.Machine)
+		}
+	}
+
+	writeJson(w, http.StatusOK, machines)
+}
+
+func (s *Server) runMachine(w http.ResponseWriter, a *app, body []byte) {
+	req := &fly.RunMachineRequest{}
+	if err := json.Unmarshal(body, req); err != nil || len(req.Config.Image) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid machine request")
+		return
+	}
+
+	id := s.newId("")
+
+	for _, mount := range req.Config.Mounts {
+		v, ok := a.volumes[mount.Volume]
+		if !ok {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("volume %s not found", mount.Volume))
+			return
+		}
+
+		if len(v.AttachedMachine) > 0 {
+			writeError(w, http.StatusBadRequest, fmt.Sprintf("volume %s is already attached", mount.Volume))
+			return
+		}
+	}
+
+	for _, mount := range req.Config.Mounts {
+		a.volumes[mount.Volume].AttachedMachine = id
+	}
+
+	region := req.Region
+	if len(region) == 0 {
+		region = "iad"
+	}
+
+	now := time.Now().UTC().Format(time.RFC3339)
+	m := &machine{
+		Machine: fly.Machine{
+			Id:         id,
+			Name:       req.Name,
+			Region:     region,
+			InstanceId: s.newId("instance-"),
+			PrivateIp:  fmt.Sprintf("fdaa:0:1:a7b:1::%x", s.nextId),
+			Config:     req.Config,
+			CreatedAt:  now,
+			UpdatedAt:  now,
+		},
+	}
+
+	m.event("launch", "created", "user", nil)
+	m.moveTo("starting", "started", s.StateDelay)
+	a.machines[id] = m
+	a.logs = append(a.logs, logEntry(m, "Machine created, starting "+req.Config.Image))
+
+	writeJson(w, http.StatusOK, m.Machine)
+}
+
+// machineAction handles requests for a machine, action is the path after the machine ID (if any)
+func (s *Server) machineAction(w http.ResponseWriter, r *http.Request, a *app, m *machine, action string, body []byte) {
+	if action == "lease" {
+		s.lease(w, r, m, body)
+		return
+	}
+
+	if r.Method == http.MethodGet && len(action) == 0 {
+		writeJson(w, http.StatusOK, m.Machine)
+		return
+	}
+
+	if m.leasedByOther(r) {
+		writeError(w, http.StatusConflict, "machine is leased by someone else")
+		return
+	}
+
+	switch {
+	case r.Method == http.MethodPost && len(action) == 0:
+		req := &fly.UpdateMachineRequest{}
+		if err := json.Unmarshal(body, req); err != nil || len(req.Config.Image) == 0 {
+			writeError(w, http.StatusBadRequest, "invalid machine request")
+			return
+		}
+
+		m.Config = req.Config
+		m.event("update", "replacing", "user", nil)
+		if m.State == "started" || m.State == "starting" {
+			m.moveTo("starting", "started", s.StateDelay)
+		}
+
+		writeJson(w, http.StatusOK, m.Machine)
+	case r.Method == http.MethodPost && action == "start":
+		if m.State != "stopped" && m.State != "created" {
+			writeError(w, http.StatusPreconditionFailed, fmt.Sprintf("machine is %s, it can't be started", m.State))
+			return
+		}
+
+		m.event("start", "starting", "user", nil)
+		m.moveTo("starting", "started", s.StateDelay)
+		a.logs = append(a.logs, logEntry(m, "Machine starting"))
+		writeJson(w, http.StatusOK, map[string]string{"previous_state": "stopped"})
+	case r.Method == http.MethodPost && action == "stop":
+		m.event("stop", "stopping", "user", nil)
+		m.moveTo("stopping", "stopped", s.StateDelay)
+		a.logs = append(a.logs, logEntry(m, "Machine stopping"))
+		writeJson(w, http.StatusOK, map[string]bool{"ok": true})
+	case r.Method == http.MethodPost && action == "restart":
+		m.event("restart", "restarting", "user", nil)
+		m.moveTo("starting", "started", s.StateDelay)
+		writeJson(w, http.StatusOK, map[string]bool{"ok": true})
+	case r.Method == http.MethodDelete && len(action) == 0:
+		if (m.State == "started" || m.State == "starting") && r.URL.Query().Get("force") != "true" {
+			writeError(w, http.StatusPreconditionFailed, "machine is still running, stop it or use force")
+			return
+		}
+
+		for _, v := range a.volumes {
+			if v.AttachedMachine == m.Id {
+				v.AttachedMachine = ""
+			}
+		}
+
+		m.event("destroy", "destroying", "user", nil)
+		m.moveTo("destroying", "destroyed", s.StateDelay)
+		writeJson(w, http.StatusOK, map[string]bool{"ok": true})
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// waitForState blocks until a machine reaches a state, like the /wait endpoint
+func (s *Server) waitForState(w http.ResponseWriter, r *http.Request, appName, id string) {
+	state := r.URL.Query().Get("state")
+	if len(state) == 0 {
+		state = "started"
+	}
+
+	timeout, _ := strconv.Atoi(r.URL.Query().Get("timeout"))
+	if timeout <= 0 || timeout > maxWaitSeconds {
+		timeout = maxWaitSeconds
+	}
+
+	deadline := time.Now().Add(time.Duration(timeout) * time.Second)
+
+	for {
+		s.mu.Lock()
+		var m *machine
+		if a, ok := s.apps[appName]; ok {
+			m = a.machines[id]
+		}
+
+		if m == nil {
+			s.mu.Unlock()
+			writeError(w, http.StatusNotFound, "machine not found")
+			return
+		}
+
+		m.settle(time.Now())
+		reached := m.State == state
+		s.mu.Unlock()
+
+		if reached {
+			writeJson(w, http.StatusOK, map[string]bool{"ok": true})
+			return
+		}
+
+		if time.Now().After(deadline) {
+			writeError(w, http.StatusRequestTimeout, "deadline_exceeded: machine did not reach state "+state)
+			return
+		}
+
+		select {
+		case <-r.Context().Done():
+			return
+		case <-time.After(20 * time.Millisecond):
+		}
+	}
+}
+
+// lease handles acquiring, getting and releasing a machine's lease
+func (s *Server) lease(w http.ResponseWriter, r *http.Request, m *machine, body []byte) {
+	held := m.lease != nil && time.Now().Unix() <= m.lease.ExpiresAt
+
+	switch r.Method {
+	case http.MethodPost:
+		if held {
+			writeError(w, http.StatusConflict, "lease currently held")
+			return
+		}
+
+		req := &fly.AcquireLeaseRequest{}
+		_ = json.Unmarshal(body, req)
+
+		ttl := req.Ttl
+		if ttl <= 0 {
+			ttl = 30
+		}
+
+		m.lease = &fly.Lease{
+			Nonce:       s.newId("nonce-"),
+			ExpiresAt:   time.Now().Unix() + int64(ttl),
+			Owner:       "vessel@example.com",
+			Description: req.Description,
+		}
+
+		writeJson(w, http.StatusOK, map[string]interface{}{"status": "success", "data": m.lease})
+	case http.MethodGet:
+		if !held {
+			writeError(w, http.StatusNotFound, "no lease")
+			return
+		}
+
+		writeJson(w, http.StatusOK, map[string]interface{}{"status": "success", "data": m.lease})
+	case http.MethodDelete:
+		if held && r.Header.Get(leaseNonceHeader) != m.lease.Nonce {
+			writeError(w, http.StatusConflict, "lease nonce does not match")
+			return
+		}
+
+		m.lease = nil
+		writeJson(w, http.StatusOK, map[string]bool{"ok": true})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func (s *Server) listVolumes(w http.ResponseWriter, a *app) {
+	volumes := make([]fly.Volume, 0, len(a.volumes))
+	for _, v := range a.volumes {
+		volumes = append(volumes, *v)
+	}
+
+	writeJson(w, http.StatusOK, volumes)
+}
+
+func (s *Server) createVolume(w http.ResponseWriter, a *app, body []byte) {
+	req := &fly.CreateVolumeRequest{}
+	if err := json.Unmarshal(body, req); err != nil || len(req.Name) == 0 {
+		writeError(w, http.StatusBadRequest, "invalid volume request")
+		return
+	}
+
+	v := &fly.Volume{
+		Id:     s.newId("vol_"),
+		Name:   req.Name,
+		State:  "created",
+		SizeGb: req.SizeGb,
+		Region: req.Region,
+	}
+
+	a.volumes[v.Id] = v
+
+	writeJson(w, http.StatusOK, v)
+}
+
+func (s *Server) deleteVolume(w http.ResponseWriter, a *app, id string) {
+	v, ok := a.volumes[id]
+	if !ok {
+		writeError(w, http.StatusNotFound, "volume not found")
+		return
+	}
+
+	if len(v.AttachedMachine) > 0 {
+		writeError(w, http.StatusPreconditionFailed, fmt.Sprintf("volume is attached to machine %s", v.AttachedMachine))
+		return
+	}
+
+	delete(a.volumes, id)
+
+	writeJson(w, http.StatusOK, v)
+}
@@ -0,0 +1,233 @@
+// Package flytest runs an in-process fake of the Fly APIs Vessel uses, so commands such as
+// init, start and destroy can run offline. It covers the Machines API (apps, machines,
+// volumes, leases and /wait), the GraphQL queries Vessel makes, logs and region probes.
+// SSH access to machines isn't faked, set IpAddress to point environments at an SSH server.
+package flytest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vessel-app/vessel-cli/internal/fly"
+)
+
+// DefaultStateDelay is how long fake machines take to move between states
+const DefaultStateDelay = 100 * time.Millisecond
+
+// Server is a fake Fly API. Point the fly package at it with fly.UseApiUrls(server.Urls()).
+type Server struct {
+	*httptest.Server
+
+	// StateDelay is how long machines take to move between states, e.g. from starting to started
+	StateDelay time.Duration
+	// IpAddress, if set, is the address of every allocated IP, e.g. ::1 to use a local SSH server
+	IpAddress string
+
+	mu       sync.Mutex
+	apps     map[string]*app
+	peers    map[string]fly.WireGuardPeer
+	faults   []*Fault
+	requests []string
+	nextId   int
+}
+
+// NewServer starts a fake Fly API on a random local port
+func NewServer() *Server {
+	s := newServer()
+	s.Server = httptest.NewServer(s)
+
+	return s
+}
+
+// Listen starts a fake Fly API on the given address, e.g. 127.0.0.1:4281
+func Listen(addr string) (*Server, error) {
+	l, err := net.Listen("tcp", addr)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not listen on %s: %w", addr, err)
+	}
+
+	s := newServer()
+	s.Server = httptest.NewUnstartedServer(s)
+	s.Server.Listener.Close()
+	s.Server.Listener = l
+	s.Server.Start()
+
+	return s, nil
+}
+
+func newServer() *Server {
+	return &Server{
+		StateDelay: DefaultStateDelay,
+		apps:       make(map[string]*app),
+		peers:      make(map[string]fly.WireGuardPeer),
+	}
+}
+
+// Urls are the base URLs to use this server for every Fly API
+func (s *Server) Urls() fly.ApiUrls {
+	return fly.ApiUrls{
+		Machines: s.URL,
+		GraphQL:  s.URL + "/graphql",
+		Logs:     s.URL,
+		Probe:    s.URL,
+	}
+}
+
+// Requests lists the requests made so far, e.g. "POST /v1/apps", with their query string if any
+func (s *Server) Requests() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]string{}, s.requests...)
+}
+
+// Machine returns a copy of a machine, with its current state
+func (s *Server) Machine(appName, id string) (*fly.Machine, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.apps[appName]
+	if !ok {
+		return nil, false
+	}
+
+	m, ok := a.machines[id]
+	if !ok {
+		return nil, false
+	}
+
+	m.settle(time.Now())
+	machine := m.Machine
+
+	return &machine, true
+}
+
+// HasApp reports whether an app exists
+func (s *Server) HasApp(appName string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.apps[appName]
+
+	return ok
+}
+
+// HasWireGuardPeer reports whether a WireGuard peer exists
+func (s *Server) HasWireGuardPeer(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.peers[name]
+
+	return ok
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	r.Body = io.NopCloser(bytes.NewReader(body))
+
+	segments := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+
+	// The control API isn't recorded, or subject to faults
+	if segments[0] == "_flytest" {
+		s.control(w, r, segments[1:])
+		return
+	}
+
+	s.mu.Lock()
+	s.requests = append(s.requests, r.Method+" "+r.URL.RequestURI())
+	fault := s.matchFault(r, body)
+	s.mu.Unlock()
+
+	if fault != nil && fault.apply(w, r) {
+		return
+	}
+
+	switch {
+	case len(segments) == 1 && segments[0] == "":
+		// Region probes are answered by the region they prefer
+		w.Header().Set("Fly-Region", r.Header.Get("Fly-Prefer-Region"))
+		w.WriteHeader(http.StatusOK)
+	case segments[0] == "graphql":
+		s.graphql(w, r, body)
+	case !authorized(r):
+		writeError(w, http.StatusUnauthorized, "missing or invalid authorization token")
+	case len(segments) >= 2 && segments[0] == "v1" && segments[1] == "apps":
+		s.machinesApi(w, r, body, segments[2:])
+	case len(segments) == 5 && segments[0] == "api" && segments[4] == "logs":
+		s.logs(w, r, segments[3])
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+// faultRequest is the JSON body used to add a fault through the control API
+type faultRequest struct {
+	Fault
+	Delay string `json:"delay"`
+}
+
+// control handles the /_flytest API, used to inject faults from outside the process:
+// POST /_flytest/faults adds a fault, DELETE /_flytest/faults clears them and
+// GET /_flytest/requests lists requests made so far.
+func (s *Server) control(w http.ResponseWriter, r *http.Request, segments []string) {
+	switch {
+	case len(segments) == 1 && segments[0] == "faults" && r.Method == http.MethodPost:
+		f := &faultRequest{}
+		if err := json.NewDecoder(r.Body).Decode(f); err != nil {
+			writeError(w, http.StatusBadRequest, "invalid fault: "+err.Error())
+			return
+		}
+
+		if len(f.Delay) > 0 {
+			delay, err := time.ParseDuration(f.Delay)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "invalid fault delay: "+err.Error())
+				return
+			}
+
+			f.Fault.Delay = delay
+		}
+
+		s.Inject(f.Fault)
+		w.WriteHeader(http.StatusCreated)
+	case len(segments) == 1 && segments[0] == "faults" && r.Method == http.MethodDelete:
+		s.ClearFaults()
+		w.WriteHeader(http.StatusOK)
+	case len(segments) == 1 && segments[0] == "requests" && r.Method == http.MethodGet:
+		writeJson(w, http.StatusOK, s.Requests())
+	default:
+		writeError(w, http.StatusNotFound, "not found")
+	}
+}
+
+func (s *Server) newId(prefix string) string {
+	s.nextId++
+
+	return fmt.Sprintf("%s%012x", prefix, s.nextId)
+}
+
+func authorized(r *http.Request) bool {
+	auth := r.Header.Get("Authorization")
+
+	return strings.HasPrefix(auth, "Bearer ") && len(auth) > len("Bearer ")
+}
+
+func writeJson(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJson(w, status, map[string]string{"error": msg})
+}
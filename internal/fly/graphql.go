@@ -0,0 +1,148 @@
+package fly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/vessel-app/vessel-cli/internal/logger"
+)
+
+// graphqlUrl is Fly's GraphQL API. Set FLY_GRAPHQL_URL to use another server.
+var graphqlUrl = "https://api.fly.io/graphql"
+
+func init() {
+	if u := os.Getenv("FLY_GRAPHQL_URL"); len(u) > 0 {
+		graphqlUrl = u
+	}
+}
+
+// GraphRequest is a GraphQL query with its variables. Variables is marshalled as JSON,
+// so a struct with json tags can be used to type them.
+type GraphRequest struct {
+	Query     string      `json:"query"`
+	Variables interface{} `json:"variables,omitempty"`
+}
+
+// GraphResponse is the JSON body of a GraphQL response. Data is decoded into the given value.
+type GraphResponse struct {
+	Data   interface{} `json:"data"`
+	Errors GraphErrors `json:"errors"`
+}
+
+// GraphError is an entry in the "errors" array of a GraphQL response
+type GraphError struct {
+	Message    string        `json:"message"`
+	Path       []interface{} `json:"path"`
+	Extensions struct {
+		Code string `json:"code"`
+		// FieldName is set by schema errors, such as undefinedField
+		FieldName string `json:"fieldName"`
+	} `json:"extensions"`
+}
+
+func (e *GraphError) Error() string {
+	msg := e.Message
+
+	if len(e.Path) > 0 {
+		path := make([]string, 0, len(e.Path))
+		for _, p := range e.Path {
+			path = append(path, fmt.Sprint(p))
+		}
+
+		msg = fmt.Sprintf("%s: %s", strings.Join(path, "."), msg)
+	}
+
+	if len(e.Extensions.Code) > 0 {
+		msg = fmt.Sprintf("%s (%s)", msg, e.Extensions.Code)
+	}
+
+	return msg
+}
+
+// GraphErrors is returned when a GraphQL response has errors, even if some data was returned
+type GraphErrors []GraphError
+
+func (e GraphErrors) Error() string {
+	messages := make([]string, 0, len(e))
+	for i := range e {
+		messages = append(messages, e[i].Error())
+	}
+
+	return "graphql error: " + strings.Join(messages, "; ")
+}
+
+// HasCode reports whether any of the errors has the given extensions code
+func (e GraphErrors) HasCode(code string) bool {
+	for i := range e {
+		if e[i].Extensions.Code == code {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *GraphRequest) ToRequest(token string) (*http.Request, error) {
+	query, err := json.Marshal(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal graphql request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, graphqlUrl, bytes.NewBuffer(query))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request object: %w", err)
+	}
+
+	// Some queries, such as the nearest region, don't need a token
+	if len(token) > 0 {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("fly-force-trace", "vessel") // Help debug api call issues in Fly
+
+	return req, nil
+}
+
+// DoGraphRequest runs a GraphQL query, decoding its data into data. If the response
+// has any errors, they're returned as GraphErrors.
+func DoGraphRequest(token string, r *GraphRequest, data interface{}) error {
+	responseBody, err := DoRequest(token, r)
+
+	if err != nil {
+		// Errors may also come with an error status code. Both are wrapped,
+		// so callers can check for either with errors.As.
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) {
+			gr := &GraphResponse{}
+			if json.Unmarshal([]byte(reqErr.Body), gr) == nil && len(gr.Errors) > 0 {
+				err = fmt.Errorf("%w: %w", reqErr, gr.Errors)
+			}
+		}
+
+		return fmt.Errorf("request error: %w", err)
+	}
+
+	gr := &GraphResponse{
+		Data: data,
+	}
+
+	if err = json.Unmarshal(responseBody, gr); err != nil {
+		return fmt.Errorf("could not unmarshall json: %w", err)
+	}
+
+	if len(gr.Errors) > 0 {
+		logger.GetLogger().Debug("caller", "fly.DoGraphRequest", "msg", "graphql response has errors", "error", gr.Errors)
+		return gr.Errors
+	}
+
+	return nil
+}
@@ -0,0 +1,67 @@
+package fly_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vessel-app/vessel-cli/internal/fly"
+	"github.com/vessel-app/vessel-cli/internal/fly/flytest"
+)
+
+func TestDoGraphRequestWrapsErrorsWithStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		_, _ = w.Write([]byte(`{"data":null,"errors":[{"message":"You must be authenticated to view this.","extensions":{"code":"UNAUTHORIZED"}}]}`))
+	}))
+	defer server.Close()
+
+	urls := fly.CurrentApiUrls()
+	t.Cleanup(func() { fly.UseApiUrls(urls) })
+	fly.UseApiUrls(fly.ApiUrls{GraphQL: server.URL})
+
+	err := fly.DoGraphRequest("token", &fly.GraphRequest{Query: "query { viewer { id } }"}, nil)
+
+	var graphErrs fly.GraphErrors
+	if !errors.As(err, &graphErrs) {
+		t.Fatalf("expected GraphErrors, got %v", err)
+	}
+
+	if !graphErrs.HasCode("UNAUTHORIZED") {
+		t.Errorf("expected UNAUTHORIZED code, got %v", graphErrs)
+	}
+
+	var reqErr *fly.RequestError
+	if !errors.As(err, &reqErr) {
+		t.Fatalf("expected RequestError, got %v", err)
+	}
+
+	if reqErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected status 401, got %d", reqErr.StatusCode)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	server := flytest.NewServer()
+	defer server.Close()
+
+	urls := fly.CurrentApiUrls()
+	t.Cleanup(func() { fly.UseApiUrls(urls) })
+	fly.UseApiUrls(server.Urls())
+
+	user, err := fly.GetUser("token")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if user.Email != "vessel@example.com" {
+		t.Errorf("expected the current user's email, got '%s'", user.Email)
+	}
+
+	if len(user.Organizations.Nodes) != 1 || user.Organizations.Nodes[0].Slug != "personal" {
+		t.Errorf("unexpected organizations: %v", user.Organizations.Nodes)
+	}
+}
@@ -6,13 +6,64 @@ import (
 	"io"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 )
 
-var flyApiHost string
+// machinesApiUrl is the base URL of Fly's Machines API. It defaults to the public API.
+// Set FLY_MACHINES_URL to use another server, or FLY_HOST to use _api.internal over
+// Fly's VPN. Set FLY_MACHINES_URL to machinesProxyUrl to go through `fly machine api-proxy`.
+var machinesApiUrl = "https://api.machines.dev"
 
-type GraphResponse struct {
-	Data interface{} `json:"data"`
+// machinesProxyUrl is where `fly machine api-proxy` listens
+const machinesProxyUrl = "http://127.0.0.1:4280"
+
+// RequestError is returned for API responses with an error status code
+type RequestError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("invalid request: status=%d, body=%s", e.StatusCode, e.Body)
+}
+
+// ApiUrls are the base URLs of the Fly APIs Vessel uses
+type ApiUrls struct {
+	Machines string
+	GraphQL  string
+	Logs     string
+	Probe    string
+}
+
+// UseApiUrls points API calls at other servers, such as a fake Fly API (see the flytest
+// package). Empty URLs are left unchanged.
+func UseApiUrls(urls ApiUrls) {
+	if len(urls.Machines) > 0 {
+		machinesApiUrl = strings.TrimRight(urls.Machines, "/")
+	}
+
+	if len(urls.GraphQL) > 0 {
+		graphqlUrl = urls.GraphQL
+	}
+
+	if len(urls.Logs) > 0 {
+		logsApiUrl = strings.TrimRight(urls.Logs, "/")
+	}
+
+	if len(urls.Probe) > 0 {
+		regionProbeUrl = urls.Probe
+	}
+}
+
+// CurrentApiUrls returns the URLs API calls are made to, e.g. to restore them after UseApiUrls
+func CurrentApiUrls() ApiUrls {
+	return ApiUrls{
+		Machines: machinesApiUrl,
+		GraphQL:  graphqlUrl,
+		Logs:     logsApiUrl,
+		Probe:    regionProbeUrl,
+	}
 }
 
 type FlyRequest interface {
@@ -21,16 +72,13 @@ type FlyRequest interface {
 
 func init() {
 	// Use "_api.internal" if connected to Fly's VPN
-	flyHost := os.Getenv("FLY_HOST")
-
-	if len(flyHost) > 0 {
-		flyApiHost = flyHost
-		return
+	if flyHost := os.Getenv("FLY_HOST"); len(flyHost) > 0 {
+		machinesApiUrl = "http://" + flyHost + ":4280"
 	}
 
-	// Else we assume the use of
-	// "fly machines api-proxy"
-	flyApiHost = "127.0.0.1"
+	if u := strings.TrimRight(os.Getenv("FLY_MACHINES_URL"), "/"); len(u) > 0 {
+		machinesApiUrl = u
+	}
 }
 
 func DoRequest(token string, r FlyRequest) ([]byte, error) {
@@ -48,7 +96,7 @@ func DoRequest(token string, r FlyRequest) ([]byte, error) {
 	if result.StatusCode > 299 {
 		// todo: Log this raw output
 		body, _ := io.ReadAll(result.Body)
-		return nil, fmt.Errorf("invalid request: status=%d, body=%s", result.StatusCode, string(body))
+		return nil, &RequestError{StatusCode: result.StatusCode, Body: string(body)}
 	}
 
 	return io.ReadAll(result.Body)
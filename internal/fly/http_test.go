@@ -0,0 +1,13 @@
+package fly
+
+import "testing"
+
+// useApiUrls points API calls at test servers, restoring the previous URLs once the test is done
+func useApiUrls(t *testing.T, urls ApiUrls) {
+	t.Helper()
+
+	previous := CurrentApiUrls()
+	t.Cleanup(func() { UseApiUrls(previous) })
+
+	UseApiUrls(urls)
+}
@@ -1,11 +1,7 @@
 package fly
 
 import (
-	"bytes"
-	"encoding/json"
 	"fmt"
-	"net/http"
-	"strings"
 )
 
 /*****************
@@ -20,57 +16,38 @@ type GetAppIpResponse struct {
 	App App `json:"app"`
 }
 
-type AllocateIpRequest struct {
-	App string
-	V6  bool
+type AllocateIpInput struct {
+	AppId string `json:"appId"`
+	Type  string `json:"type"`
 }
 
-func (i *AllocateIpRequest) ToRequest(token string) (*http.Request, error) {
-	ipType := "v6"
-	if i.V6 == false {
-		ipType = "v4"
-	}
-
-	query := []byte(fmt.Sprintf(strings.Replace(`{
-		"query": "mutation($input: AllocateIPAddressInput!) { allocateIpAddress(input: $input) { ipAddress { id address type region createdAt } } }",
-		"variables": { "input": { "appId": "%s", "type": "%s" } }
-	}`, "\n", "", -1), i.App, ipType))
-
-	req, err := http.NewRequest(http.MethodPost, "https://api.fly.io/graphql", bytes.NewBuffer(query))
-
-	if err != nil {
-		return nil, fmt.Errorf("could not create http request object: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("fly-force-trace", "vessel") // Help debug api call issues in Fly
-
-	return req, nil
+type allocateIpVariables struct {
+	Input AllocateIpInput `json:"input"`
 }
 
 func AllocateIp(token, app string, useV6 bool) (*IpAddressAllocation, error) {
-	req := &AllocateIpRequest{
-		App: app,
-		V6:  useV6,
+	ipType := "v6"
+	if useV6 == false {
+		ipType = "v4"
 	}
 
-	responseBody, err := DoRequest(token, req)
-
-	if err != nil {
-		return nil, fmt.Errorf("request error: %w", err)
+	req := &GraphRequest{
+		Query: "mutation($input: AllocateIPAddressInput!) { allocateIpAddress(input: $input) { ipAddress { id address type region createdAt } } }",
+		Variables: &allocateIpVariables{
+			Input: AllocateIpInput{
+				AppId: app,
+				Type:  ipType,
+			},
+		},
 	}
 
 	i := &IpAddressAllocationResponse{}
-	gr := &GraphResponse{
-		Data: i,
+	if err := DoGraphRequest(token, req, i); err != nil {
+		return nil, err
 	}
 
-	err = json.Unmarshal(responseBody, gr)
-
-	if err != nil {
-		return nil, fmt.Errorf("could not unmarshall json: %w", err)
+	if len(i.Allocation.IpAddress.Address) == 0 {
+		return nil, fmt.Errorf("no IP address was allocated to app: %s", app)
 	}
 
 	return &i.Allocation, nil
@@ -80,48 +57,21 @@ func AllocateIp(token, app string, useV6 bool) (*IpAddressAllocation, error) {
  * GET APP IP
 ****************/
 
-type GetAppIpRequest struct {
-	App string
-}
-
-func (i *GetAppIpRequest) ToRequest(token string) (*http.Request, error) {
-	query := []byte(fmt.Sprintf(strings.Replace(`{
-		"query": "query ($appName: String!) { app(name: $appName) { ipAddresses { nodes {id address type region createdAt } } } }",
-		"variables": { "appName": "%s" }
-	}`, "\n", "", -1), i.App))
-
-	req, err := http.NewRequest(http.MethodPost, "https://api.fly.io/graphql", bytes.NewBuffer(query))
-
-	if err != nil {
-		return nil, fmt.Errorf("could not create http request object: %w", err)
-	}
-
-	req.Header.Set("Authorization", "Bearer "+token)
-	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
-	req.Header.Set("Accept", "application/json")
-
-	return req, nil
+type appNameVariables struct {
+	AppName string `json:"appName"`
 }
 
 func GetAppIp(token, app string) (*IpAddress, error) {
-	req := &GetAppIpRequest{
-		App: app,
-	}
-
-	responseBody, err := DoRequest(token, req)
-
-	if err != nil {
-		return nil, fmt.Errorf("request error: %w", err)
+	req := &GraphRequest{
+		Query: "query ($appName: String!) { app(name: $appName) { ipAddresses { nodes {id address type region createdAt } } } }",
+		Variables: &appNameVariables{
+			AppName: app,
+		},
 	}
 
 	a := &GetAppIpResponse{}
-	gr := &GraphResponse{
-		Data: a,
-	}
-	err = json.Unmarshal(responseBody, gr)
-
-	if err != nil {
-		return nil, fmt.Errorf("could not unmarshall json: %w", err)
+	if err := DoGraphRequest(token, req, a); err != nil {
+		return nil, err
 	}
 
 	if len(a.App.IpAddresses.Nodes) == 0 {
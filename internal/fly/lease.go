@@ -0,0 +1,258 @@
+package fly
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/vessel-app/vessel-cli/internal/logger"
+)
+
+// leaseTtl is how long (in seconds) a lease lasts if it isn't released,
+// e.g. when Vessel is killed part way through an operation
+const leaseTtl = 120
+
+// leaseNonceHeader passes the nonce of a held lease to mutating machine requests
+const leaseNonceHeader = "fly-machine-lease-nonce"
+
+// LeaseOptions control how calls changing a machine acquire its lease
+type LeaseOptions struct {
+	// Force takes over a lease held by someone else, instead of failing with a LeaseHeldError
+	Force bool
+}
+
+// Lease gives its holder exclusive use of a machine, so
+// teammates can't change a machine at the same time
+type Lease struct {
+	Nonce       string `json:"nonce"`
+	ExpiresAt   int64  `json:"expires_at"`
+	Owner       string `json:"owner"`
+	Description string `json:"description"`
+}
+
+type leaseResponse struct {
+	Status string `json:"status"`
+	Data   Lease  `json:"data"`
+}
+
+// LeaseHeldError is returned when someone else holds the lease of a machine
+type LeaseHeldError struct {
+	Machine string
+	Lease   *Lease
+}
+
+func (e *LeaseHeldError) Error() string {
+	holder := e.Lease.Owner
+	if len(holder) == 0 {
+		holder = "someone else"
+	}
+
+	if len(e.Lease.Description) > 0 {
+		holder += " (" + e.Lease.Description + ")"
+	}
+
+	return fmt.Sprintf("machine %s is in use by %s, lease nonce %s expires at %s", e.Machine, holder, e.Lease.Nonce, time.Unix(e.Lease.ExpiresAt, 0).Format(time.RFC3339))
+}
+
+/*****************
+ * Acquire Lease
+ ****************/
+
+type AcquireLeaseRequest struct {
+	App         string `json:"-"`
+	Machine     string `json:"-"`
+	Ttl         int    `json:"ttl"`
+	Description string `json:"description,omitempty"`
+}
+
+func (l *AcquireLeaseRequest) ToRequest(token string) (*http.Request, error) {
+	data, err := json.Marshal(l)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal lease request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines/%s/lease", l.App, l.Machine), bytes.NewBuffer(data))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request object: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Accept", "application/json")
+
+	return req, nil
+}
+
+// AcquireLease leases a machine for ttl seconds. A LeaseHeldError is
+// returned if someone else already holds the machine's lease.
+func AcquireLease(token, app, machine string, ttl int) (*Lease, error) {
+	req := &AcquireLeaseRequest{
+		App:         app,
+		Machine:     machine,
+		Ttl:         ttl,
+		Description: leaseDescription(),
+	}
+
+	responseBody, err := DoRequest(token, req)
+
+	if err != nil {
+		var reqErr *RequestError
+		if errors.As(err, &reqErr) && reqErr.StatusCode == http.StatusConflict {
+			held, getErr := GetLease(token, app, machine)
+
+			if getErr != nil {
+				return nil, fmt.Errorf("machine lease is held, and could not get lease: %w", getErr)
+			}
+
+			return nil, &LeaseHeldError{Machine: machine, Lease: held}
+		}
+
+		return nil, fmt.Errorf("request error: %w", err)
+	}
+
+	l := &leaseResponse{}
+	err = json.Unmarshal(responseBody, l)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshall json: %w", err)
+	}
+
+	return &l.Data, nil
+}
+
+// leaseDescription tells teammates where a lease is held from
+func leaseDescription() string {
+	host, err := os.Hostname()
+
+	if err != nil {
+		return "vessel"
+	}
+
+	return "vessel on " + host
+}
+
+/*****************
+ * Get Lease
+ ****************/
+
+type GetLeaseRequest struct {
+	App     string
+	Machine string
+}
+
+func (l *GetLeaseRequest) ToRequest(token string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines/%s/lease", l.App, l.Machine), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request object: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Accept", "application/json")
+
+	return req, nil
+}
+
+// GetLease returns the current lease of a machine
+func GetLease(token, app, machine string) (*Lease, error) {
+	req := &GetLeaseRequest{
+		App:     app,
+		Machine: machine,
+	}
+
+	responseBody, err := DoRequest(token, req)
+
+	if err != nil {
+		return nil, fmt.Errorf("request error: %w", err)
+	}
+
+	l := &leaseResponse{}
+	err = json.Unmarshal(responseBody, l)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshall json: %w", err)
+	}
+
+	return &l.Data, nil
+}
+
+/*****************
+ * Release Lease
+ ****************/
+
+type ReleaseLeaseRequest struct {
+	App     string
+	Machine string
+	Nonce   string
+}
+
+func (l *ReleaseLeaseRequest) ToRequest(token string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines/%s/lease", l.App, l.Machine), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request object: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set(leaseNonceHeader, l.Nonce)
+
+	return req, nil
+}
+
+// ReleaseLease releases the lease of a machine with the given nonce
+func ReleaseLease(token, app, machine, nonce string) error {
+	req := &ReleaseLeaseRequest{
+		App:     app,
+		Machine: machine,
+		Nonce:   nonce,
+	}
+
+	_, err := DoRequest(token, req)
+
+	if err != nil {
+		return fmt.Errorf("request error: %w", err)
+	}
+
+	return nil
+}
+
+// withLease holds the lease of a machine while running a mutating call. When forcing the
+// lease, a lease held by someone else is released so the call can go ahead.
+func withLease(token, app, machine string, opts LeaseOptions, call func(nonce string) error) error {
+	lease, err := AcquireLease(token, app, machine, leaseTtl)
+
+	if err != nil {
+		var held *LeaseHeldError
+		if !errors.As(err, &held) || !opts.Force {
+			return err
+		}
+
+		logger.GetLogger().Debug("caller", "fly.withLease", "msg", "forcing release of held lease", "machine", machine, "owner", held.Lease.Owner, "nonce", held.Lease.Nonce)
+
+		if err = ReleaseLease(token, app, machine, held.Lease.Nonce); err != nil {
+			return fmt.Errorf("could not release held lease: %w", err)
+		}
+
+		if lease, err = AcquireLease(token, app, machine, leaseTtl); err != nil {
+			return err
+		}
+	}
+
+	// Releasing fails if the call deleted the machine, which is fine
+	defer func() {
+		if err := ReleaseLease(token, app, machine, lease.Nonce); err != nil {
+			logger.GetLogger().Debug("caller", "fly.withLease", "msg", "could not release lease", "machine", machine, "error", err)
+		}
+	}()
+
+	return call(lease.Nonce)
+}
@@ -0,0 +1,192 @@
+package fly
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeLeases is a Machines API holding the lease of a single machine, which can be started
+type fakeLeases struct {
+	mu       sync.Mutex
+	held     *Lease
+	leases   int
+	requests []string
+	// startStatus is the status code of start requests, once the lease is checked
+	startStatus int
+}
+
+func newFakeLeases(t *testing.T) *fakeLeases {
+	t.Helper()
+
+	f := &fakeLeases{startStatus: http.StatusOK}
+	server := httptest.NewServer(f)
+	t.Cleanup(server.Close)
+
+	useApiUrls(t, ApiUrls{Machines: server.URL})
+
+	return f
+}
+
+func (f *fakeLeases) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	nonce := r.Header.Get(leaseNonceHeader)
+	f.requests = append(f.requests, strings.TrimSpace(r.Method+" "+r.URL.Path+" "+nonce))
+
+	switch {
+	case strings.HasSuffix(r.URL.Path, "/lease") && r.Method == http.MethodPost:
+		if f.held != nil {
+			http.Error(w, `{"error":"lease currently held"}`, http.StatusConflict)
+			return
+		}
+
+		f.leases++
+		f.held = &Lease{Nonce: fmt.Sprintf("nonce-%d", f.leases), ExpiresAt: 1700000000, Owner: "me@example.com"}
+		_ = json.NewEncoder(w).Encode(&leaseResponse{Status: "success", Data: *f.held})
+	case strings.HasSuffix(r.URL.Path, "/lease") && r.Method == http.MethodGet:
+		if f.held == nil {
+			http.Error(w, `{"error":"no lease"}`, http.StatusNotFound)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(&leaseResponse{Status: "success", Data: *f.held})
+	case strings.HasSuffix(r.URL.Path, "/lease") && r.Method == http.MethodDelete:
+		if f.held == nil || f.held.Nonce != nonce {
+			http.Error(w, `{"error":"lease nonce does not match"}`, http.StatusConflict)
+			return
+		}
+
+		f.held = nil
+		_, _ = w.Write([]byte(`{"ok":true}`))
+	case strings.HasSuffix(r.URL.Path, "/start"):
+		if f.held == nil || f.held.Nonce != nonce {
+			http.Error(w, `{"error":"machine is leased"}`, http.StatusConflict)
+			return
+		}
+
+		w.WriteHeader(f.startStatus)
+		_, _ = w.Write([]byte(`{}`))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// holdLease makes a teammate hold the machine's lease
+func (f *fakeLeases) holdLease() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.held = &Lease{Nonce: "teammate", ExpiresAt: 1700000000, Owner: "teammate@example.com", Description: "vessel on laptop"}
+}
+
+func (f *fakeLeases) state() (*Lease, []string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.held, slices.Clone(f.requests)
+}
+
+func TestAcquireLeaseHeldBySomeoneElse(t *testing.T) {
+	f := newFakeLeases(t)
+	f.holdLease()
+
+	_, err := AcquireLease("token", "app", "m1", leaseTtl)
+
+	var held *LeaseHeldError
+	if !errors.As(err, &held) {
+		t.Fatalf("expected a LeaseHeldError, got %v", err)
+	}
+
+	if held.Machine != "m1" || held.Lease.Owner != "teammate@example.com" || held.Lease.Nonce != "teammate" {
+		t.Errorf("expected the teammate's lease, got %+v", held.Lease)
+	}
+
+	if err = StartMachine("token", "app", "m1", LeaseOptions{}); !errors.As(err, &held) {
+		t.Fatalf("expected starting the machine to fail with a LeaseHeldError, got %v", err)
+	}
+
+	lease, requests := f.state()
+
+	if lease == nil || lease.Nonce != "teammate" {
+		t.Errorf("expected the teammate to keep the lease, got %+v", lease)
+	}
+
+	if slices.Contains(requests, "POST /v1/apps/app/machines/m1/start") || slices.Contains(requests, "DELETE /v1/apps/app/machines/m1/lease teammate") {
+		t.Errorf("expected the machine to be left alone, got %v", requests)
+	}
+}
+
+func TestForcedLeaseTakesOverHeldLease(t *testing.T) {
+	f := newFakeLeases(t)
+	f.holdLease()
+
+	if err := StartMachine("token", "app", "m1", LeaseOptions{Force: true}); err != nil {
+		t.Fatalf("expected the lease to be taken over, got %v", err)
+	}
+
+	lease, requests := f.state()
+
+	expected := []string{
+		"POST /v1/apps/app/machines/m1/lease",
+		"GET /v1/apps/app/machines/m1/lease",
+		"DELETE /v1/apps/app/machines/m1/lease teammate",
+		"POST /v1/apps/app/machines/m1/lease",
+		"POST /v1/apps/app/machines/m1/start nonce-1",
+		"DELETE /v1/apps/app/machines/m1/lease nonce-1",
+	}
+
+	if !slices.Equal(requests, expected) {
+		t.Errorf("unexpected requests:\n%s\nexpected:\n%s", strings.Join(requests, "\n"), strings.Join(expected, "\n"))
+	}
+
+	if lease != nil {
+		t.Errorf("expected the lease to be released, got %+v", lease)
+	}
+}
+
+func TestWithLeaseReleasesLeaseOnError(t *testing.T) {
+	f := newFakeLeases(t)
+	f.startStatus = http.StatusInternalServerError
+
+	err := StartMachine("token", "app", "m1", LeaseOptions{})
+
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || reqErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected the start request's error, got %v", err)
+	}
+
+	lease, requests := f.state()
+
+	if lease != nil {
+		t.Errorf("expected the lease to be released, got %+v", lease)
+	}
+
+	if requests[len(requests)-1] != "DELETE /v1/apps/app/machines/m1/lease nonce-1" {
+		t.Errorf("expected the lease to be released last, got %v", requests)
+	}
+
+	// The call isn't made if the lease can't be acquired, and nothing is released
+	f.holdLease()
+
+	called := false
+	err = withLease("token", "app", "m1", LeaseOptions{}, func(nonce string) error {
+		called = true
+		return nil
+	})
+
+	if err == nil || called {
+		t.Errorf("expected the call to be skipped, got %v", err)
+	}
+
+	if lease, _ = f.state(); lease == nil || lease.Nonce != "teammate" {
+		t.Errorf("expected the teammate to keep the lease, got %+v", lease)
+	}
+}
@@ -0,0 +1,215 @@
+package fly
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/vessel-app/vessel-cli/internal/logger"
+)
+
+// logsApiUrl is the base URL of Fly's log API. Set FLY_LOGS_URL to use another
+// server, such as a local stand-in serving newline-delimited JSON log entries.
+var logsApiUrl = "https://api.fly.io"
+
+// Polling and reconnect timings used when following logs, shortened by tests
+var (
+	logsPollInterval = time.Second
+	logsMaxReconnect = 10 * time.Second
+	logsFirstBackoff = 500 * time.Millisecond
+)
+
+func init() {
+	if u := strings.TrimRight(os.Getenv("FLY_LOGS_URL"), "/"); len(u) > 0 {
+		logsApiUrl = u
+	}
+}
+
+// LogEntry is a line of output from a process running in one of an app's machines
+type LogEntry struct {
+	Timestamp string `json:"timestamp"`
+	Message   string `json:"message"`
+	Level     string `json:"level"`
+	Instance  string `json:"instance"`
+	Region    string `json:"region"`
+}
+
+// Time parses the entry's timestamp, returning the zero time if it can't be parsed
+func (e *LogEntry) Time() time.Time {
+	t, _ := time.Parse(time.RFC3339Nano, e.Timestamp)
+	return t
+}
+
+// LogOptions filters and controls the logs read from an app
+type LogOptions struct {
+	Region  string
+	Machine string
+	// Follow keeps reading new logs until the context ends
+	Follow bool
+}
+
+// logsLine is a JSON value read from the log API. The API responds with pages of
+// entries, while stand-ins may stream bare entries as newline-delimited JSON.
+type logsLine struct {
+	LogEntry
+	Data []struct {
+		Attributes LogEntry `json:"attributes"`
+	} `json:"data"`
+	Meta struct {
+		NextToken string `json:"next_token"`
+	} `json:"meta"`
+}
+
+type LogsRequest struct {
+	App       string
+	Region    string
+	Machine   string
+	NextToken string
+}
+
+func (l *LogsRequest) ToRequest(token string) (*http.Request, error) {
+	query := url.Values{}
+
+	if len(l.Region) > 0 {
+		query.Set("region", l.Region)
+	}
+
+	if len(l.Machine) > 0 {
+		query.Set("instance", l.Machine)
+	}
+
+	if len(l.NextToken) > 0 {
+		query.Set("next_token", l.NextToken)
+	}
+
+	logsUrl := fmt.Sprintf("%s/api/v1/apps/%s/logs", logsApiUrl, l.App)
+	if len(query) > 0 {
+		logsUrl += "?" + query.Encode()
+	}
+
+	req, err := http.NewRequest(http.MethodGet, logsUrl, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request object: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json, application/x-ndjson")
+
+	return req, nil
+}
+
+// readLogs makes a single request to the log API, passing each entry to handle as it's decoded.
+// The token to continue from (if any) and the number of entries read are returned.
+func readLogs(ctx context.Context, token string, r *LogsRequest, handle func(*LogEntry)) (string, int, error) {
+	req, err := r.ToRequest(token)
+
+	if err != nil {
+		return "", 0, fmt.Errorf("could not create request: %w", err)
+	}
+
+	// No client timeout, a stand-in may hold the stream open. The context ends it instead.
+	logger.GetLogger().Debug("caller", "fly.readLogs", "msg", "making http request", "url", req.URL)
+	result, err := http.DefaultClient.Do(req.WithContext(ctx))
+
+	if err != nil {
+		return "", 0, fmt.Errorf("http client error: %w", err)
+	}
+
+	defer result.Body.Close()
+
+	if result.StatusCode > 299 {
+		body, _ := io.ReadAll(result.Body)
+		return "", 0, &RequestError{StatusCode: result.StatusCode, Body: string(body)}
+	}
+
+	nextToken := r.NextToken
+	count := 0
+	decoder := json.NewDecoder(result.Body)
+
+	for {
+		line := &logsLine{}
+		if err = decoder.Decode(line); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nextToken, count, nil
+			}
+
+			return nextToken, count, fmt.Errorf("could not decode log entry: %w", err)
+		}
+
+		if len(line.Message) > 0 {
+			handle(&line.LogEntry)
+			count++
+		}
+
+		for i := range line.Data {
+			handle(&line.Data[i].Attributes)
+			count++
+		}
+
+		if len(line.Meta.NextToken) > 0 {
+			nextToken = line.Meta.NextToken
+		}
+	}
+}
+
+// StreamLogs passes an app's log entries to handle. When following, new entries are read until
+// the context ends, reconnecting with backoff whenever the stream drops.
+func StreamLogs(ctx context.Context, token, app string, opts *LogOptions, handle func(*LogEntry)) error {
+	req := &LogsRequest{
+		App:     app,
+		Region:  opts.Region,
+		Machine: opts.Machine,
+	}
+
+	backoff := logsFirstBackoff
+
+	for {
+		nextToken, count, err := readLogs(ctx, token, req, handle)
+		req.NextToken = nextToken
+
+		if !opts.Follow {
+			return err
+		}
+
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		wait := logsPollInterval
+		if err != nil {
+			var reqErr *RequestError
+			if errors.As(err, &reqErr) && reqErr.StatusCode < 500 && reqErr.StatusCode != http.StatusTooManyRequests {
+				return err
+			}
+
+			logger.GetLogger().Debug("caller", "fly.StreamLogs", "msg", "log stream dropped, reconnecting", "backoff", backoff, "error", err)
+
+			wait = backoff
+			backoff = backoff * 2
+			if backoff > logsMaxReconnect {
+				backoff = logsMaxReconnect
+			}
+		} else {
+			backoff = logsFirstBackoff
+
+			// Check again right away while there are more logs to read
+			if count > 0 {
+				wait = 0
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(wait):
+		}
+	}
+}
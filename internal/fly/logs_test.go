@@ -0,0 +1,182 @@
+package fly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+)
+
+// useLogsServer points the log API at handler, with short polling and reconnect timings
+func useLogsServer(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	useApiUrls(t, ApiUrls{Logs: server.URL})
+
+	pollInterval, maxReconnect, firstBackoff := logsPollInterval, logsMaxReconnect, logsFirstBackoff
+	logsPollInterval, logsMaxReconnect, logsFirstBackoff = 10*time.Millisecond, 80*time.Millisecond, 20*time.Millisecond
+
+	t.Cleanup(func() {
+		logsPollInterval, logsMaxReconnect, logsFirstBackoff = pollInterval, maxReconnect, firstBackoff
+	})
+}
+
+func TestStreamLogsReadsNdjson(t *testing.T) {
+	useLogsServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+
+		for i := 1; i <= 3; i++ {
+			fmt.Fprintf(w, `{"timestamp":"2024-01-01T00:00:0%dZ","message":"line %d","instance":"m1","region":"iad"}`+"\n", i, i)
+			w.(http.Flusher).Flush()
+		}
+	})
+
+	var messages []string
+	err := StreamLogs(context.Background(), "token", "app", &LogOptions{}, func(e *LogEntry) {
+		messages = append(messages, e.Message)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(messages, []string{"line 1", "line 2", "line 3"}) {
+		t.Errorf("expected each line to be read, got %v", messages)
+	}
+}
+
+func TestStreamLogsFollowsNextToken(t *testing.T) {
+	pages := map[string]string{
+		"":   `{"data":[{"attributes":{"message":"one"}},{"attributes":{"message":"two"}}],"meta":{"next_token":"t1"}}`,
+		"t1": `{"data":[{"attributes":{"message":"three"}}],"meta":{"next_token":"t2"}}`,
+		"t2": `{"data":[],"meta":{"next_token":"t2"}}`,
+	}
+
+	var mu sync.Mutex
+	var tokens []string
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	useLogsServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("region") != "iad" || r.URL.Query().Get("instance") != "m1" {
+			t.Errorf("expected region and instance filters, got %s", r.URL.RawQuery)
+		}
+
+		token := r.URL.Query().Get("next_token")
+
+		mu.Lock()
+		tokens = append(tokens, token)
+		if len(tokens) == 4 {
+			cancel()
+		}
+		mu.Unlock()
+
+		_, _ = w.Write([]byte(pages[token]))
+	})
+
+	var messages []string
+	err := StreamLogs(ctx, "token", "app", &LogOptions{Region: "iad", Machine: "m1", Follow: true}, func(e *LogEntry) {
+		messages = append(messages, e.Message)
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(messages, []string{"one", "two", "three"}) {
+		t.Errorf("expected each page to be read once, got %v", messages)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// The last token is kept while there are no new logs
+	if !slices.Equal(tokens[:4], []string{"", "t1", "t2", "t2"}) {
+		t.Errorf("expected requests to continue from the last token, got %v", tokens)
+	}
+}
+
+func TestStreamLogsReconnectsWithBackoff(t *testing.T) {
+	var mu sync.Mutex
+	var requests []time.Time
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	useLogsServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, time.Now())
+		attempt := len(requests)
+		mu.Unlock()
+
+		// The stream drops a few times before logs come through
+		if attempt <= 5 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"message":"back"}` + "\n"))
+	})
+
+	var messages []string
+	err := StreamLogs(ctx, "token", "app", &LogOptions{Follow: true}, func(e *LogEntry) {
+		messages = append(messages, e.Message)
+		cancel()
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !slices.Equal(messages, []string{"back"}) {
+		t.Errorf("expected logs after reconnecting, got %v", messages)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// Waits double from the first backoff, up to the max
+	expected := []time.Duration{20 * time.Millisecond, 40 * time.Millisecond, 80 * time.Millisecond, 80 * time.Millisecond, 80 * time.Millisecond}
+
+	if len(requests) != len(expected)+1 {
+		t.Fatalf("expected %d requests, got %d", len(expected)+1, len(requests))
+	}
+
+	for i, min := range expected {
+		if gap := requests[i+1].Sub(requests[i]); gap < min {
+			t.Errorf("expected reconnect %d to wait at least %s, waited %s", i+1, min, gap)
+		}
+	}
+}
+
+func TestStreamLogsStopsOnClientErrors(t *testing.T) {
+	requests := 0
+
+	useLogsServer(t, func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	err := StreamLogs(ctx, "token", "app", &LogOptions{Follow: true}, func(e *LogEntry) {})
+
+	var reqErr *RequestError
+	if !errors.As(err, &reqErr) || reqErr.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected the request's error, got %v", err)
+	}
+
+	if requests != 1 {
+		t.Errorf("expected no reconnects, got %d requests", requests)
+	}
+}
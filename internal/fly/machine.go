@@ -2,31 +2,35 @@ package fly
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
-	"strings"
 	"time"
+
+	"github.com/vessel-app/vessel-cli/internal/logger"
+	"github.com/vessel-app/vessel-cli/internal/wait"
+	"golang.org/x/exp/slices"
 )
 
+// RunMachineRequest creates and starts a machine. The JSON body
+// is marshalled from the typed request rather than built by hand.
 type RunMachineRequest struct {
-	App    string
-	Region string
-	Image  string
-	Env    map[string]string
+	App    string        `json:"-"`
+	Name   string        `json:"name,omitempty"`
+	Region string        `json:"region,omitempty"`
+	Config MachineConfig `json:"config"`
 }
 
 func (m *RunMachineRequest) ToRequest(token string) (*http.Request, error) {
-	env := ""
-	for k, v := range m.Env {
-		env += fmt.Sprintf(`"%s": "%s",`, k, strings.Trim(v, "\n "))
-	}
-	env = strings.TrimRight(env, ",")
+	data, err := json.Marshal(m)
 
-	data := []byte(fmt.Sprintf(`{"name": "vessel-php", "region": "%s", "config": {"image": "%s", "env": {%s}, "services": [{"internal_port": 2222, "protocol": "tcp", "ports":[{"port": 22}]}, {"internal_port": 80, "protocol": "tcp", "ports":[{"port": 80, "handlers": ["http"]},{"port": 443, "handlers": ["tls", "http"]}]}]}}`, m.Region, m.Image, env))
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal machine request: %w", err)
+	}
 
-	// TODO: Decide on url to use (vpn vs proxy)
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://"+flyApiHost+":4280/v1/apps/%s/machines", m.App), bytes.NewBuffer(data))
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines", m.App), bytes.NewBuffer(data))
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create http request object: %w", err)
@@ -39,15 +43,12 @@ func (m *RunMachineRequest) ToRequest(token string) (*http.Request, error) {
 	return req, nil
 }
 
-func RunMachine(token, app, region, image, pubKey string) (*Machine, error) {
-	e := make(map[string]string)
-	e["VESSEL_PUBLIC_KEY"] = pubKey
-
+func RunMachine(token, app, name, region string, config *MachineConfig) (*Machine, error) {
 	req := &RunMachineRequest{
 		App:    app,
+		Name:   name,
 		Region: region,
-		Image:  image,
-		Env:    e,
+		Config: *config,
 	}
 
 	responseBody, err := DoRequest(token, req)
@@ -75,8 +76,7 @@ type ListMachinesResponse struct {
 }
 
 func (m *ListMachinesRequest) ToRequest(token string) (*http.Request, error) {
-	// TODO: Decide on url to use (vpn vs proxy)
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://"+flyApiHost+":4280/v1/apps/%s/machines", m.App), nil)
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines", m.App), nil)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create http request object: %w", err)
@@ -101,8 +101,9 @@ func ListMachines(token, app string) (*ListMachinesResponse, error) {
 		return nil, fmt.Errorf("request error: %w", err)
 	}
 
+	// The API responds with a bare JSON array of machines
 	m := &ListMachinesResponse{}
-	err = json.Unmarshal(responseBody, m)
+	err = json.Unmarshal(responseBody, &m.Machines)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not unmarshall json: %w", err)
@@ -117,8 +118,7 @@ type GetMachineRequest struct {
 }
 
 func (m *GetMachineRequest) ToRequest(token string) (*http.Request, error) {
-	// TODO: Decide on url to use (vpn vs proxy)
-	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("http://"+flyApiHost+":4280/v1/apps/%s/machines/%s", m.App, m.Machine), nil)
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines/%s", m.App, m.Machine), nil)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create http request object: %w", err)
@@ -154,14 +154,77 @@ func GetMachine(token, app, machine string) (*Machine, error) {
 	return m, nil
 }
 
+// UpdateMachineRequest replaces the config of a machine.
+// Fly restarts a running machine to apply the new config.
+type UpdateMachineRequest struct {
+	App     string        `json:"-"`
+	Machine string        `json:"-"`
+	Nonce   string        `json:"-"`
+	Config  MachineConfig `json:"config"`
+}
+
+func (m *UpdateMachineRequest) ToRequest(token string) (*http.Request, error) {
+	data, err := json.Marshal(m)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal machine request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines/%s", m.App, m.Machine), bytes.NewBuffer(data))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request object: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Accept", "application/json")
+
+	if len(m.Nonce) > 0 {
+		req.Header.Set(leaseNonceHeader, m.Nonce)
+	}
+
+	return req, nil
+}
+
+func UpdateMachine(token, app, machine string, config *MachineConfig, lease LeaseOptions) (*Machine, error) {
+	var responseBody []byte
+	err := withLease(token, app, machine, lease, func(nonce string) error {
+		req := &UpdateMachineRequest{
+			App:     app,
+			Machine: machine,
+			Nonce:   nonce,
+			Config:  *config,
+		}
+
+		var err error
+		responseBody, err = DoRequest(token, req)
+
+		return err
+	})
+
+	if err != nil {
+		return nil, fmt.Errorf("request error: %w", err)
+	}
+
+	m := &Machine{}
+	err = json.Unmarshal(responseBody, m)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshall json: %w", err)
+	}
+
+	return m, nil
+}
+
 type StartMachineRequest struct {
 	App     string
 	Machine string
+	Nonce   string
 }
 
 func (m *StartMachineRequest) ToRequest(token string) (*http.Request, error) {
-	// TODO: Decide on url to use (vpn vs proxy)
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://"+flyApiHost+":4280/v1/apps/%s/machines/%s/start", m.App, m.Machine), nil)
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines/%s/start", m.App, m.Machine), nil)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create http request object: %w", err)
@@ -171,16 +234,25 @@ func (m *StartMachineRequest) ToRequest(token string) (*http.Request, error) {
 	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
 	req.Header.Set("Accept", "application/json")
 
+	if len(m.Nonce) > 0 {
+		req.Header.Set(leaseNonceHeader, m.Nonce)
+	}
+
 	return req, nil
 }
 
-func StartMachine(token, app, machine string) error {
-	req := &StartMachineRequest{
-		App:     app,
-		Machine: machine,
-	}
+func StartMachine(token, app, machine string, lease LeaseOptions) error {
+	err := withLease(token, app, machine, lease, func(nonce string) error {
+		req := &StartMachineRequest{
+			App:     app,
+			Machine: machine,
+			Nonce:   nonce,
+		}
 
-	_, err := DoRequest(token, req)
+		_, err := DoRequest(token, req)
+
+		return err
+	})
 
 	if err != nil {
 		return fmt.Errorf("request error: %w", err)
@@ -192,11 +264,11 @@ func StartMachine(token, app, machine string) error {
 type StopMachineRequest struct {
 	App     string
 	Machine string
+	Nonce   string
 }
 
 func (m *StopMachineRequest) ToRequest(token string) (*http.Request, error) {
-	// TODO: Decide on url to use (vpn vs proxy)
-	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("http://"+flyApiHost+":4280/v1/apps/%s/machines/%s/stop", m.App, m.Machine), nil)
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines/%s/stop", m.App, m.Machine), nil)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create http request object: %w", err)
@@ -206,16 +278,69 @@ func (m *StopMachineRequest) ToRequest(token string) (*http.Request, error) {
 	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
 	req.Header.Set("Accept", "application/json")
 
+	if len(m.Nonce) > 0 {
+		req.Header.Set(leaseNonceHeader, m.Nonce)
+	}
+
 	return req, nil
 }
 
-func StopMachine(token, app, machine string) error {
-	req := &StopMachineRequest{
-		App:     app,
-		Machine: machine,
+func StopMachine(token, app, machine string, lease LeaseOptions) error {
+	err := withLease(token, app, machine, lease, func(nonce string) error {
+		req := &StopMachineRequest{
+			App:     app,
+			Machine: machine,
+			Nonce:   nonce,
+		}
+
+		_, err := DoRequest(token, req)
+
+		return err
+	})
+
+	if err != nil {
+		return fmt.Errorf("request error: %w", err)
 	}
 
-	_, err := DoRequest(token, req)
+	return nil
+}
+
+type RestartMachineRequest struct {
+	App     string
+	Machine string
+	Nonce   string
+}
+
+func (m *RestartMachineRequest) ToRequest(token string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines/%s/restart", m.App, m.Machine), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request object: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Accept", "application/json")
+
+	if len(m.Nonce) > 0 {
+		req.Header.Set(leaseNonceHeader, m.Nonce)
+	}
+
+	return req, nil
+}
+
+func RestartMachine(token, app, machine string, lease LeaseOptions) error {
+	err := withLease(token, app, machine, lease, func(nonce string) error {
+		req := &RestartMachineRequest{
+			App:     app,
+			Machine: machine,
+			Nonce:   nonce,
+		}
+
+		_, err := DoRequest(token, req)
+
+		return err
+	})
 
 	if err != nil {
 		return fmt.Errorf("request error: %w", err)
@@ -227,11 +352,19 @@ func StopMachine(token, app, machine string) error {
 type DeleteMachineRequest struct {
 	App     string
 	Machine string
+	Nonce   string
+	Force   bool
 }
 
 func (m *DeleteMachineRequest) ToRequest(token string) (*http.Request, error) {
-	// TODO: Decide on url to use (vpn vs proxy)
-	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("http://"+flyApiHost+":4280/v1/apps/%s/machines/%s", m.App, m.Machine), nil)
+	url := fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines/%s", m.App, m.Machine)
+
+	// Force kills a running machine instead of requiring it to be stopped first
+	if m.Force {
+		url += "?force=true"
+	}
+
+	req, err := http.NewRequest(http.MethodDelete, url, nil)
 
 	if err != nil {
 		return nil, fmt.Errorf("could not create http request object: %w", err)
@@ -241,16 +374,27 @@ func (m *DeleteMachineRequest) ToRequest(token string) (*http.Request, error) {
 	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
 	req.Header.Set("Accept", "application/json")
 
+	if len(m.Nonce) > 0 {
+		req.Header.Set(leaseNonceHeader, m.Nonce)
+	}
+
 	return req, nil
 }
 
-func DeleteMachine(token, app, machine string) error {
-	req := &DeleteMachineRequest{
-		App:     app,
-		Machine: machine,
-	}
+// DeleteMachine deletes a machine, force deletes it even if it's running
+func DeleteMachine(token, app, machine string, force bool, lease LeaseOptions) error {
+	err := withLease(token, app, machine, lease, func(nonce string) error {
+		req := &DeleteMachineRequest{
+			App:     app,
+			Machine: machine,
+			Nonce:   nonce,
+			Force:   force,
+		}
+
+		_, err := DoRequest(token, req)
 
-	_, err := DoRequest(token, req)
+		return err
+	})
 
 	if err != nil {
 		return fmt.Errorf("request error: %w", err)
@@ -259,31 +403,130 @@ func DeleteMachine(token, app, machine string) error {
 	return nil
 }
 
-func WaitForMachine(token, app, machine string) error {
-	// Total wait time ~5 minutes (should only need a minute or 2)
-	ticker := time.NewTicker(2 * time.Second)
-	totalAttempts := 0
-	attemptsAllowed := 150
-
-	for {
-		select {
-		case <-ticker.C:
-			e, err := GetMachine(token, app, machine)
-			if err != nil {
-				ticker.Stop()
-				return fmt.Errorf("could not get machine: %w", err)
-			}
-
-			if e.IsInitialized() {
-				ticker.Stop()
-				return nil
-			}
-
-			totalAttempts++
-			if totalAttempts >= attemptsAllowed {
-				ticker.Stop()
-				return fmt.Errorf("too many get machine attempts")
-			}
+// DefaultWaitTimeout is how long to wait for a machine, it should only need a minute or 2
+const DefaultWaitTimeout = 5 * time.Minute
+
+// waitBlockSeconds is how long each request to the /wait endpoint blocks for. It's kept short,
+// so the machine's state is checked (and reported) regularly while waiting.
+const waitBlockSeconds = 10
+
+// waitableStates can be waited for with the /wait endpoint
+var waitableStates = []string{"started", "stopped", "destroyed"}
+
+// failedStates are machine states a machine can't be started from
+var failedStates = []string{"destroying", "destroyed", "failed"}
+
+type WaitMachineRequest struct {
+	App        string
+	Machine    string
+	InstanceId string
+	State      string
+	Timeout    int
+}
+
+func (m *WaitMachineRequest) ToRequest(token string) (*http.Request, error) {
+	url := fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/machines/%s/wait?state=%s&timeout=%d", m.App, m.Machine, m.State, m.Timeout)
+
+	if len(m.InstanceId) > 0 {
+		url += "&instance_id=" + m.InstanceId
+	}
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request object: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Accept", "application/json")
+
+	return req, nil
+}
+
+// blockForMachineState blocks until a machine reaches a state, or the request times out.
+// It returns false if the /wait endpoint can't be used, so the caller polls instead.
+func blockForMachineState(ctx context.Context, token string, r *WaitMachineRequest) bool {
+	req, err := r.ToRequest(token)
+
+	if err != nil {
+		return false
+	}
+
+	// The /wait endpoint holds the request open, so DoRequest's short timeout doesn't apply
+	client := &http.Client{
+		Timeout: time.Duration(r.Timeout+5) * time.Second,
+	}
+
+	logger.GetLogger().Debug("caller", "fly.blockForMachineState", "msg", "waiting for machine state", "url", req.URL)
+
+	result, err := client.Do(req.WithContext(ctx))
+
+	if err != nil {
+		// The context ending is handled by the caller
+		return ctx.Err() != nil
+	}
+
+	defer result.Body.Close()
+
+	// A 408 means the state wasn't reached within the timeout, which is fine
+	if result.StatusCode > 299 && result.StatusCode != http.StatusRequestTimeout {
+		body, _ := io.ReadAll(result.Body)
+		logger.GetLogger().Debug("caller", "fly.blockForMachineState", "msg", "wait endpoint unavailable, polling instead", "status", result.StatusCode, "body", string(body))
+
+		return false
+	}
+
+	return true
+}
+
+// WaitForMachine waits for a newly created machine to finish initializing
+func WaitForMachine(ctx context.Context, token, app, machine string, onChange wait.StateFunc) (*Machine, error) {
+	return waitForMachine(ctx, token, app, machine, "started", func(m *Machine) bool {
+		return m.IsInitialized()
+	}, onChange)
+}
+
+// WaitForMachineState waits for a machine to reach the given state, e.g. "started"
+func WaitForMachineState(ctx context.Context, token, app, machine, state string, onChange wait.StateFunc) (*Machine, error) {
+	return waitForMachine(ctx, token, app, machine, state, func(m *Machine) bool {
+		return m.State == state
+	}, onChange)
+}
+
+// waitForMachine waits until the machine is ready, reporting each state it passes through to
+// onChange. The /wait endpoint is used to wait for the given state where possible. Returns a
+// wait.TimeoutError or wait.FailedError, with the last known state, if the machine doesn't get ready.
+func waitForMachine(ctx context.Context, token, app, machine, state string, ready func(m *Machine) bool, onChange wait.StateFunc) (*Machine, error) {
+	var m *Machine
+
+	check := func(ctx context.Context) (string, bool, bool, error) {
+		var err error
+		if m, err = GetMachine(token, app, machine); err != nil {
+			return "", false, false, fmt.Errorf("could not get machine: %w", err)
+		}
+
+		failed := slices.Contains(failedStates, m.State) && !slices.Contains(failedStates, state)
+
+		return m.State, ready(m), failed, nil
+	}
+
+	var block wait.BlockFunc
+	if slices.Contains(waitableStates, state) {
+		block = func(ctx context.Context) bool {
+			return blockForMachineState(ctx, token, &WaitMachineRequest{
+				App:        app,
+				Machine:    machine,
+				InstanceId: m.InstanceId,
+				State:      state,
+				Timeout:    waitBlockSeconds,
+			})
 		}
 	}
+
+	if _, err := wait.Until(ctx, check, block, onChange); err != nil {
+		return m, fmt.Errorf("machine %s not ready: %w", machine, err)
+	}
+
+	return m, nil
 }
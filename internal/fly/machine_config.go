@@ -0,0 +1,108 @@
+package fly
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Machine configs have many more settings than Vessel manages (restart policy, metadata, checks,
+// init, etc). Updating a machine replaces its whole config, so settings without a typed field
+// are kept in Extra when decoding, and sent back as they were when encoding.
+
+// machineConfigFields and guestFields have the typed fields, without the JSON methods below
+type machineConfigFields MachineConfig
+type guestFields Guest
+
+func (c *MachineConfig) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*machineConfigFields)(c)); err != nil {
+		return err
+	}
+
+	extra, err := extraFields(data, "image", "env", "guest", "services", "mounts")
+
+	if err != nil {
+		return err
+	}
+
+	c.Extra = extra
+
+	return nil
+}
+
+func (c MachineConfig) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(machineConfigFields(c))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeExtra(data, c.Extra)
+}
+
+func (g *Guest) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, (*guestFields)(g)); err != nil {
+		return err
+	}
+
+	extra, err := extraFields(data, "cpu_kind", "cpus", "memory_mb")
+
+	if err != nil {
+		return err
+	}
+
+	g.Extra = extra
+
+	return nil
+}
+
+func (g Guest) MarshalJSON() ([]byte, error) {
+	data, err := json.Marshal(guestFields(g))
+
+	if err != nil {
+		return nil, err
+	}
+
+	return mergeExtra(data, g.Extra)
+}
+
+// extraFields returns the fields of a JSON object other than the typed ones
+func extraFields(data []byte, typed ...string) (map[string]json.RawMessage, error) {
+	fields := make(map[string]json.RawMessage)
+
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	for _, k := range typed {
+		delete(fields, k)
+	}
+
+	if len(fields) == 0 {
+		return nil, nil
+	}
+
+	return fields, nil
+}
+
+// mergeExtra adds extra fields to an encoded JSON object. The object's own fields take precedence.
+func mergeExtra(data []byte, extra map[string]json.RawMessage) ([]byte, error) {
+	if len(extra) == 0 {
+		return data, nil
+	}
+
+	fields := make(map[string]json.RawMessage, len(extra))
+	for k, v := range extra {
+		fields[k] = v
+	}
+
+	typed := make(map[string]json.RawMessage)
+	if err := json.Unmarshal(data, &typed); err != nil {
+		return nil, fmt.Errorf("could not decode typed fields: %w", err)
+	}
+
+	for k, v := range typed {
+		fields[k] = v
+	}
+
+	return json.Marshal(fields)
+}
@@ -0,0 +1,266 @@
+package fly
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/umahmood/haversine"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+	"github.com/vessel-app/vessel-cli/internal/util"
+	"gopkg.in/yaml.v3"
+)
+
+// regionCacheTtl is how long the region list is cached in ~/.vessel/regions.yml
+const regionCacheTtl = 24 * time.Hour
+
+// optionalRegionFields may not be known by the API. Fields it reports as undefined are
+// left out of the query, rather than failing to list regions.
+var optionalRegionFields = []string{"deprecated", "capacity"}
+
+func platformRegionsQuery(optionalFields []string) string {
+	fields := append([]string{"code", "name", "latitude", "longitude", "gatewayAvailable", "requiresPaidPlan"}, optionalFields...)
+
+	return fmt.Sprintf("query {\n  platform {\n    regions { %s }\n  }\n}", strings.Join(fields, " "))
+}
+
+type platformRegion struct {
+	Region
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+type PlatformRegions struct {
+	Platform struct {
+		Regions []platformRegion `json:"regions"`
+	} `json:"platform"`
+}
+
+// ListPlatformRegions gets Fly's current regions from the platform API
+func ListPlatformRegions(token string) ([]Region, error) {
+	fields := optionalRegionFields
+
+	for {
+		regions, err := listPlatformRegions(token, platformRegionsQuery(fields))
+
+		// Other errors, such as an invalid token, are returned as is
+		undefined := undefinedFields(err)
+		remaining := make([]string, 0, len(fields))
+		for _, f := range fields {
+			if !slices.Contains(undefined, f) {
+				remaining = append(remaining, f)
+			}
+		}
+
+		if len(undefined) == 0 || len(remaining) == len(fields) {
+			return regions, err
+		}
+
+		logger.GetLogger().Debug("caller", "fly.ListPlatformRegions", "msg", "retrying without undefined fields", "fields", undefined, "error", err)
+		fields = remaining
+	}
+}
+
+// undefinedFields lists the fields a query failed on because the API doesn't know them.
+// Nothing is returned if the query failed for any other reason.
+func undefinedFields(err error) []string {
+	var graphErrs GraphErrors
+	if !errors.As(err, &graphErrs) {
+		return nil
+	}
+
+	fields := make([]string, 0, len(graphErrs))
+	for _, e := range graphErrs {
+		if e.Extensions.Code != "undefinedField" || len(e.Extensions.FieldName) == 0 {
+			return nil
+		}
+
+		fields = append(fields, e.Extensions.FieldName)
+	}
+
+	return fields
+}
+
+func listPlatformRegions(token, query string) ([]Region, error) {
+	p := &PlatformRegions{}
+	if err := DoGraphRequest(token, &GraphRequest{Query: query}, p); err != nil {
+		return nil, err
+	}
+
+	if len(p.Platform.Regions) == 0 {
+		return nil, fmt.Errorf("no regions returned")
+	}
+
+	regions := make([]Region, 0, len(p.Platform.Regions))
+	for _, r := range p.Platform.Regions {
+		region := r.Region
+		region.Location = haversine.Coord{Lat: r.Latitude, Lon: r.Longitude}
+		regions = append(regions, region)
+	}
+
+	return regions, nil
+}
+
+// regionCache is stored in ~/.vessel/regions.yml
+type regionCache struct {
+	FetchedAt time.Time           `yaml:"fetched_at"`
+	Regions   []cachedRegionEntry `yaml:"regions"`
+}
+
+type cachedRegionEntry struct {
+	Code             string  `yaml:"code"`
+	Name             string  `yaml:"name"`
+	Latitude         float64 `yaml:"latitude"`
+	Longitude        float64 `yaml:"longitude"`
+	GatewayAvailable bool    `yaml:"gateway_available"`
+	RequiresPaidPlan bool    `yaml:"requires_paid_plan"`
+	Deprecated       bool    `yaml:"deprecated"`
+	Capacity         *int64  `yaml:"capacity,omitempty"`
+}
+
+// AvailableRegions lists the regions machines can be placed in. The list is fetched from
+// Fly and cached. If it can't be fetched (offline, or without a token), a stale cache or
+// else the built-in Regions is used.
+func AvailableRegions(token string) []Region {
+	cache, err := readRegionCache()
+
+	if err != nil {
+		logger.GetLogger().Debug("caller", "fly.AvailableRegions", "msg", "could not read region cache", "error", err)
+	}
+
+	if cache != nil && time.Since(cache.FetchedAt) < regionCacheTtl {
+		return available(cache.regions())
+	}
+
+	var regions []Region
+	err = fmt.Errorf("no fly api token")
+	if len(token) > 0 {
+		regions, err = ListPlatformRegions(token)
+	}
+
+	if err != nil {
+		logger.GetLogger().Debug("caller", "fly.AvailableRegions", "msg", "could not fetch regions", "error", err)
+
+		if cache != nil {
+			return available(cache.regions())
+		}
+
+		return available(Regions)
+	}
+
+	if err = writeRegionCache(regions); err != nil {
+		logger.GetLogger().Debug("caller", "fly.AvailableRegions", "msg", "could not write region cache", "error", err)
+	}
+
+	return available(regions)
+}
+
+// available filters out regions machines can't be placed in
+func available(regions []Region) []Region {
+	placeable := make([]Region, 0, len(regions))
+	for _, r := range regions {
+		if r.Placeable() {
+			placeable = append(placeable, r)
+		}
+	}
+
+	return placeable
+}
+
+func (c *regionCache) regions() []Region {
+	regions := make([]Region, 0, len(c.Regions))
+	for _, r := range c.Regions {
+		regions = append(regions, Region{
+			Code:             r.Code,
+			Name:             r.Name,
+			GatewayAvailable: r.GatewayAvailable,
+			RequiresPaidPlan: r.RequiresPaidPlan,
+			Deprecated:       r.Deprecated,
+			Capacity:         r.Capacity,
+			Location:         haversine.Coord{Lat: r.Latitude, Lon: r.Longitude},
+		})
+	}
+
+	return regions
+}
+
+func regionCachePath() (string, error) {
+	vesselDir, err := util.MakeStorageDir()
+
+	if err != nil {
+		return "", fmt.Errorf("could not find vessel directory: %w", err)
+	}
+
+	return filepath.FromSlash(vesselDir + "/regions.yml"), nil
+}
+
+// readRegionCache returns nil if there is no cache yet
+func readRegionCache() (*regionCache, error) {
+	cachePath, err := regionCachePath()
+
+	if err != nil {
+		return nil, err
+	}
+
+	file, err := os.ReadFile(cachePath)
+
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read yaml file '%s': %w", cachePath, err)
+	}
+
+	cache := &regionCache{}
+	if err = yaml.Unmarshal(file, cache); err != nil {
+		return nil, fmt.Errorf("error parsing yaml file %s: %w", cachePath, err)
+	}
+
+	if len(cache.Regions) == 0 {
+		return nil, nil
+	}
+
+	return cache, nil
+}
+
+func writeRegionCache(regions []Region) error {
+	cachePath, err := regionCachePath()
+
+	if err != nil {
+		return err
+	}
+
+	cache := &regionCache{
+		FetchedAt: time.Now(),
+	}
+
+	for _, r := range regions {
+		cache.Regions = append(cache.Regions, cachedRegionEntry{
+			Code:             r.Code,
+			Name:             r.Name,
+			Latitude:         r.Location.Lat,
+			Longitude:        r.Location.Lon,
+			GatewayAvailable: r.GatewayAvailable,
+			RequiresPaidPlan: r.RequiresPaidPlan,
+			Deprecated:       r.Deprecated,
+			Capacity:         r.Capacity,
+		})
+	}
+
+	data, err := yaml.Marshal(cache)
+
+	if err != nil {
+		return fmt.Errorf("could not marshal region cache: %w", err)
+	}
+
+	if err = os.WriteFile(cachePath, data, 0644); err != nil {
+		return fmt.Errorf("could not write region cache file '%s': %w", cachePath, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,140 @@
+package fly
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// regionsServer fakes the platform regions query, rejecting fields it doesn't know
+func regionsServer(t *testing.T, undefined []string, status int, errorCode string) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	var queries []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := &GraphRequest{}
+		if err := json.NewDecoder(r.Body).Decode(req); err != nil {
+			t.Errorf("could not decode request: %v", err)
+		}
+
+		mu.Lock()
+		queries = append(queries, req.Query)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+
+		if len(errorCode) > 0 {
+			w.WriteHeader(status)
+			_, _ = w.Write([]byte(`{"data":null,"errors":[{"message":"denied","extensions":{"code":"` + errorCode + `"}}]}`))
+			return
+		}
+
+		errs := make([]map[string]interface{}, 0)
+		for _, f := range undefined {
+			if strings.Contains(req.Query, " "+f) {
+				errs = append(errs, map[string]interface{}{
+					"message":    "Field '" + f + "' doesn't exist on type 'Region'",
+					"extensions": map[string]string{"code": "undefinedField", "typeName": "Region", "fieldName": f},
+				})
+			}
+		}
+
+		if len(errs) > 0 {
+			_ = json.NewEncoder(w).Encode(map[string]interface{}{"errors": errs})
+			return
+		}
+
+		regions := []map[string]interface{}{
+			{"code": "iad", "name": "Ashburn, Virginia (US)", "deprecated": false, "capacity": 100},
+			{"code": "mad", "name": "Madrid, Spain", "deprecated": false, "capacity": 0},
+			{"code": "sea", "name": "Seattle, Washington (US)", "deprecated": true, "capacity": 100},
+		}
+
+		// Only the fields asked for are returned
+		for _, region := range regions {
+			for field := range region {
+				if !strings.Contains(req.Query, " "+field) {
+					delete(region, field)
+				}
+			}
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"data": map[string]interface{}{"platform": map[string]interface{}{"regions": regions}},
+		})
+	}))
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return append([]string{}, queries...)
+	}
+}
+
+func TestListPlatformRegionsFiltersUnplaceableRegions(t *testing.T) {
+	server, _ := regionsServer(t, nil, 0, "")
+	defer server.Close()
+
+	useApiUrls(t, ApiUrls{GraphQL: server.URL})
+
+	regions, err := ListPlatformRegions("token")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	placeable := available(regions)
+
+	if len(placeable) != 1 || placeable[0].Code != "iad" {
+		t.Errorf("expected only iad to be placeable, got %v", placeable)
+	}
+}
+
+func TestListPlatformRegionsDropsUndefinedFields(t *testing.T) {
+	server, queries := regionsServer(t, []string{"capacity"}, 0, "")
+	defer server.Close()
+
+	useApiUrls(t, ApiUrls{GraphQL: server.URL})
+
+	regions, err := ListPlatformRegions("token")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if q := queries(); len(q) != 2 || strings.Contains(q[1], "capacity") || !strings.Contains(q[1], "deprecated") {
+		t.Errorf("expected a retry without only the capacity field, got %v", q)
+	}
+
+	// Without capacity, only the deprecated region is hidden
+	placeable := available(regions)
+
+	if len(placeable) != 2 {
+		t.Errorf("expected 2 placeable regions, got %v", placeable)
+	}
+}
+
+func TestListPlatformRegionsReturnsOtherErrors(t *testing.T) {
+	server, queries := regionsServer(t, nil, http.StatusUnauthorized, "UNAUTHORIZED")
+	defer server.Close()
+
+	useApiUrls(t, ApiUrls{GraphQL: server.URL})
+
+	_, err := ListPlatformRegions("token")
+
+	var graphErrs GraphErrors
+	if !errors.As(err, &graphErrs) || !graphErrs.HasCode("UNAUTHORIZED") {
+		t.Errorf("expected the auth error to be returned, got %v", err)
+	}
+
+	if q := queries(); len(q) != 1 {
+		t.Errorf("expected no retry after an auth error, got %v", q)
+	}
+}
@@ -0,0 +1,145 @@
+package fly
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// regionProbeUrl is an app running in Fly's regions. Requests are routed to each
+// region in turn with the Fly-Prefer-Region header, and the region that answered is
+// read from the Fly-Region header. Set FLY_PROBE_URL to use another app.
+//
+// The app isn't run by Vessel, and may not be deployed in every region. Regions it
+// doesn't answer from have an unknown latency, rather than being unreachable.
+var regionProbeUrl = "https://debug.fly.dev"
+
+// ErrLatencyUnknown means a region's latency couldn't be measured, as the probe app
+// answered from another region. The region itself may well be usable.
+var ErrLatencyUnknown = errors.New("latency is unknown")
+
+// probeAttempts are made per region, keeping the fastest. The first attempt
+// includes connecting, later attempts reuse the connection.
+const probeAttempts = 3
+
+func init() {
+	if u := os.Getenv("FLY_PROBE_URL"); len(u) > 0 {
+		regionProbeUrl = u
+	}
+}
+
+// RegionLatency is the measured round trip time to a region
+type RegionLatency struct {
+	Region  Region
+	Latency time.Duration
+	Error   error
+}
+
+// ProbeRegions measures the round trip time to each region concurrently. Results are
+// ranked fastest first, followed by regions of unknown latency, with regions that
+// couldn't be reached last.
+func ProbeRegions(ctx context.Context, regions []Region) []RegionLatency {
+	results := make([]RegionLatency, len(regions))
+
+	var wg sync.WaitGroup
+	for i := range regions {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			latency, err := probeRegion(ctx, regions[i].Code)
+			results[i] = RegionLatency{
+				Region:  regions[i],
+				Latency: latency,
+				Error:   err,
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	sort.SliceStable(results, func(i, j int) bool {
+		if rank(results[i]) != rank(results[j]) {
+			return rank(results[i]) < rank(results[j])
+		}
+
+		return results[i].Latency < results[j].Latency
+	})
+
+	return results
+}
+
+// rank orders measured regions before those of unknown latency, and unreachable regions last
+func rank(r RegionLatency) int {
+	switch {
+	case r.Error == nil:
+		return 0
+	case errors.Is(r.Error, ErrLatencyUnknown):
+		return 1
+	default:
+		return 2
+	}
+}
+
+// probeRegion returns the fastest of several HTTP round trips to a region
+func probeRegion(ctx context.Context, region string) (time.Duration, error) {
+	// Each region gets its own connection, so connections are reused by attempts to the same region
+	client := &http.Client{
+		Timeout:   5 * time.Second,
+		Transport: &http.Transport{},
+	}
+	defer client.CloseIdleConnections()
+
+	var fastest time.Duration
+	var err error
+	// answeredBy is set if the probe app answered from another region
+	var answeredBy string
+	for attempt := 0; attempt < probeAttempts; attempt++ {
+		var req *http.Request
+		req, err = http.NewRequestWithContext(ctx, http.MethodHead, regionProbeUrl, nil)
+
+		if err != nil {
+			return 0, fmt.Errorf("could not create http request object: %w", err)
+		}
+
+		req.Header.Set("Fly-Prefer-Region", region)
+
+		start := time.Now()
+		var result *http.Response
+		result, err = client.Do(req)
+
+		if err != nil {
+			continue
+		}
+
+		elapsed := time.Since(start)
+		_, _ = io.Copy(io.Discard, result.Body)
+		result.Body.Close()
+
+		// Fly routes the request elsewhere if the app can't be reached in the preferred
+		// region, in which case we'd be timing another region
+		if answered := result.Header.Get("Fly-Region"); answered != region {
+			answeredBy = answered
+			continue
+		}
+
+		if fastest == 0 || elapsed < fastest {
+			fastest = elapsed
+		}
+	}
+
+	if fastest == 0 && len(answeredBy) > 0 {
+		return 0, fmt.Errorf("%w, requests to %s were answered by region '%s'", ErrLatencyUnknown, region, answeredBy)
+	}
+
+	if fastest == 0 {
+		return 0, fmt.Errorf("could not reach region %s: %w", region, err)
+	}
+
+	return fastest, nil
+}
@@ -0,0 +1,42 @@
+package fly
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProbeRegionsDoesNotTimeOtherRegions(t *testing.T) {
+	// The probe app only runs in iad, so requests preferring other regions are answered there
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// syd can't be reached at all
+		if r.Header.Get("Fly-Prefer-Region") == "syd" {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+
+		w.Header().Set("Fly-Region", "iad")
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	useApiUrls(t, ApiUrls{Probe: server.URL})
+
+	results := ProbeRegions(context.Background(), []Region{{Code: "syd"}, {Code: "mad"}, {Code: "iad"}})
+
+	if results[0].Region.Code != "iad" || results[0].Error != nil {
+		t.Errorf("expected iad to be ranked first, got %+v", results[0])
+	}
+
+	// Requests preferring mad were timed in iad, so mad's latency is unknown, but it isn't excluded
+	if results[1].Region.Code != "mad" || !errors.Is(results[1].Error, ErrLatencyUnknown) {
+		t.Errorf("expected mad's latency to be unknown, got %+v", results[1])
+	}
+
+	if results[2].Region.Code != "syd" || results[2].Error == nil || errors.Is(results[2].Error, ErrLatencyUnknown) {
+		t.Errorf("expected syd to be unreachable, got %+v", results[2])
+	}
+}
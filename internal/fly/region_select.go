@@ -1,17 +1,17 @@
 package fly
 
 import (
-	"fmt"
 	"github.com/umahmood/haversine"
 )
 
-// Distance calculates the distance between region and a given point
+// Distance calculates the distance (in miles) between region and a given point
 func (r *Region) Distance(p haversine.Coord) float64 {
-	mi, km := haversine.Distance(p, r.Location)
-	fmt.Printf("Miles: %f, Kilometers: %f", mi, km)
+	mi, _ := haversine.Distance(p, r.Location)
 	return mi
 }
 
+// Regions are Fly's regions. Locations use negative latitudes south of the
+// equator, and negative longitudes west of Greenwich.
 var Regions = []Region{
 	{
 		Code: "ams",
@@ -36,7 +36,7 @@ var Regions = []Region{
 		Name: "Dallas, Texas (US)",
 		Location: haversine.Coord{
 			Lat: 32.7767,
-			Lon: 96.7970,
+			Lon: -96.7970,
 		},
 	},
 
@@ -45,7 +45,7 @@ var Regions = []Region{
 		Name: "Secaucus, NJ (US)",
 		Location: haversine.Coord{
 			Lat: 40.7895,
-			Lon: 74.0565,
+			Lon: -74.0565,
 		},
 	},
 
@@ -62,8 +62,8 @@ var Regions = []Region{
 		Code: "gru",
 		Name: "São Paulo",
 		Location: haversine.Coord{
-			Lat: 23.5558,
-			Lon: 46.6396,
+			Lat: -23.5558,
+			Lon: -46.6396,
 		},
 	},
 
@@ -81,7 +81,7 @@ var Regions = []Region{
 		Name: "Ashburn, Virginia (US)",
 		Location: haversine.Coord{
 			Lat: 39.0438,
-			Lon: 77.4874,
+			Lon: -77.4874,
 		},
 	},
 
@@ -90,7 +90,7 @@ var Regions = []Region{
 		Name: "Los Angeles, California (US)",
 		Location: haversine.Coord{
 			Lat: 34.0522,
-			Lon: 118.2437,
+			Lon: -118.2437,
 		},
 	},
 
@@ -99,7 +99,7 @@ var Regions = []Region{
 		Name: "London, United Kingdom",
 		Location: haversine.Coord{
 			Lat: 51.5072,
-			Lon: 0.1276,
+			Lon: -0.1276,
 		},
 	},
 
@@ -117,7 +117,7 @@ var Regions = []Region{
 		Name: "Madrid, Spain",
 		Location: haversine.Coord{
 			Lat: 40.4168,
-			Lon: 3.7038,
+			Lon: -3.7038,
 		},
 	},
 
@@ -126,7 +126,7 @@ var Regions = []Region{
 		Name: "Miami, Florida (US)",
 		Location: haversine.Coord{
 			Lat: 25.7617,
-			Lon: 80.1918,
+			Lon: -80.1918,
 		},
 	},
 
@@ -144,7 +144,7 @@ var Regions = []Region{
 		Name: "Chicago, Illinois (US)",
 		Location: haversine.Coord{
 			Lat: 41.8781,
-			Lon: 87.6298,
+			Lon: -87.6298,
 		},
 	},
 
@@ -152,8 +152,8 @@ var Regions = []Region{
 		Code: "scl",
 		Name: "Santiago, Chile",
 		Location: haversine.Coord{
-			Lat: 33.4489,
-			Lon: 70.6693,
+			Lat: -33.4489,
+			Lon: -70.6693,
 		},
 	},
 
@@ -162,7 +162,7 @@ var Regions = []Region{
 		Name: "Seattle, Washington (US)",
 		Location: haversine.Coord{
 			Lat: 47.6062,
-			Lon: 122.3321,
+			Lon: -122.3321,
 		},
 	},
 
@@ -180,7 +180,7 @@ var Regions = []Region{
 		Name: "Sunnyvale, California (US)",
 		Location: haversine.Coord{
 			Lat: 37.3688,
-			Lon: 122.0363,
+			Lon: -122.0363,
 		},
 	},
 
@@ -188,7 +188,7 @@ var Regions = []Region{
 		Code: "syd",
 		Name: "Sydney, Australia",
 		Location: haversine.Coord{
-			Lat: 33.8688,
+			Lat: -33.8688,
 			Lon: 151.2093,
 		},
 	},
@@ -198,7 +198,7 @@ var Regions = []Region{
 		Name: "Montreal, Canada",
 		Location: haversine.Coord{
 			Lat: 45.5017,
-			Lon: 73.5673,
+			Lon: -73.5673,
 		},
 	},
 
@@ -207,7 +207,7 @@ var Regions = []Region{
 		Name: "Toronto, Canada",
 		Location: haversine.Coord{
 			Lat: 43.6532,
-			Lon: 79.3832,
+			Lon: -79.3832,
 		},
 	},
 }
@@ -0,0 +1,96 @@
+package fly
+
+// Secret values are never returned by the API, only a digest of the value.
+// Never log the request body of secret requests, as it contains the values.
+
+/*****************
+ * SET SECRETS
+****************/
+
+type SecretInput struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+type SetSecretsInput struct {
+	AppId   string        `json:"appId"`
+	Secrets []SecretInput `json:"secrets"`
+}
+
+type setSecretsVariables struct {
+	Input SetSecretsInput `json:"input"`
+}
+
+func SetSecrets(token, app string, secrets map[string]string) error {
+	input := SetSecretsInput{
+		AppId:   app,
+		Secrets: make([]SecretInput, 0, len(secrets)),
+	}
+
+	for k, v := range secrets {
+		input.Secrets = append(input.Secrets, SecretInput{
+			Key:   k,
+			Value: v,
+		})
+	}
+
+	req := &GraphRequest{
+		Query:     "mutation($input: SetSecretsInput!) { setSecrets(input: $input) { release { id version } } }",
+		Variables: &setSecretsVariables{Input: input},
+	}
+
+	return DoGraphRequest(token, req, nil)
+}
+
+/*****************
+ * UNSET SECRETS
+****************/
+
+type UnsetSecretsInput struct {
+	AppId string   `json:"appId"`
+	Keys  []string `json:"keys"`
+}
+
+type unsetSecretsVariables struct {
+	Input UnsetSecretsInput `json:"input"`
+}
+
+func UnsetSecrets(token, app string, keys []string) error {
+	req := &GraphRequest{
+		Query: "mutation($input: UnsetSecretsInput!) { unsetSecrets(input: $input) { release { id version } } }",
+		Variables: &unsetSecretsVariables{
+			Input: UnsetSecretsInput{
+				AppId: app,
+				Keys:  keys,
+			},
+		},
+	}
+
+	return DoGraphRequest(token, req, nil)
+}
+
+/*****************
+ * LIST SECRETS
+****************/
+
+type ListSecretsResponse struct {
+	App struct {
+		Secrets []Secret `json:"secrets"`
+	} `json:"app"`
+}
+
+func ListSecrets(token, app string) ([]Secret, error) {
+	req := &GraphRequest{
+		Query: "query($appName: String!) { app(name: $appName) { secrets { name digest createdAt } } }",
+		Variables: &appNameVariables{
+			AppName: app,
+		},
+	}
+
+	s := &ListSecretsResponse{}
+	if err := DoGraphRequest(token, req, s); err != nil {
+		return nil, err
+	}
+
+	return s.App.Secrets, nil
+}
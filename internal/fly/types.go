@@ -1,6 +1,9 @@
 package fly
 
 import (
+	"encoding/json"
+	"time"
+
 	"github.com/umahmood/haversine"
 	"golang.org/x/exp/slices"
 )
@@ -20,11 +23,115 @@ type App struct {
 ****************/
 
 type Machine struct {
-	Id     string `json:"id"`
-	Name   string `json:"name"`
-	State  string `json:"state"`
-	Region string `json:"regions"`
-	Image  string `json:"image"`
+	Id         string         `json:"id"`
+	Name       string         `json:"name"`
+	State      string         `json:"state"`
+	Region     string         `json:"region"`
+	InstanceId string         `json:"instance_id"`
+	PrivateIp  string         `json:"private_ip"`
+	Config     MachineConfig  `json:"config"`
+	ImageRef   ImageRef       `json:"image_ref"`
+	CreatedAt  string         `json:"created_at"`
+	UpdatedAt  string         `json:"updated_at"`
+	Events     []MachineEvent `json:"events"`
+}
+
+// MachineConfig is the desired configuration of a machine, sent when
+// creating it and returned by the API as the machine's current config
+type MachineConfig struct {
+	Image    string            `json:"image"`
+	Env      map[string]string `json:"env,omitempty"`
+	Guest    *Guest            `json:"guest,omitempty"`
+	Services []Service         `json:"services,omitempty"`
+	Mounts   []Mount           `json:"mounts,omitempty"`
+	// Extra holds the settings Vessel doesn't manage, so they're kept when the machine is updated
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Guest is the CPU and memory sizing of a machine
+type Guest struct {
+	CpuKind  string `json:"cpu_kind,omitempty"`
+	Cpus     int    `json:"cpus,omitempty"`
+	MemoryMb int    `json:"memory_mb,omitempty"`
+	// Extra holds settings such as kernel_args, kept when the size is changed
+	Extra map[string]json.RawMessage `json:"-"`
+}
+
+// Service exposes a port within the machine through Fly's proxy
+type Service struct {
+	Protocol     string `json:"protocol"`
+	InternalPort int    `json:"internal_port"`
+	Ports        []Port `json:"ports"`
+}
+
+// Port is a public port of a Service, and the handlers (tls, http, etc)
+// Fly's proxy applies to connections on it
+type Port struct {
+	Port     int      `json:"port"`
+	Handlers []string `json:"handlers,omitempty"`
+}
+
+// ImageRef is the resolved image a machine is running
+type ImageRef struct {
+	Registry   string `json:"registry"`
+	Repository string `json:"repository"`
+	Tag        string `json:"tag"`
+	Digest     string `json:"digest"`
+}
+
+// MachineEvent is an entry in the lifecycle history of a machine. Source is
+// who triggered the event, e.g. "user" for API calls or "flyd" for Fly itself.
+type MachineEvent struct {
+	Id        string `json:"id"`
+	Type      string `json:"type"`
+	Status    string `json:"status"`
+	Source    string `json:"source"`
+	Timestamp int64  `json:"timestamp"`
+	// Request holds event details, which differ per event type
+	Request json.RawMessage `json:"request,omitempty"`
+}
+
+// ExitEvent describes how a machine's process exited
+type ExitEvent struct {
+	ExitCode      int    `json:"exit_code"`
+	GuestExitCode int    `json:"guest_exit_code"`
+	Signal        int    `json:"signal"`
+	GuestSignal   int    `json:"guest_signal"`
+	OomKilled     bool   `json:"oom_killed"`
+	RequestedStop bool   `json:"requested_stop"`
+	Restarting    bool   `json:"restarting"`
+	ExitedAt      string `json:"exited_at"`
+}
+
+type machineEventRequest struct {
+	ExitEvent    *ExitEvent `json:"exit_event"`
+	RestartCount int        `json:"restart_count"`
+}
+
+// Time is when the event happened. Timestamps are in milliseconds.
+func (e *MachineEvent) Time() time.Time {
+	return time.UnixMilli(e.Timestamp)
+}
+
+// Exit returns the exit details of "exit" events, and nil for other events
+func (e *MachineEvent) Exit() *ExitEvent {
+	return e.request().ExitEvent
+}
+
+// RestartCount is how many times the machine has been restarted after exiting
+func (e *MachineEvent) RestartCount() int {
+	return e.request().RestartCount
+}
+
+func (e *MachineEvent) request() *machineEventRequest {
+	r := &machineEventRequest{}
+
+	// Details of other event types don't fit, and are ignored
+	if len(e.Request) > 0 {
+		_ = json.Unmarshal(e.Request, r)
+	}
+
+	return r
 }
 
 func (m *Machine) IsInitialized() bool {
@@ -33,6 +140,35 @@ func (m *Machine) IsInitialized() bool {
 	return slices.Contains(initValues, m.State)
 }
 
+/*****************
+ * VOLUME
+****************/
+
+type Volume struct {
+	Id              string `json:"id"`
+	Name            string `json:"name"`
+	State           string `json:"state"`
+	SizeGb          int    `json:"size_gb"`
+	Region          string `json:"region"`
+	AttachedMachine string `json:"attached_machine_id"`
+}
+
+// Mount attaches a volume to a path within a machine
+type Mount struct {
+	Volume string `json:"volume"`
+	Path   string `json:"path"`
+}
+
+/*****************
+ * SECRET
+****************/
+
+type Secret struct {
+	Name      string `json:"name"`
+	Digest    string `json:"digest"`
+	CreatedAt string `json:"createdAt"`
+}
+
 /*****************
  * IP ADDRESSES
 ****************/
@@ -63,8 +199,18 @@ type Region struct {
 	Code             string `json:"code"`
 	Name             string `json:"name"`
 	GatewayAvailable bool   `json:"gatewayAvailable"`
-	Location         haversine.Coord
-	UserDistance     float64
+	RequiresPaidPlan bool   `json:"requiresPaidPlan"`
+	// Deprecated regions can't have new machines placed in them
+	Deprecated bool `json:"deprecated"`
+	// Capacity is how many more machines the region can take, if the API reports it
+	Capacity     *int64 `json:"capacity"`
+	Location     haversine.Coord
+	UserDistance float64
+}
+
+// Placeable reports whether new machines can be placed in the region
+func (r Region) Placeable() bool {
+	return !r.Deprecated && (r.Capacity == nil || *r.Capacity > 0)
 }
 
 /*****************
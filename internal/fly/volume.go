@@ -0,0 +1,148 @@
+package fly
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+/*****************
+ * CREATE VOLUME
+****************/
+
+type CreateVolumeRequest struct {
+	App    string `json:"-"`
+	Name   string `json:"name"`
+	Region string `json:"region"`
+	SizeGb int    `json:"size_gb"`
+}
+
+func (r *CreateVolumeRequest) ToRequest(token string) (*http.Request, error) {
+	data, err := json.Marshal(r)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not marshal volume request: %w", err)
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/volumes", r.App), bytes.NewBuffer(data))
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request object: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Accept", "application/json")
+
+	return req, nil
+}
+
+// CreateVolume creates a volume. Volumes must be in the
+// same region as the machine they are mounted into.
+func CreateVolume(token, app, name, region string, sizeGb int) (*Volume, error) {
+	req := &CreateVolumeRequest{
+		App:    app,
+		Name:   name,
+		Region: region,
+		SizeGb: sizeGb,
+	}
+
+	responseBody, err := DoRequest(token, req)
+
+	if err != nil {
+		return nil, fmt.Errorf("request error: %w", err)
+	}
+
+	v := &Volume{}
+	err = json.Unmarshal(responseBody, v)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshall json: %w", err)
+	}
+
+	return v, nil
+}
+
+/*****************
+ * LIST VOLUMES
+****************/
+
+type ListVolumesRequest struct {
+	App string
+}
+
+func (r *ListVolumesRequest) ToRequest(token string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/volumes", r.App), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request object: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Accept", "application/json")
+
+	return req, nil
+}
+
+func ListVolumes(token, app string) ([]Volume, error) {
+	req := &ListVolumesRequest{
+		App: app,
+	}
+
+	responseBody, err := DoRequest(token, req)
+
+	if err != nil {
+		return nil, fmt.Errorf("request error: %w", err)
+	}
+
+	v := make([]Volume, 0)
+	err = json.Unmarshal(responseBody, &v)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not unmarshall json: %w", err)
+	}
+
+	return v, nil
+}
+
+/*****************
+ * DELETE VOLUME
+****************/
+
+type DeleteVolumeRequest struct {
+	App    string
+	Volume string
+}
+
+func (r *DeleteVolumeRequest) ToRequest(token string) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf(machinesApiUrl+"/v1/apps/%s/volumes/%s", r.App, r.Volume), nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create http request object: %w", err)
+	}
+
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Content-Type", "application/json; charset=UTF-8")
+	req.Header.Set("Accept", "application/json")
+
+	return req, nil
+}
+
+// DeleteVolume deletes a volume. The volume cannot
+// be attached to a machine when it is deleted.
+func DeleteVolume(token, app, volume string) error {
+	req := &DeleteVolumeRequest{
+		App:    app,
+		Volume: volume,
+	}
+
+	_, err := DoRequest(token, req)
+
+	if err != nil {
+		return fmt.Errorf("request error: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,132 @@
+package fly
+
+import (
+	"fmt"
+)
+
+/*****************
+ * ORGANIZATION ID
+****************/
+
+type organizationResponse struct {
+	Organization Organization `json:"organization"`
+}
+
+type organizationVariables struct {
+	Slug string `json:"slug"`
+}
+
+// GetOrganizationId finds the ID of an organization, as mutations take IDs rather than slugs
+func GetOrganizationId(token, org string) (string, error) {
+	req := &GraphRequest{
+		Query: "query ($slug: String!) { organization(slug: $slug) { id slug name } }",
+		Variables: &organizationVariables{
+			Slug: org,
+		},
+	}
+
+	o := &organizationResponse{}
+	if err := DoGraphRequest(token, req, o); err != nil {
+		return "", err
+	}
+
+	if len(o.Organization.Id) == 0 {
+		return "", fmt.Errorf("organization not found: %s", org)
+	}
+
+	return o.Organization.Id, nil
+}
+
+/*****************
+ * ADD WIREGUARD PEER
+****************/
+
+// WireGuardPeer is a WireGuard peer within an organization's private network (6PN)
+type WireGuardPeer struct {
+	// PeerIp is our address within the private network
+	PeerIp string `json:"peerip"`
+	// EndpointIp is the gateway's public address, to connect to
+	EndpointIp string `json:"endpointip"`
+	// PublicKey is the gateway's public key
+	PublicKey string `json:"pubkey"`
+}
+
+type addWireGuardPeerResponse struct {
+	Peer WireGuardPeer `json:"addWireGuardPeer"`
+}
+
+type AddWireGuardPeerInput struct {
+	OrganizationId string `json:"organizationId"`
+	Region         string `json:"region"`
+	Name           string `json:"name"`
+	PublicKey      string `json:"pubkey"`
+}
+
+type addWireGuardPeerVariables struct {
+	Input AddWireGuardPeerInput `json:"input"`
+}
+
+// AddWireGuardPeer adds a peer with the given (base64) public key, using the WireGuard gateway of a region
+func AddWireGuardPeer(token, org, region, name, publicKey string) (*WireGuardPeer, error) {
+	orgId, err := GetOrganizationId(token, org)
+
+	if err != nil {
+		return nil, err
+	}
+
+	req := &GraphRequest{
+		Query: "mutation($input: AddWireGuardPeerInput!) { addWireGuardPeer(input: $input) { peerip endpointip pubkey } }",
+		Variables: &addWireGuardPeerVariables{
+			Input: AddWireGuardPeerInput{
+				OrganizationId: orgId,
+				Region:         region,
+				Name:           name,
+				PublicKey:      publicKey,
+			},
+		},
+	}
+
+	p := &addWireGuardPeerResponse{}
+	if err := DoGraphRequest(token, req, p); err != nil {
+		return nil, err
+	}
+
+	if len(p.Peer.PeerIp) == 0 {
+		return nil, fmt.Errorf("no address was given to wireguard peer: %s", name)
+	}
+
+	return &p.Peer, nil
+}
+
+/*****************
+ * REMOVE WIREGUARD PEER
+****************/
+
+type RemoveWireGuardPeerInput struct {
+	OrganizationId string `json:"organizationId"`
+	Name           string `json:"name"`
+}
+
+type removeWireGuardPeerVariables struct {
+	Input RemoveWireGuardPeerInput `json:"input"`
+}
+
+func RemoveWireGuardPeer(token, org, name string) error {
+	orgId, err := GetOrganizationId(token, org)
+
+	if err != nil {
+		return err
+	}
+
+	req := &GraphRequest{
+		Query: "mutation($input: RemoveWireGuardPeerInput!) { removeWireGuardPeer(input: $input) { organization { id } } }",
+		Variables: &removeWireGuardPeerVariables{
+			Input: RemoveWireGuardPeerInput{
+				OrganizationId: orgId,
+				Name:           name,
+			},
+		},
+	}
+
+	return DoGraphRequest(token, req, nil)
+}
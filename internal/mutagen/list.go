@@ -0,0 +1,44 @@
+package mutagen
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ListSessions finds the syncing and forwarding sessions started for the given session name
+func ListSessions(name string) ([]SyncSession, []ForwardSession, error) {
+	exe, err := GetMutagenCommandPath()
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("unable to determine mutagen path: %w", err)
+	}
+
+	syncs, err := findSyncSessions(exe)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not list sync sessions: %w", err)
+	}
+
+	forwards, err := findForwardSessions(exe)
+
+	if err != nil {
+		return nil, nil, fmt.Errorf("could not list forward sessions: %w", err)
+	}
+
+	matchingSyncs := make([]SyncSession, 0)
+	for _, s := range syncs {
+		if s.Name == name {
+			matchingSyncs = append(matchingSyncs, s)
+		}
+	}
+
+	// Forwarding sessions are named "<name>-<index>"
+	matchingForwards := make([]ForwardSession, 0)
+	for _, f := range forwards {
+		if strings.HasPrefix(f.Name, fmt.Sprintf("%s-", name)) {
+			matchingForwards = append(matchingForwards, f)
+		}
+	}
+
+	return matchingSyncs, matchingForwards, nil
+}
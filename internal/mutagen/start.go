@@ -6,7 +6,9 @@ import (
 	"strings"
 )
 
-func StartSession(name, localDir string, cfg *config.EnvironmentConfig) error {
+// StartSession syncs files and forwards ports to the dev environment. Ports forwarded to
+// sidecars go through the dev environment, to the sidecar hosts given by sidecar name.
+func StartSession(name, localDir string, cfg *config.EnvironmentConfig, sidecarHosts map[string]string) error {
 	_, err := Sync(name, cfg.Remote.Alias, localDir, cfg.Remote.RemotePath, cfg.Ignore)
 
 	if err != nil {
@@ -28,5 +30,28 @@ func StartSession(name, localDir string, cfg *config.EnvironmentConfig) error {
 		}
 	}
 
+	for _, sidecar := range cfg.Sidecars {
+		host, ok := sidecarHosts[sidecar.Name]
+
+		if !ok {
+			return fmt.Errorf("sidecar '%s' has not been created, run `vessel apply` first", sidecar.Name)
+		}
+
+		for k, p := range sidecar.Forwarding {
+			ports := strings.Split(p, ":")
+
+			if len(ports) != 2 {
+				return fmt.Errorf("invalid forwarding configuration found for sidecar %s in vessel.yml file: %s", sidecar.Name, p)
+			}
+
+			// Mutagen session names can't contain underscores
+			_, err = Forward(fmt.Sprintf("%s-%s-%d", name, strings.ReplaceAll(sidecar.Name, "_", "-"), k), "tcp:127.0.0.1:"+ports[0], cfg.Remote.Alias, "tcp:"+host+":"+ports[1])
+
+			if err != nil {
+				return fmt.Errorf("error forwarding port config `%s` for sidecar %s: %w", p, sidecar.Name, err)
+			}
+		}
+	}
+
 	return nil
 }
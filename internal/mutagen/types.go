@@ -22,6 +22,13 @@ type SyncSession struct {
 	Labels map[string]string `json:"labels,omitempty"`
 	// Paused indicates whether or not the session is paused.
 	Paused bool `json:"paused"`
+	// Status is the current synchronization status, e.g. "watching".
+	Status string `json:"status,omitempty"`
+}
+
+// IsConnected determines if both ends of the sync session are connected
+func (s *SyncSession) IsConnected() bool {
+	return s.Alpha.Connected && s.Beta.Connected
 }
 
 // ForwardSession represents the JSON sync session we get when calling `mutagen sync list`
@@ -46,6 +53,13 @@ type ForwardSession struct {
 	Labels map[string]string `json:"labels,omitempty"`
 	// Paused indicates whether or not the session is paused.
 	Paused bool `json:"paused"`
+	// Status is the current forwarding status, e.g. "forwarding".
+	Status string `json:"status,omitempty"`
+}
+
+// IsConnected determines if both ends of the forward session are connected
+func (s *ForwardSession) IsConnected() bool {
+	return s.Source.Connected && s.Destination.Connected
 }
 
 // Endpoint represents a synchronization endpoint.
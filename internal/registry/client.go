@@ -0,0 +1,381 @@
+package registry
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/vessel-app/vessel-cli/internal/logger"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to a registry over the registry HTTP API (v2). It authenticates with
+// basic auth, or with a bearer token retrieved using basic auth, as the registry asks.
+type Client struct {
+	BaseUrl  string
+	Username string
+	Password string
+
+	http      *http.Client
+	token     string
+	basicAuth bool
+}
+
+func NewClient(baseUrl, username, password string) *Client {
+	return &Client{
+		BaseUrl:  strings.TrimRight(baseUrl, "/"),
+		Username: username,
+		Password: password,
+		http: &http.Client{
+			// Layers can be large, so this only limits waiting on a stuck registry
+			Timeout: 30 * time.Minute,
+		},
+	}
+}
+
+// Ping checks that the registry can be reached and that the credentials are accepted
+func (c *Client) Ping() error {
+	resp, err := c.do(http.MethodGet, "/v2/", nil, nil, 0)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+// BlobExists determines if the registry already has a blob, so it need not be uploaded
+func (c *Client) BlobExists(repository, digest string) (bool, error) {
+	resp, err := c.do(http.MethodHead, fmt.Sprintf("/v2/%s/blobs/%s", repository, digest), nil, nil, 0)
+
+	if err != nil {
+		return false, err
+	}
+
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return true, nil
+	case http.StatusNotFound:
+		return false, nil
+	}
+
+	return false, responseError(resp)
+}
+
+// GetBlob streams a blob from the registry. The caller closes the returned body.
+func (c *Client) GetBlob(repository, digest string) (io.ReadCloser, int64, error) {
+	resp, err := c.do(http.MethodGet, fmt.Sprintf("/v2/%s/blobs/%s", repository, digest), nil, nil, 0)
+
+	if err != nil {
+		return nil, 0, err
+	}
+
+	if resp.StatusCode > 299 {
+		defer resp.Body.Close()
+		return nil, 0, responseError(resp)
+	}
+
+	return resp.Body, resp.ContentLength, nil
+}
+
+// UploadBlob uploads a blob in a single request. The body function is called for
+// each attempt, so the upload can be retried after authenticating.
+func (c *Client) UploadBlob(repository, digest string, size int64, body func() (io.ReadCloser, error)) error {
+	resp, err := c.do(http.MethodPost, fmt.Sprintf("/v2/%s/blobs/uploads/", repository), nil, nil, 0)
+
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusAccepted {
+		return responseError(resp)
+	}
+
+	location, err := c.resolve(resp.Header.Get("Location"))
+
+	if err != nil {
+		return fmt.Errorf("invalid upload location: %w", err)
+	}
+
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+
+	resp, err = c.do(http.MethodPut, location.String(), header, body, size)
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+// GetManifest retrieves a manifest by tag or digest, along with its media type
+func (c *Client) GetManifest(repository, reference string) ([]byte, string, error) {
+	header := http.Header{}
+	header.Set("Accept", strings.Join(manifestMediaTypes, ", "))
+
+	resp, err := c.do(http.MethodGet, fmt.Sprintf("/v2/%s/manifests/%s", repository, reference), header, nil, 0)
+
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode > 299 {
+		return nil, "", responseError(resp)
+	}
+
+	manifest, err := io.ReadAll(resp.Body)
+
+	if err != nil {
+		return nil, "", fmt.Errorf("could not read manifest: %w", err)
+	}
+
+	mediaType := resp.Header.Get("Content-Type")
+
+	// Fall back to the media type within the manifest
+	if len(mediaType) == 0 || mediaType == "application/json" {
+		m := &Manifest{}
+		if err = json.Unmarshal(manifest, m); err == nil {
+			mediaType = m.MediaType
+		}
+	}
+
+	return manifest, mediaType, nil
+}
+
+// PutManifest uploads a manifest under a tag or digest
+func (c *Client) PutManifest(repository, reference, mediaType string, manifest []byte) error {
+	header := http.Header{}
+	header.Set("Content-Type", mediaType)
+
+	body := func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(manifest)), nil
+	}
+
+	resp, err := c.do(http.MethodPut, fmt.Sprintf("/v2/%s/manifests/%s", repository, reference), header, body, int64(len(manifest)))
+
+	if err != nil {
+		return err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusCreated {
+		return responseError(resp)
+	}
+
+	return nil
+}
+
+// do makes a request, authenticating and retrying once if the registry asks for credentials
+func (c *Client) do(method, path string, header http.Header, body func() (io.ReadCloser, error), size int64) (*http.Response, error) {
+	target, err := c.resolve(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry url: %w", err)
+	}
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		var reqBody io.ReadCloser
+		if body != nil {
+			if reqBody, err = body(); err != nil {
+				return nil, fmt.Errorf("could not read request body: %w", err)
+			}
+		}
+
+		req, err := http.NewRequest(method, target.String(), reqBody)
+
+		if err != nil {
+			return nil, fmt.Errorf("could not create http request object: %w", err)
+		}
+
+		for k, v := range header {
+			req.Header[k] = v
+		}
+
+		if body != nil {
+			req.ContentLength = size
+		}
+
+		if len(c.token) > 0 {
+			req.Header.Set("Authorization", "Bearer "+c.token)
+		} else if c.basicAuth {
+			req.SetBasicAuth(c.Username, c.Password)
+		}
+
+		logger.GetLogger().Debug("caller", "registry.client", "msg", "making http request", "attempt", attempt, "method", method, "url", target.Redacted())
+
+		resp, err := c.http.Do(req)
+
+		if err != nil {
+			return nil, fmt.Errorf("http client error: %w", err)
+		}
+
+		if resp.StatusCode != http.StatusUnauthorized || attempt == 2 {
+			return resp, nil
+		}
+
+		challenge := resp.Header.Get("WWW-Authenticate")
+		resp.Body.Close()
+
+		if err = c.authenticate(challenge); err != nil {
+			return nil, err
+		}
+	}
+
+	return nil, fmt.Errorf("could not authenticate with registry")
+}
+
+// authenticate responds to a WWW-Authenticate challenge. Basic challenges use the
+// credentials directly, bearer challenges exchange them for a token.
+// See https://docs.docker.com/registry/spec/auth/token/
+func (c *Client) authenticate(challenge string) error {
+	scheme, params := parseChallenge(challenge)
+
+	switch strings.ToLower(scheme) {
+	case "basic":
+		if len(c.Username) == 0 {
+			return fmt.Errorf("registry %s requires credentials", c.BaseUrl)
+		}
+		c.basicAuth = true
+		return nil
+	case "bearer":
+		realm, err := url.Parse(params["realm"])
+
+		if err != nil || len(params["realm"]) == 0 {
+			return fmt.Errorf("invalid registry auth realm '%s'", params["realm"])
+		}
+
+		query := realm.Query()
+		if len(params["service"]) > 0 {
+			query.Set("service", params["service"])
+		}
+		if len(params["scope"]) > 0 {
+			query.Set("scope", params["scope"])
+		}
+		realm.RawQuery = query.Encode()
+
+		req, err := http.NewRequest(http.MethodGet, realm.String(), nil)
+
+		if err != nil {
+			return fmt.Errorf("could not create http request object: %w", err)
+		}
+
+		if len(c.Username) > 0 {
+			req.SetBasicAuth(c.Username, c.Password)
+		}
+
+		resp, err := c.http.Do(req)
+
+		if err != nil {
+			return fmt.Errorf("http client error: %w", err)
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode > 299 {
+			return fmt.Errorf("could not authenticate with registry: %w", responseError(resp))
+		}
+
+		token := &struct {
+			Token       string `json:"token"`
+			AccessToken string `json:"access_token"`
+		}{}
+
+		if err = json.NewDecoder(resp.Body).Decode(token); err != nil {
+			return fmt.Errorf("could not decode registry token: %w", err)
+		}
+
+		c.token = token.Token
+		if len(c.token) == 0 {
+			c.token = token.AccessToken
+		}
+
+		if len(c.token) == 0 {
+			return fmt.Errorf("registry did not return a token")
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("unsupported registry auth challenge '%s'", challenge)
+}
+
+// resolve turns a path, or a (possibly relative) URL returned by the registry, into a full URL
+func (c *Client) resolve(path string) (*url.URL, error) {
+	base, err := url.Parse(c.BaseUrl + "/")
+
+	if err != nil {
+		return nil, err
+	}
+
+	ref, err := url.Parse(path)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return base.ResolveReference(ref), nil
+}
+
+// parseChallenge parses a header such as: Bearer realm="https://auth.example.com/token",service="example.com"
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+
+	scheme, rest, _ := strings.Cut(strings.TrimSpace(challenge), " ")
+
+	for len(rest) > 0 {
+		rest = strings.TrimLeft(rest, " ,")
+		key, value, found := strings.Cut(rest, "=")
+
+		if !found {
+			break
+		}
+
+		if strings.HasPrefix(value, `"`) {
+			end := strings.Index(value[1:], `"`)
+			if end < 0 {
+				params[strings.ToLower(key)] = value[1:]
+				break
+			}
+			params[strings.ToLower(key)] = value[1 : end+1]
+			rest = value[end+2:]
+		} else {
+			v, next, _ := strings.Cut(value, ",")
+			params[strings.ToLower(key)] = v
+			rest = next
+		}
+	}
+
+	return scheme, params
+}
+
+func responseError(resp *http.Response) error {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return fmt.Errorf("invalid request: status=%d, body=%s", resp.StatusCode, strings.TrimSpace(string(body)))
+}
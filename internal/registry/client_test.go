@@ -0,0 +1,263 @@
+package registry
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeRegistry is an in-memory registry. It asks for basic auth, or for a bearer
+// token from its /token endpoint, if auth is set to "basic" or "bearer".
+type fakeRegistry struct {
+	*httptest.Server
+
+	auth     string
+	username string
+	password string
+
+	mu        sync.Mutex
+	blobs     map[string][]byte
+	manifests map[string]fakeManifest
+	uploads   int
+}
+
+type fakeManifest struct {
+	mediaType string
+	body      []byte
+}
+
+const fakeToken = "fake-registry-token"
+
+func newFakeRegistry(t *testing.T, auth string) *fakeRegistry {
+	t.Helper()
+
+	r := &fakeRegistry{
+		auth:      auth,
+		username:  "user",
+		password:  "secret",
+		blobs:     make(map[string][]byte),
+		manifests: make(map[string]fakeManifest),
+	}
+	r.Server = httptest.NewServer(r)
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *fakeRegistry) client() *Client {
+	return NewClient(r.URL, r.username, r.password)
+}
+
+func (r *fakeRegistry) authorized(req *http.Request) bool {
+	switch r.auth {
+	case "basic":
+		user, pass, ok := req.BasicAuth()
+		return ok && user == r.username && pass == r.password
+	case "bearer":
+		return req.Header.Get("Authorization") == "Bearer "+fakeToken
+	}
+
+	return true
+}
+
+func (r *fakeRegistry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	if req.URL.Path == "/token" {
+		user, pass, ok := req.BasicAuth()
+		if !ok || user != r.username || pass != r.password || req.URL.Query().Get("service") != "fake" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		_ = json.NewEncoder(w).Encode(map[string]string{"token": fakeToken})
+		return
+	}
+
+	if !r.authorized(req) {
+		switch r.auth {
+		case "basic":
+			w.Header().Set("WWW-Authenticate", `Basic realm="fake"`)
+		case "bearer":
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="fake",scope="repository:app:pull,push"`, r.URL))
+		}
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	path := strings.TrimPrefix(req.URL.Path, "/v2/")
+
+	switch {
+	case req.URL.Path == "/v2/":
+		w.WriteHeader(http.StatusOK)
+	case strings.HasSuffix(path, "/blobs/uploads/") && req.Method == http.MethodPost:
+		w.Header().Set("Location", "/upload/"+strings.TrimSuffix(path, "/blobs/uploads/"))
+		w.WriteHeader(http.StatusAccepted)
+	case strings.HasPrefix(req.URL.Path, "/upload/") && req.Method == http.MethodPut:
+		body, _ := io.ReadAll(req.Body)
+		digest := req.URL.Query().Get("digest")
+
+		if digest != digestOf(body) {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		r.blobs[digest] = body
+		r.uploads++
+		w.WriteHeader(http.StatusCreated)
+	case strings.Contains(path, "/blobs/"):
+		_, digest, _ := strings.Cut(path, "/blobs/")
+		blob, ok := r.blobs[digest]
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		if req.Method == http.MethodGet {
+			_, _ = w.Write(blob)
+		}
+	case strings.Contains(path, "/manifests/"):
+		repository, reference, _ := strings.Cut(path, "/manifests/")
+		key := repository + ":" + reference
+
+		if req.Method == http.MethodPut {
+			body, _ := io.ReadAll(req.Body)
+			m := fakeManifest{mediaType: req.Header.Get("Content-Type"), body: body}
+			r.manifests[key] = m
+			r.manifests[repository+":"+digestOf(body)] = m
+			w.WriteHeader(http.StatusCreated)
+			return
+		}
+
+		m, ok := r.manifests[key]
+
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		w.Header().Set("Content-Type", m.mediaType)
+		_, _ = w.Write(m.body)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// addBlob stores a blob, returning its descriptor
+func (r *fakeRegistry) addBlob(mediaType string, blob []byte) Descriptor {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	digest := digestOf(blob)
+	r.blobs[digest] = blob
+
+	return Descriptor{MediaType: mediaType, Digest: digest, Size: int64(len(blob))}
+}
+
+// addManifest stores a manifest under a repository and reference, and under its digest
+func (r *fakeRegistry) addManifest(repository, reference, mediaType string, manifest interface{}) Descriptor {
+	body, _ := json.Marshal(manifest)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	m := fakeManifest{mediaType: mediaType, body: body}
+	r.manifests[repository+":"+reference] = m
+	r.manifests[repository+":"+digestOf(body)] = m
+
+	return Descriptor{MediaType: mediaType, Digest: digestOf(body), Size: int64(len(body))}
+}
+
+func digestOf(blob []byte) string {
+	sum := sha256.Sum256(blob)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+func TestClientAuthenticates(t *testing.T) {
+	for _, auth := range []string{"none", "basic", "bearer"} {
+		t.Run(auth, func(t *testing.T) {
+			r := newFakeRegistry(t, auth)
+
+			if err := r.client().Ping(); err != nil {
+				t.Fatalf("could not ping registry: %v", err)
+			}
+
+			wrong := NewClient(r.URL, "user", "wrong")
+
+			if err := wrong.Ping(); auth != "none" && err == nil {
+				t.Error("expected wrong credentials to be refused")
+			}
+		})
+	}
+}
+
+func TestClientUploadsBlobsAndManifests(t *testing.T) {
+	r := newFakeRegistry(t, "bearer")
+	c := r.client()
+
+	blob := []byte("layer contents")
+	digest := digestOf(blob)
+
+	exists, err := c.BlobExists("app", digest)
+
+	if err != nil || exists {
+		t.Fatalf("expected blob not to exist yet, got %v, %v", exists, err)
+	}
+
+	body := func() (io.ReadCloser, error) {
+		return io.NopCloser(strings.NewReader(string(blob))), nil
+	}
+
+	if err = c.UploadBlob("app", digest, int64(len(blob)), body); err != nil {
+		t.Fatalf("could not upload blob: %v", err)
+	}
+
+	if exists, err = c.BlobExists("app", digest); err != nil || !exists {
+		t.Fatalf("expected blob to exist, got %v, %v", exists, err)
+	}
+
+	manifest := []byte(`{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","layers":[]}`)
+
+	if err = c.PutManifest("app", "latest", MediaTypeOCIManifest, manifest); err != nil {
+		t.Fatalf("could not put manifest: %v", err)
+	}
+
+	got, mediaType, err := c.GetManifest("app", "latest")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(got) != string(manifest) || mediaType != MediaTypeOCIManifest {
+		t.Errorf("unexpected manifest %s (%s)", got, mediaType)
+	}
+}
+
+func TestParseChallenge(t *testing.T) {
+	scheme, params := parseChallenge(`Bearer realm="https://auth.example.com/token",service="registry.example.com",scope="repository:org/app:pull,push"`)
+
+	if scheme != "Bearer" {
+		t.Errorf("unexpected scheme %s", scheme)
+	}
+
+	expected := map[string]string{
+		"realm":   "https://auth.example.com/token",
+		"service": "registry.example.com",
+		"scope":   "repository:org/app:pull,push",
+	}
+
+	for k, v := range expected {
+		if params[k] != v {
+			t.Errorf("expected %s to be '%s', got '%s'", k, v, params[k])
+		}
+	}
+}
@@ -0,0 +1,91 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// CopyImage copies an image between registries, e.g. from a private registry into registry.fly.io.
+// Multi-platform images are copied as their linux/amd64 image, the platform Fly machines run.
+func CopyImage(src *Client, srcRepository, srcReference string, dst *Client, dstRepository, dstTag string) error {
+	manifest, mediaType, err := src.GetManifest(srcRepository, srcReference)
+
+	if err != nil {
+		return fmt.Errorf("could not get manifest of %s:%s: %w", srcRepository, srcReference, err)
+	}
+
+	if IsIndex(mediaType) {
+		index := &Manifest{}
+		if err = json.Unmarshal(manifest, index); err != nil {
+			return fmt.Errorf("could not decode image index: %w", err)
+		}
+
+		platformManifest, err := findPlatform(index, "linux", "amd64")
+
+		if err != nil {
+			return err
+		}
+
+		manifest, mediaType, err = src.GetManifest(srcRepository, platformManifest.Digest)
+
+		if err != nil {
+			return fmt.Errorf("could not get linux/amd64 manifest: %w", err)
+		}
+	}
+
+	image := &Manifest{}
+	if err = json.Unmarshal(manifest, image); err != nil {
+		return fmt.Errorf("could not decode image manifest: %w", err)
+	}
+
+	blobs := image.Layers
+	if image.Config != nil {
+		blobs = append([]Descriptor{*image.Config}, blobs...)
+	}
+
+	for _, blob := range blobs {
+		if err = copyBlob(src, srcRepository, dst, dstRepository, blob); err != nil {
+			return err
+		}
+	}
+
+	if err = dst.PutManifest(dstRepository, dstTag, mediaType, manifest); err != nil {
+		return fmt.Errorf("could not push manifest: %w", err)
+	}
+
+	return nil
+}
+
+func copyBlob(src *Client, srcRepository string, dst *Client, dstRepository string, blob Descriptor) error {
+	exists, err := dst.BlobExists(dstRepository, blob.Digest)
+
+	if err != nil {
+		return fmt.Errorf("could not check for blob %s: %w", blob.Digest, err)
+	}
+
+	if exists {
+		return nil
+	}
+
+	body := func() (io.ReadCloser, error) {
+		reader, _, err := src.GetBlob(srcRepository, blob.Digest)
+		return reader, err
+	}
+
+	if err = dst.UploadBlob(dstRepository, blob.Digest, blob.Size, body); err != nil {
+		return fmt.Errorf("could not copy blob %s: %w", blob.Digest, err)
+	}
+
+	return nil
+}
+
+func findPlatform(index *Manifest, os, architecture string) (*Descriptor, error) {
+	for _, m := range index.Manifests {
+		if m.Platform != nil && m.Platform.OS == os && m.Platform.Architecture == architecture {
+			return &m, nil
+		}
+	}
+
+	return nil, fmt.Errorf("image has no %s/%s platform", os, architecture)
+}
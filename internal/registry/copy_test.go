@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestCopyImageSelectsLinuxAmd64(t *testing.T) {
+	src := newFakeRegistry(t, "basic")
+	dst := newFakeRegistry(t, "bearer")
+
+	platforms := map[string]Descriptor{}
+	for _, arch := range []string{"arm64", "amd64"} {
+		config := src.addBlob("application/vnd.oci.image.config.v1+json", []byte(`{"architecture":"`+arch+`"}`))
+		layer := src.addBlob("application/vnd.oci.image.layer.v1.tar+gzip", []byte("layer for "+arch))
+
+		platforms[arch] = src.addManifest("acme/php", arch, MediaTypeOCIManifest, &Manifest{
+			SchemaVersion: 2,
+			MediaType:     MediaTypeOCIManifest,
+			Config:        &config,
+			Layers:        []Descriptor{layer},
+		})
+	}
+
+	index := &Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIIndex}
+	for _, arch := range []string{"arm64", "amd64"} {
+		d := platforms[arch]
+		d.Platform = &Platform{OS: "linux", Architecture: arch}
+		index.Manifests = append(index.Manifests, d)
+	}
+
+	src.addManifest("acme/php", "8.2", MediaTypeOCIIndex, index)
+
+	if err := CopyImage(src.client(), "acme/php", "8.2", dst.client(), "app", "acme-php-8.2"); err != nil {
+		t.Fatalf("could not copy image: %v", err)
+	}
+
+	manifest, mediaType, err := dst.client().GetManifest("app", "acme-php-8.2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if mediaType != MediaTypeOCIManifest || digestOf(manifest) != platforms["amd64"].Digest {
+		t.Errorf("expected the linux/amd64 manifest to be copied, got %s (%s)", manifest, mediaType)
+	}
+
+	m := &Manifest{}
+	if err = json.Unmarshal(manifest, m); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, blob := range append([]Descriptor{*m.Config}, m.Layers...) {
+		if exists, err := dst.client().BlobExists("app", blob.Digest); err != nil || !exists {
+			t.Errorf("expected blob %s to be copied", blob.Digest)
+		}
+	}
+
+	// Blobs the destination already has aren't uploaded again
+	uploads := dst.uploads
+
+	if err = CopyImage(src.client(), "acme/php", "8.2", dst.client(), "app", "acme-php-8.2"); err != nil {
+		t.Fatal(err)
+	}
+
+	if dst.uploads != uploads {
+		t.Errorf("expected no more uploads, got %d", dst.uploads-uploads)
+	}
+}
+
+func TestCopyImageWithoutLinuxAmd64(t *testing.T) {
+	src := newFakeRegistry(t, "none")
+	dst := newFakeRegistry(t, "none")
+
+	arm := src.addManifest("acme/php", "arm64", MediaTypeOCIManifest, &Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIManifest})
+	arm.Platform = &Platform{OS: "linux", Architecture: "arm64"}
+	src.addManifest("acme/php", "8.2", MediaTypeOCIIndex, &Manifest{SchemaVersion: 2, MediaType: MediaTypeOCIIndex, Manifests: []Descriptor{arm}})
+
+	if err := CopyImage(src.client(), "acme/php", "8.2", dst.client(), "app", "acme-php-8.2"); err == nil {
+		t.Error("expected an image without a linux/amd64 platform to be refused")
+	}
+}
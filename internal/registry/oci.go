@@ -0,0 +1,199 @@
+package registry
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ociRefNameAnnotation names the tag of a manifest within an OCI layout's index.json
+const ociRefNameAnnotation = "org.opencontainers.image.ref.name"
+
+// PushOCILayout pushes an image in OCI layout (a directory, or a tarball of one such as
+// `docker save` creates) to a repository under the given tag. If the layout holds several
+// images, the one annotated with the tag is pushed, otherwise the first one.
+// See https://github.com/opencontainers/image-spec/blob/main/image-layout.md
+func PushOCILayout(dst *Client, path, repository, tag string) error {
+	stat, err := os.Stat(path)
+
+	if err != nil {
+		return fmt.Errorf("could not read image: %w", err)
+	}
+
+	layout := path
+	if !stat.IsDir() {
+		tmp, err := os.MkdirTemp("", "vessel-image-")
+
+		if err != nil {
+			return fmt.Errorf("could not create temporary directory: %w", err)
+		}
+
+		defer os.RemoveAll(tmp)
+
+		if err = extractTar(path, tmp); err != nil {
+			return fmt.Errorf("could not extract image tarball: %w", err)
+		}
+
+		layout = tmp
+	}
+
+	index := &Manifest{}
+	indexJson, err := os.ReadFile(filepath.Join(layout, "index.json"))
+
+	if err != nil {
+		return fmt.Errorf("image is not in OCI layout, could not read index.json: %w", err)
+	}
+
+	if err = json.Unmarshal(indexJson, index); err != nil {
+		return fmt.Errorf("could not decode index.json: %w", err)
+	}
+
+	if len(index.Manifests) == 0 {
+		return fmt.Errorf("index.json does not list any images")
+	}
+
+	image := index.Manifests[0]
+	for _, m := range index.Manifests {
+		if m.Annotations[ociRefNameAnnotation] == tag {
+			image = m
+			break
+		}
+	}
+
+	return pushLayoutManifest(dst, layout, repository, tag, image)
+}
+
+// pushLayoutManifest pushes the blobs a manifest refers to, then the manifest itself.
+// Indexes are pushed along with each manifest they list.
+func pushLayoutManifest(dst *Client, layout, repository, reference string, descriptor Descriptor) error {
+	manifest, err := os.ReadFile(blobPath(layout, descriptor.Digest))
+
+	if err != nil {
+		return fmt.Errorf("could not read manifest %s: %w", descriptor.Digest, err)
+	}
+
+	m := &Manifest{}
+	if err = json.Unmarshal(manifest, m); err != nil {
+		return fmt.Errorf("could not decode manifest %s: %w", descriptor.Digest, err)
+	}
+
+	mediaType := descriptor.MediaType
+	if len(mediaType) == 0 {
+		mediaType = m.MediaType
+	}
+
+	if IsIndex(mediaType) {
+		for _, child := range m.Manifests {
+			if err = pushLayoutManifest(dst, layout, repository, child.Digest, child); err != nil {
+				return err
+			}
+		}
+	} else {
+		blobs := m.Layers
+		if m.Config != nil {
+			blobs = append([]Descriptor{*m.Config}, blobs...)
+		}
+
+		for _, blob := range blobs {
+			if err = pushLayoutBlob(dst, layout, repository, blob); err != nil {
+				return err
+			}
+		}
+	}
+
+	if err = dst.PutManifest(repository, reference, mediaType, manifest); err != nil {
+		return fmt.Errorf("could not push manifest %s: %w", descriptor.Digest, err)
+	}
+
+	return nil
+}
+
+func pushLayoutBlob(dst *Client, layout, repository string, blob Descriptor) error {
+	exists, err := dst.BlobExists(repository, blob.Digest)
+
+	if err != nil {
+		return fmt.Errorf("could not check for blob %s: %w", blob.Digest, err)
+	}
+
+	if exists {
+		return nil
+	}
+
+	path := blobPath(layout, blob.Digest)
+	body := func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+
+	if err = dst.UploadBlob(repository, blob.Digest, blob.Size, body); err != nil {
+		return fmt.Errorf("could not push blob %s: %w", blob.Digest, err)
+	}
+
+	return nil
+}
+
+// blobPath is the location of a blob within an OCI layout, e.g. blobs/sha256/<hash>
+func blobPath(layout, digest string) string {
+	algorithm, hash, _ := strings.Cut(digest, ":")
+	return filepath.Join(layout, "blobs", algorithm, hash)
+}
+
+// extractTar extracts the regular files and directories of a tarball,
+// refusing any entry that would be written outside of the destination
+func extractTar(src, dest string) error {
+	f, err := os.Open(src)
+
+	if err != nil {
+		return fmt.Errorf("could not open tar file for reading: %w", err)
+	}
+
+	defer f.Close()
+
+	tr := tar.NewReader(f)
+
+	for {
+		header, err := tr.Next()
+
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return fmt.Errorf("error during read of tar archive %s: %w", src, err)
+		}
+
+		fullPath := filepath.Join(dest, header.Name)
+
+		// Tarballs may start with a "./" entry, which is the destination itself
+		if fullPath != filepath.Clean(dest) && !strings.HasPrefix(fullPath, filepath.Clean(dest)+string(os.PathSeparator)) {
+			return fmt.Errorf("invalid file path in tar archive: %s", header.Name)
+		}
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err = os.MkdirAll(fullPath, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err = os.MkdirAll(filepath.Dir(fullPath), 0755); err != nil {
+				return err
+			}
+
+			out, err := os.Create(fullPath)
+
+			if err != nil {
+				return fmt.Errorf("failed to create file %s: %w", fullPath, err)
+			}
+
+			_, err = io.Copy(out, tr)
+			_ = out.Close()
+
+			if err != nil {
+				return fmt.Errorf("failed to copy to file %s: %w", fullPath, err)
+			}
+		}
+	}
+}
@@ -0,0 +1,144 @@
+package registry
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+type tarEntry struct {
+	name string
+	body []byte
+}
+
+// writeTar writes a tarball, adding a directory entry for names ending in a slash
+func writeTar(t *testing.T, path string, entries []tarEntry) {
+	t.Helper()
+
+	f, err := os.Create(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	tw := tar.NewWriter(f)
+
+	for _, e := range entries {
+		header := &tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.body)), Typeflag: tar.TypeReg}
+
+		if e.name[len(e.name)-1] == '/' {
+			header = &tar.Header{Name: e.name, Mode: 0755, Typeflag: tar.TypeDir}
+		}
+
+		if err = tw.WriteHeader(header); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err = tw.Write(e.body); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err = tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestExtractTar(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "image.tar")
+	dest := filepath.Join(dir, "out")
+
+	// Tarballs created with `tar -C dir -cf image.tar .` start with a "./" entry
+	writeTar(t, src, []tarEntry{
+		{name: "./"},
+		{name: "./blobs/"},
+		{name: "./index.json", body: []byte(`{}`)},
+	})
+
+	if err := extractTar(src, dest); err != nil {
+		t.Fatalf("could not extract tarball: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(dest, "index.json")); err != nil {
+		t.Errorf("expected index.json to be extracted: %v", err)
+	}
+}
+
+func TestExtractTarRefusesPathsOutsideDestination(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "image.tar")
+
+	writeTar(t, src, []tarEntry{
+		{name: "../escaped", body: []byte("oops")},
+	})
+
+	if err := extractTar(src, filepath.Join(dir, "out")); err == nil {
+		t.Error("expected an entry outside of the destination to be refused")
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, "escaped")); !os.IsNotExist(err) {
+		t.Error("expected no file to be written outside of the destination")
+	}
+}
+
+func TestPushOCILayoutTarball(t *testing.T) {
+	dst := newFakeRegistry(t, "bearer")
+	dir := t.TempDir()
+
+	config := []byte(`{"architecture":"amd64","os":"linux"}`)
+	layer := []byte("layer contents")
+	manifest, _ := json.Marshal(&Manifest{
+		SchemaVersion: 2,
+		MediaType:     MediaTypeOCIManifest,
+		Config:        &Descriptor{MediaType: "application/vnd.oci.image.config.v1+json", Digest: digestOf(config), Size: int64(len(config))},
+		Layers:        []Descriptor{{MediaType: "application/vnd.oci.image.layer.v1.tar", Digest: digestOf(layer), Size: int64(len(layer))}},
+	})
+	index, _ := json.Marshal(&Manifest{
+		SchemaVersion: 2,
+		Manifests: []Descriptor{{
+			MediaType:   MediaTypeOCIManifest,
+			Digest:      digestOf(manifest),
+			Size:        int64(len(manifest)),
+			Annotations: map[string]string{ociRefNameAnnotation: "dev"},
+		}},
+	})
+
+	blob := func(b []byte) string {
+		return "./blobs/sha256/" + digestOf(b)[len("sha256:"):]
+	}
+
+	src := filepath.Join(dir, "image.tar")
+	writeTar(t, src, []tarEntry{
+		{name: "./"},
+		{name: "./index.json", body: index},
+		{name: "./oci-layout", body: []byte(`{"imageLayoutVersion":"1.0.0"}`)},
+		{name: blob(config), body: config},
+		{name: blob(layer), body: layer},
+		{name: blob(manifest), body: manifest},
+	})
+
+	if err := PushOCILayout(dst.client(), src, "app", "dev"); err != nil {
+		t.Fatalf("could not push image: %v", err)
+	}
+
+	pushed, _, err := dst.client().GetManifest("app", "dev")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(pushed) != string(manifest) {
+		t.Errorf("unexpected manifest %s", pushed)
+	}
+
+	for _, b := range [][]byte{config, layer} {
+		if exists, err := dst.client().BlobExists("app", digestOf(b)); err != nil || !exists {
+			t.Errorf("expected blob %s to be pushed", digestOf(b))
+		}
+	}
+}
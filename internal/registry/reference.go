@@ -0,0 +1,82 @@
+package registry
+
+import (
+	"strings"
+)
+
+// DockerHub is the registry used for images without a registry host, e.g. "vesselapp/php:8.1"
+const DockerHub = "docker.io"
+
+// Reference is a parsed image name such as "ghcr.io/org/image:tag"
+type Reference struct {
+	// Registry is the registry host, e.g. "ghcr.io"
+	Registry string
+	// Repository is the image name within the registry, e.g. "org/image"
+	Repository string
+	// Tag is the tag or digest (sha256:...) of the image
+	Tag string
+}
+
+// ParseReference parses an image name. Images without a registry host are on Docker Hub,
+// and Docker Hub images without an organization are in its "library" organization.
+func ParseReference(image string) Reference {
+	ref := Reference{
+		Registry: DockerHub,
+		Tag:      "latest",
+	}
+
+	name := image
+
+	if at := strings.Index(name, "@"); at >= 0 {
+		ref.Tag = name[at+1:]
+		name = name[:at]
+	} else if colon := strings.LastIndex(name, ":"); colon > strings.LastIndex(name, "/") {
+		ref.Tag = name[colon+1:]
+		name = name[:colon]
+	}
+
+	// The first part of the name is a registry host if it looks like a hostname
+	if slash := strings.Index(name, "/"); slash >= 0 {
+		first := name[:slash]
+		if strings.ContainsAny(first, ".:") || first == "localhost" {
+			ref.Registry = first
+			name = name[slash+1:]
+		}
+	}
+
+	if ref.Registry == DockerHub && !strings.Contains(name, "/") {
+		name = "library/" + name
+	}
+
+	ref.Repository = name
+
+	return ref
+}
+
+// String returns the full image name
+func (r Reference) String() string {
+	if strings.HasPrefix(r.Tag, "sha256:") {
+		return r.Registry + "/" + r.Repository + "@" + r.Tag
+	}
+
+	return r.Registry + "/" + r.Repository + ":" + r.Tag
+}
+
+// BaseUrl is the registry API's base URL. Local registries are reached
+// over plain HTTP, all others over HTTPS.
+func BaseUrl(registry string) string {
+	if registry == DockerHub {
+		return "https://registry-1.docker.io"
+	}
+
+	host := registry
+	if i := strings.LastIndex(host, ":"); i >= 0 && !strings.HasSuffix(host, "]") {
+		host = host[:i]
+	}
+
+	if host == "localhost" || host == "127.0.0.1" || host == "[::1]" {
+		return "http://" + registry
+	}
+
+	return "https://" + registry
+}
@@ -0,0 +1,57 @@
+package registry
+
+import "testing"
+
+func TestParseReference(t *testing.T) {
+	tests := []struct {
+		image    string
+		expected Reference
+	}{
+		{"mysql", Reference{Registry: DockerHub, Repository: "library/mysql", Tag: "latest"}},
+		{"mysql:8.0", Reference{Registry: DockerHub, Repository: "library/mysql", Tag: "8.0"}},
+		{"vesselapp/php:8.2", Reference{Registry: DockerHub, Repository: "vesselapp/php", Tag: "8.2"}},
+		{"ghcr.io/acme/php", Reference{Registry: "ghcr.io", Repository: "acme/php", Tag: "latest"}},
+		{"ghcr.io/acme/team/php:8.2-fpm", Reference{Registry: "ghcr.io", Repository: "acme/team/php", Tag: "8.2-fpm"}},
+		{"localhost:5000/php:dev", Reference{Registry: "localhost:5000", Repository: "php", Tag: "dev"}},
+		{"localhost/php", Reference{Registry: "localhost", Repository: "php", Tag: "latest"}},
+		{"ghcr.io/acme/php@sha256:abc", Reference{Registry: "ghcr.io", Repository: "acme/php", Tag: "sha256:abc"}},
+	}
+
+	for _, test := range tests {
+		if ref := ParseReference(test.image); ref != test.expected {
+			t.Errorf("%s: expected %+v, got %+v", test.image, test.expected, ref)
+		}
+	}
+}
+
+func TestReferenceString(t *testing.T) {
+	tests := map[string]Reference{
+		"ghcr.io/acme/php:8.2":        {Registry: "ghcr.io", Repository: "acme/php", Tag: "8.2"},
+		"ghcr.io/acme/php@sha256:abc": {Registry: "ghcr.io", Repository: "acme/php", Tag: "sha256:abc"},
+	}
+
+	for expected, ref := range tests {
+		if s := ref.String(); s != expected {
+			t.Errorf("expected %s, got %s", expected, s)
+		}
+	}
+}
+
+func TestBaseUrl(t *testing.T) {
+	tests := map[string]string{
+		DockerHub:         "https://registry-1.docker.io",
+		"ghcr.io":         "https://ghcr.io",
+		"registry.fly.io": "https://registry.fly.io",
+		"localhost":       "http://localhost",
+		"localhost:5000":  "http://localhost:5000",
+		"127.0.0.1:5000":  "http://127.0.0.1:5000",
+		"[::1]:5000":      "http://[::1]:5000",
+		"example.com:443": "https://example.com:443",
+	}
+
+	for registry, expected := range tests {
+		if u := BaseUrl(registry); u != expected {
+			t.Errorf("%s: expected %s, got %s", registry, expected, u)
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package registry
+
+// Media types of the manifests we push and pull
+const (
+	MediaTypeOCIIndex           = "application/vnd.oci.image.index.v1+json"
+	MediaTypeOCIManifest        = "application/vnd.oci.image.manifest.v1+json"
+	MediaTypeDockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	MediaTypeDockerManifest     = "application/vnd.docker.distribution.manifest.v2+json"
+)
+
+// manifestMediaTypes are accepted when pulling a manifest
+var manifestMediaTypes = []string{
+	MediaTypeOCIIndex,
+	MediaTypeDockerManifestList,
+	MediaTypeOCIManifest,
+	MediaTypeDockerManifest,
+}
+
+// IsIndex determines if a media type is a list of manifests (a multi-platform image)
+func IsIndex(mediaType string) bool {
+	return mediaType == MediaTypeOCIIndex || mediaType == MediaTypeDockerManifestList
+}
+
+// Descriptor points to a blob or manifest by its digest
+type Descriptor struct {
+	MediaType   string            `json:"mediaType,omitempty"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	Platform    *Platform         `json:"platform,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+}
+
+// Manifest is an image manifest, or an index of manifests. Only the fields
+// needed to find the blobs an image is made of are decoded.
+type Manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType,omitempty"`
+	Config        *Descriptor  `json:"config,omitempty"`
+	Layers        []Descriptor `json:"layers,omitempty"`
+	Manifests     []Descriptor `json:"manifests,omitempty"`
+}
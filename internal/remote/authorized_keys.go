@@ -0,0 +1,110 @@
+package remote
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+)
+
+// PasswordFunc asks for the password of an SSH host's user
+type PasswordFunc func() (string, error)
+
+// InstallKey adds a public key to the authorized_keys file of an SSH host, so later connections
+// can use the key. As the key isn't authorized yet, we authenticate with the keys of a running
+// ssh-agent (if SSH_AUTH_SOCK is set), falling back to asking for the user's password. The host's
+// key is checked against ~/.ssh/known_hosts before authenticating, asking confirm to trust it if
+// the host isn't known yet.
+func InstallKey(cfg *config.RemoteConfig, publicKey string, password PasswordFunc, confirm HostKeyConfirmFunc) error {
+	key := ShellQuote(strings.TrimSpace(publicKey))
+	cmd := fmt.Sprintf(`umask 077 && mkdir -p ~/.ssh && touch ~/.ssh/authorized_keys && (grep -qxF %s ~/.ssh/authorized_keys || echo %s >> ~/.ssh/authorized_keys)`, key, key)
+
+	auth := make([]ssh.AuthMethod, 0, 3)
+
+	if socket := os.Getenv("SSH_AUTH_SOCK"); len(socket) > 0 {
+		conn, err := net.Dial("unix", socket)
+
+		if err != nil {
+			return fmt.Errorf("could not connect to ssh-agent: %w", err)
+		}
+
+		defer conn.Close()
+
+		auth = append(auth, ssh.PublicKeysCallback(agent.NewClient(conn).Signers))
+	}
+
+	if password != nil {
+		// Some hosts only allow passwords through keyboard-interactive auth
+		auth = append(auth, ssh.RetryableAuthMethod(ssh.PasswordCallback(password), 3))
+		auth = append(auth, ssh.RetryableAuthMethod(ssh.KeyboardInteractive(func(user, instruction string, questions []string, echos []bool) ([]string, error) {
+			answers := make([]string, len(questions))
+			for i := range questions {
+				answer, err := password()
+
+				if err != nil {
+					return nil, err
+				}
+
+				answers[i] = answer
+			}
+
+			return answers, nil
+		}), 3))
+	}
+
+	path, err := knownHostsPath()
+
+	if err != nil {
+		return err
+	}
+
+	hostKeyCallback, err := knownHostsCallback(path, confirm)
+
+	if err != nil {
+		return err
+	}
+
+	clientConfig := &ssh.ClientConfig{
+		User:            cfg.User,
+		Auth:            auth,
+		Timeout:         5 * time.Second,
+		HostKeyCallback: hostKeyCallback,
+	}
+
+	hostSocket := net.JoinHostPort(cfg.Hostname, strconv.Itoa(cfg.Port))
+	conn, err := ssh.Dial("tcp", hostSocket, clientConfig)
+
+	if err != nil {
+		return fmt.Errorf("cannot connect %v: %w", hostSocket, err)
+	}
+
+	defer conn.Close()
+
+	session, err := conn.NewSession()
+
+	if err != nil {
+		return fmt.Errorf("cannot open new session: %w", err)
+	}
+
+	defer session.Close()
+
+	if output, err := session.CombinedOutput(cmd); err != nil {
+		return fmt.Errorf("could not add key to authorized_keys: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+
+	return nil
+}
+
+// RemoveKey removes a public key from the authorized_keys file of the connection's host
+func (c *Connection) RemoveKey(publicKey string) error {
+	key := ShellQuote(strings.TrimSpace(publicKey))
+
+	// grep exits non-zero when no keys are left, which is fine
+	return c.Run(fmt.Sprintf(`umask 077 && grep -vxF %s ~/.ssh/authorized_keys > ~/.ssh/authorized_keys.vessel; mv ~/.ssh/authorized_keys.vessel ~/.ssh/authorized_keys`, key))
+}
@@ -9,21 +9,106 @@ import (
 	"golang.org/x/crypto/ssh/terminal"
 	"net"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
 )
 
 type Connection struct {
-	config *config.RemoteConfig
+	config     *config.RemoteConfig
+	env        map[string]string
+	dial       DialFunc
+	knownHosts bool
+	pinnedKeys string
 }
 
+// DialFunc opens a network connection to an address, e.g. through a tunnel
+type DialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
 func NewConnection(cfg *config.RemoteConfig) *Connection {
 	return &Connection{
 		config: cfg,
 	}
 }
 
+// WithEnv sets environment variables for commands and shells run over the connection.
+// SSH servers usually refuse variables sent by the client, so they are exported
+// within the remote command itself.
+func (c *Connection) WithEnv(env map[string]string) *Connection {
+	c.env = env
+	return c
+}
+
+// WithDialer connects to the host using the given dial function,
+// instead of connecting to it directly
+func (c *Connection) WithDialer(dial DialFunc) *Connection {
+	c.dial = dial
+	return c
+}
+
+// WithKnownHosts verifies the host's key against ~/.ssh/known_hosts, for long-lived hosts
+// whose key was trusted when the environment was created
+func (c *Connection) WithKnownHosts() *Connection {
+	c.knownHosts = true
+	return c
+}
+
+// WithPinnedHostKey verifies the host's key against its own known_hosts file. The key of a
+// host that isn't in the file yet is trusted on first use and added to it.
+func (c *Connection) WithPinnedHostKey(knownHostsPath string) *Connection {
+	c.pinnedKeys = knownHostsPath
+	return c
+}
+
+// connect opens an SSH connection to the host
+func (c *Connection) connect(config *ssh.ClientConfig) (*ssh.Client, error) {
+	hostSocket := net.JoinHostPort(c.config.Hostname, strconv.Itoa(c.config.Port))
+
+	if c.dial == nil {
+		return ssh.Dial("tcp", hostSocket, config)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), config.Timeout)
+	defer cancel()
+
+	conn, err := c.dial(ctx, "tcp", hostSocket)
+
+	if err != nil {
+		return nil, err
+	}
+
+	sshConn, channels, requests, err := ssh.NewClientConn(conn, hostSocket, config)
+
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return ssh.NewClient(sshConn, channels, requests), nil
+}
+
+// exports generates shell statements exporting the connection's environment variables
+func (c *Connection) exports() string {
+	keys := make([]string, 0, len(c.env))
+	for k := range c.env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	exports := ""
+	for _, k := range keys {
+		exports += fmt.Sprintf("export %s=%s && ", k, ShellQuote(c.env[k]))
+	}
+
+	return exports
+}
+
+// ShellQuote single-quotes a value for a POSIX shell
+func ShellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
 func (c *Connection) clientConfig() (*ssh.ClientConfig, error) {
 	var sshKey string
 	if strings.HasPrefix(c.config.IdentityFile, "~/") {
@@ -48,13 +133,31 @@ func (c *Connection) clientConfig() (*ssh.ClientConfig, error) {
 		return nil, fmt.Errorf("unable to parse private key: %w", err)
 	}
 
+	hostKeyCallback := ssh.InsecureIgnoreHostKey()
+
+	if c.knownHosts {
+		path, err := knownHostsPath()
+
+		if err != nil {
+			return nil, err
+		}
+
+		if hostKeyCallback, err = knownHostsCallback(path, nil); err != nil {
+			return nil, err
+		}
+	} else if len(c.pinnedKeys) > 0 {
+		if hostKeyCallback, err = knownHostsCallback(c.pinnedKeys, trustOnFirstUse); err != nil {
+			return nil, err
+		}
+	}
+
 	return &ssh.ClientConfig{
 		User: c.config.User,
 		Auth: []ssh.AuthMethod{
 			ssh.PublicKeys(signer),
 		},
 		Timeout:         5 * time.Second,
-		HostKeyCallback: ssh.InsecureIgnoreHostKey(),
+		HostKeyCallback: hostKeyCallback,
 	}, nil
 }
 
@@ -67,7 +170,7 @@ func (c *Connection) TestConnection() error {
 
 	hostSocket := net.JoinHostPort(c.config.Hostname, strconv.Itoa(c.config.Port))
 
-	conn, err := ssh.Dial("tcp", hostSocket, config)
+	conn, err := c.connect(config)
 
 	if err != nil {
 		return fmt.Errorf("cannot connect %v: %w", hostSocket, err)
@@ -78,7 +181,13 @@ func (c *Connection) TestConnection() error {
 	return nil
 }
 
+// Cmd runs a command within the project's remote path
 func (c *Connection) Cmd(cmd string) error {
+	return c.Run(fmt.Sprintf("cd %s && %s", ShellQuote(c.config.RemotePath), cmd))
+}
+
+// Run runs a command from the remote user's home directory
+func (c *Connection) Run(cmd string) error {
 	config, err := c.clientConfig()
 
 	if err != nil {
@@ -86,7 +195,7 @@ func (c *Connection) Cmd(cmd string) error {
 	}
 
 	hostSocket := net.JoinHostPort(c.config.Hostname, strconv.Itoa(c.config.Port))
-	conn, err := ssh.Dial("tcp", hostSocket, config)
+	conn, err := c.connect(config)
 	if err != nil {
 		return fmt.Errorf("cannot connect %v: %w", hostSocket, err)
 	}
@@ -102,8 +211,7 @@ func (c *Connection) Cmd(cmd string) error {
 	session.Stderr = os.Stderr
 	session.Stdin = os.Stdin
 
-	cmd = fmt.Sprintf("cd %s && %s", c.config.RemotePath, cmd)
-	if err := session.Run(cmd); err != nil {
+	if err := session.Run(c.exports() + cmd); err != nil {
 		return fmt.Errorf("error running command: %w", err)
 	}
 
@@ -120,7 +228,7 @@ func (c *Connection) SSH(ctx context.Context) error {
 	}
 
 	hostSocket := net.JoinHostPort(c.config.Hostname, strconv.Itoa(c.config.Port))
-	conn, err := ssh.Dial("tcp", hostSocket, config)
+	conn, err := c.connect(config)
 	if err != nil {
 		return fmt.Errorf("cannot connect to '%s': %w", hostSocket, err)
 	}
@@ -167,7 +275,12 @@ func (c *Connection) SSH(ctx context.Context) error {
 	session.Stderr = os.Stderr
 	session.Stdin = os.Stdin
 
-	if err := session.Shell(); err != nil {
+	if len(c.env) > 0 {
+		// Start the user's login shell with the environment variables exported
+		if err := session.Start(c.exports() + `exec "${SHELL:-/bin/sh}" -l`); err != nil {
+			return fmt.Errorf("session shell error: %w", err)
+		}
+	} else if err := session.Shell(); err != nil {
 		return fmt.Errorf("session shell error: %w", err)
 	}
 
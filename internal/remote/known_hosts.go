@@ -0,0 +1,113 @@
+package remote
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+
+	"github.com/mitchellh/go-homedir"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// HostKeyConfirmFunc asks whether to trust the key of a host seen for the first time
+type HostKeyConfirmFunc func(host, fingerprint string) (bool, error)
+
+// knownHostsPath is the user's OpenSSH known_hosts file, which ssh and Mutagen check as well
+func knownHostsPath() (string, error) {
+	home, err := homedir.Dir()
+
+	if err != nil {
+		return "", fmt.Errorf("cannot find home directory: %w", err)
+	}
+
+	return filepath.Join(home, ".ssh", "known_hosts"), nil
+}
+
+// knownHostsCallback verifies host keys against a known_hosts file, such as ~/.ssh/known_hosts. A host
+// that isn't known yet is trusted on first use if confirm accepts its key, which is then added to the
+// file. Without confirm, unknown hosts are rejected. Keys that changed are always rejected.
+func knownHostsCallback(path string, confirm HostKeyConfirmFunc) (ssh.HostKeyCallback, error) {
+	// knownhosts.New fails if the file doesn't exist yet
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("could not create %s: %w", filepath.Dir(path), err)
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDONLY, 0600)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not open known_hosts: %w", err)
+	}
+
+	f.Close()
+
+	callback, err := knownhosts.New(path)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not read known_hosts: %w", err)
+	}
+
+	return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+		err := callback(hostname, remote, key)
+
+		var keyErr *knownhosts.KeyError
+		if !errors.As(err, &keyErr) {
+			return err
+		}
+
+		if len(keyErr.Want) > 0 {
+			return fmt.Errorf("host key of %s has changed, it may be impersonated: %w", hostname, err)
+		}
+
+		if confirm == nil {
+			return fmt.Errorf("host key of %s is not in %s: %w", hostname, path, err)
+		}
+
+		trusted, err := confirm(knownhosts.Normalize(hostname), ssh.FingerprintSHA256(key))
+
+		if err != nil {
+			return err
+		}
+
+		if !trusted {
+			return fmt.Errorf("host key of %s was not trusted", hostname)
+		}
+
+		return addKnownHost(path, hostname, key)
+	}, nil
+}
+
+// trustOnFirstUse accepts the key of any host seen for the first time
+func trustOnFirstUse(host, fingerprint string) (bool, error) {
+	return true, nil
+}
+
+// addKnownHost appends a host's key to known_hosts
+func addKnownHost(path, hostname string, key ssh.PublicKey) error {
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_RDWR, 0600)
+
+	if err != nil {
+		return fmt.Errorf("could not open known_hosts: %w", err)
+	}
+
+	defer f.Close()
+
+	line := knownhosts.Line([]string{hostname}, key) + "\n"
+
+	// Don't join our line onto the last one, if the file doesn't end with a newline
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		last := make([]byte, 1)
+
+		if _, err = f.ReadAt(last, info.Size()-1); err == nil && last[0] != '\n' {
+			line = "\n" + line
+		}
+	}
+
+	if _, err = f.WriteString(line); err != nil {
+		return fmt.Errorf("could not add host key to known_hosts: %w", err)
+	}
+
+	return nil
+}
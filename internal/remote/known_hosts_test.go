@@ -0,0 +1,177 @@
+package remote
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"github.com/vessel-app/vessel-cli/internal/util"
+	"golang.org/x/crypto/ssh"
+)
+
+func hostKey(t *testing.T) ssh.PublicKey {
+	t.Helper()
+
+	public, _, err := ed25519.GenerateKey(rand.Reader)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	key, err := ssh.NewPublicKey(public)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func TestKnownHostsCallback(t *testing.T) {
+	addr := &net.TCPAddr{IP: net.ParseIP("192.0.2.1"), Port: 2222}
+	key := hostKey(t)
+
+	path := filepath.Join(t.TempDir(), ".ssh", "known_hosts")
+
+	// Unknown hosts are rejected without confirmation
+	callback, err := knownHostsCallback(path, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = callback("box:2222", addr, key); err == nil {
+		t.Fatal("expected unknown host to be rejected")
+	}
+
+	// Declining to trust the key rejects it
+	callback, err = knownHostsCallback(path, func(host, fingerprint string) (bool, error) {
+		return false, nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = callback("box:2222", addr, key); err == nil {
+		t.Fatal("expected declined host key to be rejected")
+	}
+
+	// Trusting the key adds it to known_hosts
+	var asked string
+	callback, err = knownHostsCallback(path, func(host, fingerprint string) (bool, error) {
+		asked = host
+		if fingerprint != ssh.FingerprintSHA256(key) {
+			t.Errorf("unexpected fingerprint %s", fingerprint)
+		}
+
+		return true, nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = callback("box:2222", addr, key); err != nil {
+		t.Fatalf("expected trusted host key to be accepted: %v", err)
+	}
+
+	if asked != "[box]:2222" {
+		t.Errorf("expected to be asked about [box]:2222, got %s", asked)
+	}
+
+	knownHosts, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(knownHosts), "[box]:2222 ssh-ed25519 ") {
+		t.Errorf("unexpected known_hosts: %s", knownHosts)
+	}
+
+	// Known hosts are accepted without asking again
+	callback, err = knownHostsCallback(path, nil)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = callback("box:2222", addr, key); err != nil {
+		t.Errorf("expected known host key to be accepted: %v", err)
+	}
+
+	// Keys that changed are rejected, even if we'd trust them
+	callback, err = knownHostsCallback(path, func(host, fingerprint string) (bool, error) {
+		return true, nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = callback("box:2222", addr, hostKey(t)); err == nil || !strings.Contains(err.Error(), "has changed") {
+		t.Errorf("expected changed host key to be rejected, got %v", err)
+	}
+}
+
+func TestPinnedHostKey(t *testing.T) {
+	dir := t.TempDir()
+
+	keys, err := util.GenerateSSHKey()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identityFile := filepath.Join(dir, "id_vessel")
+
+	if err = os.WriteFile(identityFile, keys.Private, 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(dir, "known_hosts")
+	connection := NewConnection(&config.RemoteConfig{Hostname: "fdaa::3", Port: 2222, IdentityFile: identityFile}).WithPinnedHostKey(path)
+
+	addr := &net.TCPAddr{IP: net.ParseIP("fdaa::3"), Port: 2222}
+	key := hostKey(t)
+
+	// The key is trusted on first use, and pinned
+	clientConfig, err := connection.clientConfig()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = clientConfig.HostKeyCallback("[fdaa::3]:2222", addr, key); err != nil {
+		t.Fatalf("expected the first key to be trusted: %v", err)
+	}
+
+	knownHosts, err := os.ReadFile(path)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(string(knownHosts), "[fdaa::3]:2222 ssh-ed25519 ") {
+		t.Errorf("unexpected known_hosts: %s", knownHosts)
+	}
+
+	// Later connections only accept the pinned key
+	if clientConfig, err = connection.clientConfig(); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = clientConfig.HostKeyCallback("[fdaa::3]:2222", addr, key); err != nil {
+		t.Errorf("expected the pinned key to be accepted: %v", err)
+	}
+
+	if err = clientConfig.HostKeyCallback("[fdaa::3]:2222", addr, hostKey(t)); err == nil || !strings.Contains(err.Error(), "has changed") {
+		t.Errorf("expected a different key to be rejected, got %v", err)
+	}
+}
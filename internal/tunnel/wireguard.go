@@ -0,0 +1,140 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"net/netip"
+	"strings"
+
+	"github.com/vessel-app/vessel-cli/internal/config"
+	"golang.org/x/crypto/curve25519"
+	"golang.zx2c4.com/wireguard/conn"
+	"golang.zx2c4.com/wireguard/device"
+	"golang.zx2c4.com/wireguard/tun/netstack"
+)
+
+// gatewayPort is the port Fly's WireGuard gateways listen on
+const gatewayPort = "51820"
+
+// mtu leaves room for WireGuard's overhead within a typical 1500 byte packet
+const mtu = 1420
+
+// Tunnel is a WireGuard tunnel into Fly's private network (6PN). It runs entirely within
+// the process, using a userspace network stack, so it needs no root access or TUN device.
+type Tunnel struct {
+	device *device.Device
+	net    *netstack.Net
+}
+
+// GenerateKeys generates a WireGuard key pair, base64 encoded as Fly's API expects
+func GenerateKeys() (privateKey string, publicKey string, err error) {
+	private := make([]byte, curve25519.ScalarSize)
+
+	if _, err = rand.Read(private); err != nil {
+		return "", "", fmt.Errorf("could not generate wireguard private key: %w", err)
+	}
+
+	// Clamp the key, see https://cr.yp.to/ecdh.html
+	private[0] &= 248
+	private[31] = (private[31] & 127) | 64
+
+	public, err := curve25519.X25519(private, curve25519.Basepoint)
+
+	if err != nil {
+		return "", "", fmt.Errorf("could not generate wireguard public key: %w", err)
+	}
+
+	return base64.StdEncoding.EncodeToString(private), base64.StdEncoding.EncodeToString(public), nil
+}
+
+// Up starts a tunnel using a WireGuard peer. Hostnames are resolved with the private
+// network's DNS server, so .internal hostnames can be dialed.
+func Up(peer *config.WireGuardState) (*Tunnel, error) {
+	local, err := netip.ParseAddr(peer.PeerIp)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid wireguard peer ip '%s': %w", peer.PeerIp, err)
+	}
+
+	network, err := local.Prefix(48)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid wireguard peer ip '%s': %w", peer.PeerIp, err)
+	}
+
+	// The private network's DNS server is at <network>::3
+	dnsServer := network.Addr().As16()
+	dnsServer[15] = 3
+
+	privateKey, err := hexKey(peer.PrivateKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid wireguard private key: %w", err)
+	}
+
+	publicKey, err := hexKey(peer.PublicKey)
+
+	if err != nil {
+		return nil, fmt.Errorf("invalid wireguard gateway public key: %w", err)
+	}
+
+	tun, tnet, err := netstack.CreateNetTUN([]netip.Addr{local}, []netip.Addr{netip.AddrFrom16(dnsServer)}, mtu)
+
+	if err != nil {
+		return nil, fmt.Errorf("could not create userspace network: %w", err)
+	}
+
+	dev := device.NewDevice(tun, conn.NewDefaultBind(), device.NewLogger(device.LogLevelSilent, ""))
+
+	ipc := strings.Join([]string{
+		"private_key=" + privateKey,
+		"public_key=" + publicKey,
+		"endpoint=" + net.JoinHostPort(peer.EndpointIp, gatewayPort),
+		"allowed_ip=" + network.String(),
+		"persistent_keepalive_interval=15",
+	}, "\n")
+
+	if err = dev.IpcSet(ipc); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("could not configure wireguard: %w", err)
+	}
+
+	if err = dev.Up(); err != nil {
+		dev.Close()
+		return nil, fmt.Errorf("could not start wireguard: %w", err)
+	}
+
+	return &Tunnel{
+		device: dev,
+		net:    tnet,
+	}, nil
+}
+
+// DialContext connects to an address within the private network, e.g. a machine's .internal hostname
+func (t *Tunnel) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	return t.net.DialContext(ctx, network, address)
+}
+
+// Close stops the tunnel, closing any connections made through it
+func (t *Tunnel) Close() {
+	t.device.Close()
+}
+
+// hexKey converts a base64 key into hex, as WireGuard's configuration protocol expects
+func hexKey(key string) (string, error) {
+	raw, err := base64.StdEncoding.DecodeString(key)
+
+	if err != nil {
+		return "", err
+	}
+
+	if len(raw) != curve25519.ScalarSize {
+		return "", fmt.Errorf("key must be %d bytes, got %d", curve25519.ScalarSize, len(raw))
+	}
+
+	return hex.EncodeToString(raw), nil
+}
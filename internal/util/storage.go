@@ -75,6 +75,8 @@ func MakeEnvStorageDir() (string, error) {
 	return vesselPath, nil
 }
 
+// GetAppEnvDir returns the full path for an app's env directory
+// ~/.vessel/envs/<app-name>
 func GetAppEnvDir(appName string) (string, error) {
 	home, err := homedir.Dir()
 
@@ -82,7 +84,7 @@ func GetAppEnvDir(appName string) (string, error) {
 		return "", fmt.Errorf("could not find home dir: %w", err)
 	}
 
-	return filepath.FromSlash(fmt.Sprintf("%s/envs/%s", home, appName)), nil
+	return filepath.FromSlash(fmt.Sprintf("%s/.vessel/envs/%s", home, appName)), nil
 }
 
 // MakeAppDir creates a ~/.vessel/envs/<app-name> directory
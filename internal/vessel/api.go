@@ -2,9 +2,11 @@ package vessel
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
 	"github.com/vessel-app/vessel-cli/internal/logger"
+	"github.com/vessel-app/vessel-cli/internal/wait"
 	"io"
 	"net/http"
 	"os"
@@ -42,6 +44,26 @@ type Environment struct {
 	Initialized bool   `json:"initialized"`
 }
 
+// RequestError is returned for API responses with an error status code
+type RequestError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *RequestError) Error() string {
+	return fmt.Sprintf("invalid request: status=%d, body=%s", e.StatusCode, e.Body)
+}
+
+// requestError reads the body of an error response, so the API's reason is surfaced
+func requestError(r *http.Response) *RequestError {
+	body, _ := io.ReadAll(r.Body)
+
+	return &RequestError{
+		StatusCode: r.StatusCode,
+		Body:       strings.TrimSpace(string(body)),
+	}
+}
+
 func GetUser(token string) (*User, error) {
 	url := fmt.Sprintf("%s/user", vesselApiEndpoint())
 
@@ -50,6 +72,7 @@ func GetUser(token string) (*User, error) {
 	}
 	req, _ := http.NewRequest("GET", url, nil)
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
 
 	logger.GetLogger().Debug("caller", "api::GetUser", "msg", "about to call Vessel API", "url", url, "http_method", "GET")
 
@@ -63,7 +86,7 @@ func GetUser(token string) (*User, error) {
 	defer r.Body.Close()
 
 	if r.StatusCode > 299 {
-		return nil, fmt.Errorf("invalid user request: %w", err)
+		return nil, fmt.Errorf("invalid user request: %w", requestError(r))
 	}
 
 	user := &User{}
@@ -112,9 +135,9 @@ func CreateEnvironment(team, name, publicKey, region, token string) (*Environmen
 	defer r.Body.Close()
 
 	if r.StatusCode > 299 {
-		b, _ := io.ReadAll(r.Body)
-		logger.GetLogger().Debug("caller", "api::CreateEnvironment", "msg", "http request error", "status", r.StatusCode, "body", string(b))
-		return nil, fmt.Errorf("invalid create environment request: %w", err)
+		reqErr := requestError(r)
+		logger.GetLogger().Debug("caller", "api::CreateEnvironment", "msg", "http request error", "status", reqErr.StatusCode, "body", reqErr.Body)
+		return nil, fmt.Errorf("invalid create environment request: %w", reqErr)
 	}
 
 	env := &Environment{}
@@ -127,35 +150,26 @@ func CreateEnvironment(team, name, publicKey, region, token string) (*Environmen
 	return env, nil
 }
 
-// WaitForEnvironment polls the API and waits for the environment to be ready to use
-//  before finally returning it
-func WaitForEnvironment(team string, machine uint64, token string) (*Environment, error) {
-	// Total wait time ~5 minutes (should only need a minute or 2)
-	ticker := time.NewTicker(2 * time.Second)
-	totalAttempts := 0
-	attemptsAllowed := 150
-
-	for {
-		select {
-		case <-ticker.C:
-			e, err := GetEnvironment(team, machine, token)
-			if err != nil {
-				ticker.Stop()
-				return nil, fmt.Errorf("could not get environment: %w", err)
-			}
-
-			if e.Initialized {
-				ticker.Stop()
-				return e, nil
-			}
-
-			totalAttempts++
-			if totalAttempts >= attemptsAllowed {
-				ticker.Stop()
-				return nil, fmt.Errorf("too many get environment attempts")
-			}
+// WaitForEnvironment waits for the environment to be ready to use before finally returning it.
+// Each status the environment passes through is reported to onChange. A wait.TimeoutError,
+// including the last known status, is returned if the context ends first.
+func WaitForEnvironment(ctx context.Context, team string, machine uint64, token string, onChange wait.StateFunc) (*Environment, error) {
+	var e *Environment
+
+	check := func(ctx context.Context) (string, bool, bool, error) {
+		var err error
+		if e, err = GetEnvironment(team, machine, token); err != nil {
+			return "", false, false, fmt.Errorf("could not get environment: %w", err)
 		}
+
+		return e.Status, e.Initialized, e.Status == "failed", nil
+	}
+
+	if _, err := wait.Until(ctx, check, nil, onChange); err != nil {
+		return nil, fmt.Errorf("environment %d not ready: %w", machine, err)
 	}
+
+	return e, nil
 }
 
 // GetEnvironment retrieves a development environment
@@ -168,8 +182,9 @@ func GetEnvironment(team string, machine uint64, token string) (*Environment, er
 
 	req, _ := http.NewRequest("GET", url, nil)
 	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
 
-	logger.GetLogger().Debug("caller", "api::GetUser", "msg", "about to call Vessel API", "url", url, "http_method", "GET")
+	logger.GetLogger().Debug("caller", "api::GetEnvironment", "msg", "about to call Vessel API", "url", url, "http_method", "GET")
 
 	r, err := client.Do(req)
 
@@ -181,7 +196,7 @@ func GetEnvironment(team string, machine uint64, token string) (*Environment, er
 	defer r.Body.Close()
 
 	if r.StatusCode > 299 {
-		return nil, fmt.Errorf("invalid user request: %w", err)
+		return nil, fmt.Errorf("invalid get environment request: %w", requestError(r))
 	}
 
 	environment := &Environment{}
@@ -194,6 +209,42 @@ func GetEnvironment(team string, machine uint64, token string) (*Environment, er
 	return environment, nil
 }
 
+// DeleteEnvironment deletes a development environment
+func DeleteEnvironment(team string, machine uint64, token string) error {
+	url := fmt.Sprintf("%s/team/%s/environment/%d", vesselApiEndpoint(), team, machine)
+
+	client := &http.Client{
+		Timeout: time.Second * 30,
+	}
+
+	req, _ := http.NewRequest("DELETE", url, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.Header.Set("Accept", "application/json")
+
+	logger.GetLogger().Debug("caller", "api::DeleteEnvironment", "msg", "about to call Vessel API", "url", url, "http_method", "DELETE")
+
+	r, err := client.Do(req)
+
+	if err != nil {
+		// 500 errors go here
+		return fmt.Errorf("http client error: %w", err)
+	}
+
+	defer r.Body.Close()
+
+	// The environment is already gone
+	if r.StatusCode == http.StatusNotFound {
+		return nil
+	}
+
+	if r.StatusCode > 299 {
+		return fmt.Errorf("invalid delete environment request: %w", requestError(r))
+	}
+
+	return nil
+}
+
+// vesselApiEndpoint is the base URL of the Vessel API, set with VESSEL_API_ENDPOINT
 func vesselApiEndpoint() string {
 	endpoint := strings.TrimRight(os.Getenv("VESSEL_API_ENDPOINT"), "/")
 
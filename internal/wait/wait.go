@@ -0,0 +1,104 @@
+package wait
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// Backoff between polls when there's no way to block until a change
+const (
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 5 * time.Second
+)
+
+// StateFunc is called with each new state seen while waiting
+type StateFunc func(state string)
+
+// CheckFunc gets the current state. Waiting ends successfully once done is true,
+// and with a FailedError once failed is true.
+type CheckFunc func(ctx context.Context) (state string, done bool, failed bool, err error)
+
+// BlockFunc waits until the state may have changed, e.g. by long-polling an API. It
+// returns false if blocking isn't supported, so polling with backoff is used instead.
+type BlockFunc func(ctx context.Context) bool
+
+// TimeoutError is returned when the context ends before the desired state is reached
+type TimeoutError struct {
+	LastState string
+	Err       error
+}
+
+func (e *TimeoutError) Error() string {
+	return fmt.Sprintf("timed out waiting, last state was '%s': %v", e.LastState, e.Err)
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// FailedError is returned when a state is reached that the desired state can't follow
+type FailedError struct {
+	State string
+}
+
+func (e *FailedError) Error() string {
+	return fmt.Sprintf("reached failed state '%s'", e.State)
+}
+
+// Until checks the state until done, blocking between checks when block is given
+// and supported, and otherwise polling with backoff. The last state seen is returned.
+func Until(ctx context.Context, check CheckFunc, block BlockFunc, onChange StateFunc) (string, error) {
+	lastState := ""
+	backoff := initialBackoff
+
+	for {
+		state, done, failed, err := check(ctx)
+
+		if err != nil {
+			if ctx.Err() != nil {
+				return lastState, &TimeoutError{LastState: lastState, Err: ctx.Err()}
+			}
+
+			return lastState, err
+		}
+
+		if state != lastState {
+			lastState = state
+
+			if onChange != nil {
+				onChange(state)
+			}
+		}
+
+		if done {
+			return lastState, nil
+		}
+
+		if failed {
+			return lastState, &FailedError{State: state}
+		}
+
+		if block != nil && block(ctx) {
+			if ctx.Err() != nil {
+				return lastState, &TimeoutError{LastState: lastState, Err: ctx.Err()}
+			}
+
+			continue
+		}
+
+		// Blocking isn't supported, so don't try it again
+		block = nil
+
+		select {
+		case <-ctx.Done():
+			return lastState, &TimeoutError{LastState: lastState, Err: ctx.Err()}
+		case <-time.After(backoff):
+		}
+
+		backoff = backoff * 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+}